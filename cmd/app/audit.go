@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/audit"
+	"pin-go-dependencies/internal/ghactions"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/osv"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newAuditCmd() *cobra.Command {
+	var (
+		file        string
+		failOn      string
+		ignore      []string
+		dbPath      string
+		strict      bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Report known vulnerabilities of the build list",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var threshold osv.Severity
+			if failOn != "" {
+				var err error
+				if threshold, err = osv.ParseSeverity(failOn); err != nil {
+					return &usageError{err: fmt.Errorf("invalid --fail-on: %w", err)}
+				}
+			}
+
+			mods, err := audit.Modules(cmd.Context(), file)
+			if err != nil {
+				return err
+			}
+			src, err := vulnSource(cmd, dbPath, concurrency)
+			if err != nil {
+				return err
+			}
+			ignore, _ = listSetting(cmd, "ignore", ignore, cfg.Audit.Ignore)
+			findings, err := audit.Run(src, mods, ignore)
+			if err != nil {
+				// An unreachable OSV API should not break builds that
+				// merely report, but a local database must be readable.
+				if dbPath != "" || strict {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: vulnerability lookup failed: %v\n", err)
+				return nil
+			}
+
+			if findings == nil {
+				findings = []audit.Finding{}
+			}
+			err = rep.Result(findings, func(out io.Writer) error {
+				if githubOutput() {
+					return annotateFindings(out, file, findings, threshold)
+				}
+				printFindings(out, findings)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if threshold != "" && failsAudit(findings, threshold) {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "exit with 1 if there are findings of at least this severity: low, moderate, high or critical")
+	cmd.Flags().StringArrayVar(&ignore, "ignore", nil, "vulnerability ID or alias to leave out; can be repeated")
+	cmd.Flags().StringVar(&dbPath, "db", "", "read vulnerabilities from a downloaded OSV database (zip, directory or JSON file) instead of the OSV API")
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail instead of warning when the OSV API cannot be reached")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent OSV requests")
+	return cmd
+}
+
+// vulnSource returns the source of vulnerabilities: the downloaded OSV
+// database at dbPath if it is set, and the OSV API otherwise.
+func vulnSource(cmd *cobra.Command, dbPath string, concurrency int) (osv.Source, error) {
+	if dbPath != "" {
+		db, err := osv.LoadDB(dbPath)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	if err := requireNetwork(cmd, "queries the OSV database; use --db with a local copy"); err != nil {
+		return nil, err
+	}
+	return osv.NewClient(cmd.Context(), osv.DefaultURL, concurrency, failFast), nil
+}
+
+// failsAudit reports whether any finding is at least as severe as threshold.
+// Findings of unknown severity always count, since they may be critical.
+func failsAudit(findings []audit.Finding, threshold osv.Severity) bool {
+	for _, f := range findings {
+		if f.Severity == osv.SeverityUnknown || f.Severity.Rank() >= threshold.Rank() {
+			return true
+		}
+	}
+	return false
+}
+
+// printFindings prints the findings grouped by severity. They arrive sorted
+// from most to least severe.
+func printFindings(out io.Writer, findings []audit.Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintln(out, "no known vulnerabilities")
+		return
+	}
+	for i := 0; i < len(findings); {
+		sev := findings[i].Severity
+		j := i
+		for j < len(findings) && findings[j].Severity == sev {
+			j++
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s (%d):\n", strings.ToUpper(string(sev)), j-i)
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, f := range findings[i:j] {
+			fixed := "no fix"
+			if f.Fixed != "" {
+				fixed = "fixed in " + f.Fixed
+			}
+			fmt.Fprintf(tw, "  %s\t%s@%s\t%s\t%s\n", f.ID, f.Path, f.Version, fixed, f.Summary)
+		}
+		tw.Flush()
+		i = j
+	}
+}
+
+// annotateFindings reports the findings as GitHub Actions annotations on the
+// require directives of file and in the job summary. Findings that make the
+// command fail are errors, the others warnings. Modules without a require
+// directive annotate the file as a whole.
+func annotateFindings(out io.Writer, file string, findings []audit.Finding, threshold osv.Severity) error {
+	m, err := gomod.Load(file)
+	if err != nil {
+		return err
+	}
+	var rows [][]string
+	for _, f := range findings {
+		fixed := "no fix"
+		if f.Fixed != "" {
+			fixed = "fixed in " + f.Fixed
+		}
+		fails := threshold != "" && failsAudit([]audit.Finding{f}, threshold)
+		ghactions.Write(out, ghactions.Annotation{
+			Level:   annotationLevel(fails),
+			File:    file,
+			Line:    m.Line(f.Path),
+			Title:   fmt.Sprintf("audit: %s (%s)", f.ID, f.Severity),
+			Message: fmt.Sprintf("%s@%s: %s (%s)", f.Path, f.Version, f.Summary, fixed),
+		})
+		rows = append(rows, []string{string(f.Severity), f.ID, f.Path + "@" + f.Version, fixed, f.Summary})
+	}
+	return ghactions.WriteSummary("app audit", []string{"Severity", "ID", "Module", "Fix", "Summary"}, rows, "No known vulnerabilities.")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/proxy"
+)
+
+// cacheResult is the JSON result of cache clean.
+type cacheResult struct {
+	Dir   string `json:"dir"`
+	Freed int64  `json:"freed"`
+}
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the module proxy cache",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clean",
+		Short: "Remove all cached module proxy responses",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := proxy.DefaultCacheDir()
+			if err != nil {
+				return err
+			}
+			c := &proxy.Cache{Dir: dir}
+			freed, err := c.Clean()
+			if err != nil {
+				return err
+			}
+			res := cacheResult{Dir: dir, Freed: freed}
+			return rep.Result(res, func(out io.Writer) error {
+				_, err := fmt.Fprintf(out, "removed %s, %d bytes freed\n", dir, freed)
+				return err
+			})
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/config"
+	"pin-go-dependencies/internal/ghactions"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/lockfile"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/toolchain"
+	"pin-go-dependencies/internal/workpool"
+	"pin-go-dependencies/pkg/pinner"
+)
+
+func newCheckCmd() *cobra.Command {
+	var (
+		file   string
+		format string
+		quiet  bool
+		ignore []string
+		allow  []string
+		lock   string
+		filter filterFlags
+		opts   pinner.CheckOptions
+
+		concurrency int
+		tf          templateFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Fail when a dependency is not reproducibly pinned",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tf.help {
+				return describeTemplate([]pinner.Violation{}, `{{range .}}{{.Path}}@{{shortSHA .Version}}: {{.Rule}}{{"\n"}}{{end}}`)
+			}
+			if err := checkFormat(format, "text", "json"); err != nil {
+				return err
+			}
+			t, err := tf.parse(cmd)
+			if err != nil {
+				return err
+			}
+			github := githubOutput()
+			if github && format == "json" {
+				return usageErrorf("--output github cannot be combined with --format json")
+			}
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			p, err := newPinner(concurrency)
+			if err != nil {
+				return err
+			}
+			if lock != "" {
+				opts.LockFile = lockPath(file, lock)
+			}
+			ignore, _ = listSetting(cmd, "ignore", ignore, cfg.Check.Ignore)
+			opts.Ignore = matcher(ignore)
+			if sel := filter.selector(m); sel != nil {
+				// Violations about anything but a requirement, such as
+				// the go directive, are not subject to the filter.
+				required := make(map[string]bool)
+				for _, r := range m.Requires() {
+					required[r.Path] = true
+				}
+				ignored := opts.Ignore
+				opts.Ignore = func(path string) bool {
+					return ignored != nil && ignored(path) || required[path] && !sel(path)
+				}
+			}
+			if !cmd.Flags().Changed("min-go") {
+				opts.MinGo = cfg.Check.MinGo
+			}
+			if opts.MinGo != "" && !toolchain.IsValid(opts.MinGo) {
+				return usageErrorf("invalid --min-go %q, expected a Go version such as 1.21", opts.MinGo)
+			}
+			opts.RequireToolchain = opts.RequireToolchain || cfg.Check.RequireToolchain
+			allow, _ = listSetting(cmd, "allow-pseudo-tool", allow, cfg.Check.AllowPseudoTools)
+			opts.AllowPseudoTool = matcher(allow)
+			if opts.Rules, opts.Exceptions, err = checkRules(cfg.Rules); err != nil {
+				return err
+			}
+			vs, lookupErr := p.CheckWith(cmd.Context(), file, opts)
+			if vs == nil {
+				return lookupErr
+			}
+
+			if github {
+				if err := checkSummary(vs); err != nil {
+					return err
+				}
+			}
+			err = rep.Result(vs, func(out io.Writer) error {
+				switch {
+				case quiet:
+				case t != nil:
+					return t.Execute(out, vs)
+				case github:
+					for _, v := range vs {
+						level := ghactions.LevelError
+						if v.Exception != "" {
+							level = ghactions.LevelNotice
+						}
+						ghactions.Write(out, ghactions.Annotation{
+							Level:   level,
+							File:    v.File,
+							Line:    v.Line,
+							Title:   "check: " + v.Rule,
+							Message: fmt.Sprintf("%s %s: %s%s", v.Path, v.Version, v.Reason, exceptionNote(v)),
+						})
+					}
+				case format == "json":
+					return writeJSON(out, vs)
+				default:
+					for _, v := range vs {
+						fmt.Fprintf(out, "%s %s: %s (%s)%s\n", v.Path, v.Version, v.Reason, v.Rule, exceptionNote(v))
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if lookupErr != nil {
+				return lookupErr
+			}
+			if pinner.Failing(vs) > 0 {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file to check")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text or json")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "print nothing, only set the exit code")
+	cmd.Flags().StringArrayVar(&ignore, "ignore", nil, "module path pattern whose violations are left out; can be repeated")
+	cmd.Flags().StringVar(&lock, "lock", "", "verify that the build list and go.sum match this lock file")
+	cmd.Flags().Lookup("lock").NoOptDefVal = lockfile.FileName
+	cmd.Flags().BoolVar(&opts.NoPseudo, "no-pseudo", false, "report requirements on pseudo-versions")
+	cmd.Flags().StringVar(&opts.MinGo, "min-go", "", "report a go directive older than this Go version, such as 1.21")
+	cmd.Flags().BoolVar(&opts.RequireToolchain, "require-toolchain", false, "report a missing toolchain directive")
+	cmd.Flags().StringArrayVar(&allow, "allow-pseudo-tool", nil, "tool path pattern that may be pinned to a pseudo-version; can be repeated")
+	cmd.Flags().BoolVar(&opts.Generate, "generate", false, "report go:generate directives running a tool with go run at a floating version, like scan-generate")
+	cmd.Flags().BoolVar(&opts.MVS, "mvs", false, "report requirements below the version minimal version selection picks from the requirement graph")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy requests")
+	filter.register(cmd)
+	tf.register(cmd)
+	cmd.MarkFlagsMutuallyExclusive("quiet", "template")
+	cmd.MarkFlagsMutuallyExclusive("quiet", "template-file")
+	return cmd
+}
+
+// checkRules converts the rules of .pin.yaml, which Parse has validated.
+func checkRules(r config.Rules) (pinner.Rules, []pinner.Exception, error) {
+	rules := pinner.Rules{
+		ForbidPseudo: r.ForbidPseudoVersions.Enabled,
+		MajorSuffix:  r.RequireMajorVersionSuffixMatch,
+	}
+	if a := r.ForbidPseudoVersions.MaxAge; a != "" {
+		var err error
+		if rules.MaxPseudoAge, err = config.ParseAge(a); err != nil {
+			return rules, nil, err
+		}
+	}
+	rules.ForbidModule = matcher(r.ForbidModules)
+	for pattern, v := range r.MinVersions {
+		min, err := config.ParseMinVersion(v)
+		if err != nil {
+			return rules, nil, err
+		}
+		rules.MinVersions = append(rules.MinVersions, pinner.MinVersion{Pattern: pattern, Version: min})
+	}
+	// The most specific pattern comes first, so it wins over broader ones;
+	// patterns of the same length are in lexical order.
+	sort.Slice(rules.MinVersions, func(i, j int) bool {
+		pi, pj := rules.MinVersions[i].Pattern, rules.MinVersions[j].Pattern
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return pi < pj
+	})
+	var exceptions []pinner.Exception
+	for _, e := range r.Exceptions {
+		exceptions = append(exceptions, pinner.Exception{Rule: e.Rule, Module: e.Module, Justification: e.Justification})
+	}
+	return rules, exceptions, nil
+}
+
+// exceptionNote returns the suffix noting the exception covering v, if any.
+func exceptionNote(v pinner.Violation) string {
+	if v.Exception == "" {
+		return ""
+	}
+	return " [excepted: " + v.Exception + "]"
+}
+
+// checkSummary writes the violations to the GitHub Actions job summary.
+func checkSummary(vs []pinner.Violation) error {
+	var rows [][]string
+	for _, v := range vs {
+		loc := v.File
+		if v.Line > 0 {
+			loc = fmt.Sprintf("%s:%d", v.File, v.Line)
+		}
+		rows = append(rows, []string{v.Path, v.Version, v.Rule, v.Reason, loc})
+	}
+	return ghactions.WriteSummary("app check", []string{"Module", "Version", "Rule", "Reason", "Location"}, rows, "No violations.")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/config"
+)
+
+// Sources of effective settings, as shown by `config show`.
+const (
+	sourceFlag    = "flag"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceDefault = "default"
+)
+
+var (
+	// configFlag is the --config flag; cfg and cfgFile are the loaded
+	// configuration and its file, which is empty if there is none.
+	configFlag string
+	cfg        = &config.Config{}
+	cfgFile    string
+)
+
+// loadConfig loads the configuration for the commands. It runs before
+// every subcommand.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	var err error
+	cfg, cfgFile, err = config.Resolve(configFlag, ".")
+	return err
+}
+
+// matcher returns a function matching module paths against patterns, or nil
+// if there are none.
+func matcher(patterns []string) func(string) bool {
+	if len(patterns) == 0 {
+		return nil
+	}
+	return func(path string) bool { return config.Match(patterns, path) }
+}
+
+// listSetting returns a list flag of cmd if it was set and the list from the
+// configuration file otherwise, together with its source.
+func listSetting(cmd *cobra.Command, flag string, flagValue, fileValue []string) ([]string, string) {
+	switch {
+	case cmd.Flags().Changed(flag):
+		return flagValue, sourceFlag
+	case len(fileValue) > 0:
+		return fileValue, sourceFile
+	}
+	return flagValue, sourceDefault
+}
+
+// configResult is the JSON result of config show. File is empty if there
+// is no configuration file.
+type configResult struct {
+	File     string    `json:"file"`
+	Settings []setting `json:"settings"`
+}
+
+// setting is an effective setting and the source of its value.
+type setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration and the source of each value",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			list := func(l []string) string {
+				if len(l) == 0 {
+					return "-"
+				}
+				return strings.Join(l, ", ")
+			}
+			within, withinSource := "minor", sourceDefault
+			if cfg.Update.Within != "" {
+				within, withinSource = cfg.Update.Within, sourceFile
+			}
+			proxyURL, proxySource, err := goproxy()
+			if err != nil {
+				return err
+			}
+			ttl, ttlSource := cacheTTL()
+			disabled, disabledSource := noCache()
+			// Module lists have no global flags, so they come from the
+			// file or are empty.
+			listSource := func(l []string) string {
+				if len(l) > 0 {
+					return sourceFile
+				}
+				return sourceDefault
+			}
+			minGo, minGoSource := cfg.Check.MinGo, sourceFile
+			if minGo == "" {
+				minGo, minGoSource = "-", sourceDefault
+			}
+			requireSource := sourceDefault
+			if cfg.Check.RequireToolchain {
+				requireSource = sourceFile
+			}
+			unknownSource := sourceDefault
+			if cfg.Licenses.UnknownFails {
+				unknownSource = sourceFile
+			}
+			rules := cfg.Rules
+			pseudo, pseudoSource := "false", sourceDefault
+			if rules.ForbidPseudoVersions.Enabled {
+				pseudo, pseudoSource = "true", sourceFile
+				if a := rules.ForbidPseudoVersions.MaxAge; a != "" {
+					pseudo = "max-age " + a
+				}
+			}
+			suffixSource := sourceDefault
+			if rules.RequireMajorVersionSuffixMatch {
+				suffixSource = sourceFile
+			}
+			var minVersions, exceptions []string
+			for pattern, v := range rules.MinVersions {
+				minVersions = append(minVersions, pattern+" "+v)
+			}
+			sort.Strings(minVersions)
+			for _, e := range rules.Exceptions {
+				exceptions = append(exceptions, e.Rule+" "+e.Module)
+			}
+			keep, keepSource := backupKeep()
+			globs, globsSource := scriptGlobs(cmd, nil)
+
+			res := configResult{File: cfgFile, Settings: []setting{
+				{"exclude", list(cfg.Exclude), listSource(cfg.Exclude)},
+				{"forbidMajor", list(cfg.ForbidMajor), listSource(cfg.ForbidMajor)},
+				{"update.within", within, withinSource},
+				{"proxy.url", proxyURL, proxySource},
+				{"cache.ttl", ttl.String(), ttlSource},
+				{"cache.disabled", fmt.Sprint(disabled), disabledSource},
+				{"audit.ignore", list(cfg.Audit.Ignore), listSource(cfg.Audit.Ignore)},
+				{"check.ignore", list(cfg.Check.Ignore), listSource(cfg.Check.Ignore)},
+				{"check.minGo", minGo, minGoSource},
+				{"check.requireToolchain", fmt.Sprint(cfg.Check.RequireToolchain), requireSource},
+				{"check.allowPseudoTools", list(cfg.Check.AllowPseudoTools), listSource(cfg.Check.AllowPseudoTools)},
+				{"backup.keep", fmt.Sprint(keep), keepSource},
+				{"scripts.globs", list(globs), globsSource},
+				{"licenses.deny", list(cfg.Licenses.Deny), listSource(cfg.Licenses.Deny)},
+				{"licenses.unknownFails", fmt.Sprint(cfg.Licenses.UnknownFails), unknownSource},
+				{"rules.forbid-pseudo-versions", pseudo, pseudoSource},
+				{"rules.forbid-modules", list(rules.ForbidModules), listSource(rules.ForbidModules)},
+				{"rules.require-major-version-suffix-match", fmt.Sprint(rules.RequireMajorVersionSuffixMatch), suffixSource},
+				{"rules.min-version", list(minVersions), listSource(minVersions)},
+				{"rules.exceptions", list(exceptions), listSource(exceptions)},
+			}}
+			return rep.Result(res, func(out io.Writer) error {
+				if cfgFile == "" {
+					fmt.Fprintf(out, "config file: none (no %s found)\n\n", config.FileName)
+				} else {
+					fmt.Fprintf(out, "config file: %s\n\n", cfgFile)
+				}
+				tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+				for _, s := range res.Settings {
+					fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+				}
+				return tw.Flush()
+			})
+		},
+	})
+	return cmd
+}
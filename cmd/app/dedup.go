@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/dedup"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/modgraph"
+	"pin-go-dependencies/internal/report"
+)
+
+func newDedupCmd() *cobra.Command {
+	var (
+		file    string
+		suggest bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dedup",
+		Short: "Find modules in the build list more than once, at several major versions or under several paths",
+		Long: `Find modules the build list holds more than once: the same module at several
+major versions, such as example.com/m and example.com/m/v4, and the same
+module under several paths, either because a replaced module declares a
+different path in its go.mod or because the path is known to be superseded,
+such as github.com/golang/protobuf by google.golang.org/protobuf. Each
+variant is shown with the direct requirements pulling it in.
+
+With --suggest, the commands and directives that would consolidate the
+variants are printed. Nothing is ever changed; the command exits with 1 if
+there are duplicates.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			dir := filepath.Dir(file)
+			g, err := modgraph.Load(cmd.Context(), dir)
+			if err != nil {
+				return err
+			}
+			mods, err := gocmd.ListModules(cmd.Context(), dir, gocmd.ListOptions{})
+			if err != nil {
+				return err
+			}
+			direct := make(map[string]bool)
+			for _, r := range m.Requires() {
+				direct[r.Path] = !r.Indirect
+			}
+
+			dups := dedup.Find(g.Pinned(), mods, direct)
+			if suggest {
+				for i := range dups {
+					dedup.Suggest(&dups[i])
+				}
+			}
+			if dups == nil {
+				dups = []dedup.Duplicate{}
+			}
+			err = rep.Result(dups, func(out io.Writer) error {
+				printDuplicates(out, dups)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(dups) > 0 {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().BoolVar(&suggest, "suggest", false, "print the replace directives and update commands that would consolidate the duplicates")
+	return cmd
+}
+
+func printDuplicates(out io.Writer, dups []dedup.Duplicate) {
+	if len(dups) == 0 {
+		fmt.Fprintln(out, "no duplicate modules")
+		return
+	}
+	for i, d := range dups {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if d.Kind == dedup.KindMajor {
+			fmt.Fprintf(out, "%s: %d major versions\n", d.Module, len(d.Variants))
+		} else {
+			fmt.Fprintf(out, "%s: %d paths\n", d.Module, len(d.Variants))
+		}
+		for _, v := range d.Variants {
+			name := v.Path + " " + v.Version
+			if v.Declared != "" {
+				name += " (replaced, declares " + v.Declared + ")"
+			}
+			var by []string
+			if v.Direct {
+				by = append(by, "directly")
+			}
+			if len(v.RequiredBy) > 0 {
+				by = append(by, "by "+strings.Join(v.RequiredBy, ", "))
+			}
+			if len(by) == 0 {
+				fmt.Fprintf(out, "  %s\n", name)
+			} else {
+				fmt.Fprintf(out, "  %s, required %s\n", name, strings.Join(by, " and "))
+			}
+		}
+		if len(d.Suggestions) > 0 {
+			fmt.Fprintln(out, "  suggested:")
+			for _, s := range d.Suggestions {
+				fmt.Fprintf(out, "    %s\n", s)
+			}
+		}
+	}
+}
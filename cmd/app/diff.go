@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gitrev"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/moddiff"
+)
+
+// diffResult is the JSON output of the diff command. To is empty for the
+// working tree.
+type diffResult struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	*moddiff.Report
+}
+
+// diffSection is a group of changes in the text and markdown reports.
+type diffSection struct {
+	title string
+	// warn marks changes that deserve extra scrutiny.
+	warn   bool
+	header []string
+	rows   [][]string
+}
+
+func newDiffCmd() *cobra.Command {
+	var (
+		file   string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff [ref1] [ref2]",
+		Short: "Show the dependency changes between two git revisions",
+		Long: `Show the modules added, removed, upgraded and downgraded between the go.mod
+and go.sum files of two git revisions. ref1 defaults to HEAD and ref2 to the
+working tree.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "text", "json", "markdown"); err != nil {
+				return err
+			}
+			from, to := "HEAD", gitrev.WorkingTree
+			if len(args) > 0 {
+				from = args[0]
+			}
+			if len(args) > 1 {
+				to = args[1]
+			}
+			oldMod, oldSum, err := loadRevision(from, file)
+			if err != nil {
+				return err
+			}
+			newMod, newSum, err := loadRevision(to, file)
+			if err != nil {
+				return err
+			}
+
+			res := diffResult{From: from, To: to, Report: moddiff.Compare(oldMod, newMod, oldSum, newSum)}
+			return rep.Result(res, func(out io.Writer) error {
+				switch format {
+				case "json":
+					return writeJSON(out, res)
+				case "markdown":
+					printDiffMarkdown(out, from, to, diffSections(res.Report))
+				default:
+					printDiffText(out, from, to, diffSections(res.Report))
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text, json or markdown")
+	return cmd
+}
+
+// loadRevision reads the go.mod at file and the go.sum next to it as they
+// are at rev. A missing go.sum counts as empty.
+func loadRevision(rev, file string) (*gomod.Module, *gosum.Sum, error) {
+	data, err := gitrev.ReadFile(rev, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := gomod.Parse(file, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	sumFile := filepath.Join(filepath.Dir(file), "go.sum")
+	data, err = gitrev.ReadFile(rev, sumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	sum, err := gosum.Parse(sumFile, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, sum, nil
+}
+
+// diffSections groups the changes of r for printing, leaving out empty
+// groups. Downgrades come first since they usually deserve a closer look.
+func diffSections(r *moddiff.Report) []diffSection {
+	module := func(c moddiff.Change) string {
+		if c.Indirect {
+			return c.Path + " (indirect)"
+		}
+		return c.Path
+	}
+	var sections []diffSection
+	add := func(s diffSection, changes []moddiff.Change, row func(moddiff.Change) []string) {
+		for _, c := range changes {
+			s.rows = append(s.rows, row(c))
+		}
+		if len(s.rows) > 0 {
+			sections = append(sections, s)
+		}
+	}
+	add(diffSection{title: "Downgraded", warn: true, header: []string{"Module", "Old", "New", "Downgrade"}}, r.Downgraded, func(c moddiff.Change) []string {
+		return []string{module(c), c.Old, c.New, string(c.Delta)}
+	})
+	add(diffSection{title: "Checksum changed", warn: true, header: []string{"Module", "Old", "New"}}, r.Rehashed, func(c moddiff.Change) []string {
+		return []string{c.Path, c.Old, c.New}
+	})
+	add(diffSection{title: "Added", header: []string{"Module", "Version"}}, r.Added, func(c moddiff.Change) []string {
+		return []string{module(c), c.New}
+	})
+	add(diffSection{title: "Removed", header: []string{"Module", "Version"}}, r.Removed, func(c moddiff.Change) []string {
+		return []string{module(c), c.Old}
+	})
+	add(diffSection{title: "Upgraded", header: []string{"Module", "Old", "New", "Update"}}, r.Upgraded, func(c moddiff.Change) []string {
+		return []string{module(c), c.Old, c.New, string(c.Delta)}
+	})
+	add(diffSection{title: "Replaced", header: []string{"Module", "Old", "New"}}, r.Replaced, func(c moddiff.Change) []string {
+		return []string{module(c), orNone(c.Old), orNone(c.New)}
+	})
+	return sections
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func printDiffText(out io.Writer, from, to string, sections []diffSection) {
+	if len(sections) == 0 {
+		fmt.Fprintf(out, "no dependency changes between %s and %s\n", gitrev.Name(from), gitrev.Name(to))
+		return
+	}
+	for i, s := range sections {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		title := fmt.Sprintf("%s (%d):", s.title, len(s.rows))
+		if s.warn {
+			title = fmt.Sprintf("%s (%d), review carefully:", strings.ToUpper(s.title), len(s.rows))
+		}
+		fmt.Fprintln(out, title)
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "  %s\n", strings.ToUpper(strings.Join(s.header, "\t")))
+		for _, row := range s.rows {
+			fmt.Fprintf(tw, "  %s\n", strings.Join(row, "\t"))
+		}
+		tw.Flush()
+	}
+}
+
+// printDiffMarkdown prints the sections as markdown tables, to be pasted
+// into a pull request description.
+func printDiffMarkdown(out io.Writer, from, to string, sections []diffSection) {
+	fmt.Fprintf(out, "### Dependency changes from `%s` to %s\n\n", from, markdownRev(to))
+	if len(sections) == 0 {
+		fmt.Fprintln(out, "No dependency changes.")
+		return
+	}
+	for _, s := range sections {
+		title := s.title
+		if s.warn {
+			title = ":warning: " + title
+		}
+		fmt.Fprintf(out, "#### %s (%d)\n\n", title, len(s.rows))
+		fmt.Fprintf(out, "| %s |\n", strings.Join(s.header, " | "))
+		fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", len(s.header)))
+		for _, row := range s.rows {
+			cells := make([]string, len(row))
+			for i, c := range row {
+				cells[i] = strings.ReplaceAll(c, "|", `\|`)
+				if c != "" && i > 0 {
+					cells[i] = "`" + cells[i] + "`"
+				}
+				if s.warn {
+					cells[i] = "**" + cells[i] + "**"
+				}
+			}
+			fmt.Fprintf(out, "| %s |\n", strings.Join(cells, " | "))
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// markdownRev formats rev for the markdown heading.
+func markdownRev(rev string) string {
+	if rev == gitrev.WorkingTree {
+		return gitrev.Name(rev)
+	}
+	return "`" + rev + "`"
+}
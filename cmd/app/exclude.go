@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/pin"
+)
+
+func newExcludeCmd() *cobra.Command {
+	var (
+		file   string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "exclude",
+		Short: "Add or remove exclude directives, which keep the go command from selecting a version",
+	}
+	cmd.PersistentFlags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print a diff of the go.mod instead of writing it")
+
+	run := func(cmd *cobra.Command, add, remove []module.Version) error {
+		res, err := pin.PlanExclude(file, add, remove)
+		if err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := applyResult(cmd, res); err != nil {
+				return err
+			}
+		}
+		return reportChange(res, dryRun, printExcludeSummary)
+	}
+
+	add := &cobra.Command{
+		Use:   "add <module>@<version>...",
+		Short: "Exclude module versions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mods, err := parseExcludes(args, true)
+			if err != nil {
+				return err
+			}
+			return run(cmd, mods, nil)
+		},
+	}
+	remove := &cobra.Command{
+		Use:   "remove <module>[@<version>]...",
+		Short: "Remove exclusions, of every version of a module given without one",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mods, err := parseExcludes(args, false)
+			if err != nil {
+				return err
+			}
+			return run(cmd, nil, mods)
+		},
+	}
+
+	cmd.AddCommand(add, remove)
+	return cmd
+}
+
+// parseExcludes parses module@version arguments; the version is optional
+// unless needVersion is set.
+func parseExcludes(args []string, needVersion bool) ([]module.Version, error) {
+	var mods []module.Version
+	for _, a := range args {
+		path, version, ok := strings.Cut(a, "@")
+		if path == "" || ok && version == "" || needVersion && !ok {
+			if needVersion {
+				return nil, usageErrorf("invalid argument %q, expected module@version", a)
+			}
+			return nil, usageErrorf("invalid argument %q, expected module or module@version", a)
+		}
+		mods = append(mods, module.Version{Path: path, Version: version})
+	}
+	return mods, nil
+}
+
+func printExcludeSummary(out io.Writer, res *pin.Result) {
+	for _, c := range res.Added {
+		fmt.Fprintf(out, "  + exclude %s %s\n", c.Path, c.New)
+	}
+	for _, c := range res.Removed {
+		fmt.Fprintf(out, "  - exclude %s %s\n", c.Path, c.Old)
+	}
+	if !res.Modified() {
+		fmt.Fprintf(out, "%s: exclusions unchanged\n", res.File)
+		return
+	}
+	fmt.Fprintf(out, "%s: %d added, %d removed\n", res.File, len(res.Added), len(res.Removed))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/match"
+)
+
+// filterFlags are the module selection flags of pin, update, outdated,
+// check and list.
+type filterFlags struct {
+	match.Filter
+}
+
+func (f *filterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.Only, "only", nil, "only select modules matching this path pattern, where ... matches any string; can be repeated")
+	cmd.Flags().StringArrayVar(&f.Exclude, "exclude", nil, "leave out modules matching this path pattern, even if --only matches them; can be repeated")
+	cmd.Flags().BoolVar(&f.DirectOnly, "direct-only", false, "only select direct requirements")
+	cmd.Flags().BoolVar(&f.IndirectOnly, "indirect-only", false, "only select indirect requirements")
+	cmd.MarkFlagsMutuallyExclusive("direct-only", "indirect-only")
+}
+
+// selector returns a function reporting whether the flags select a module
+// of the go.mod m, or nil if they select every module. Modules m does not
+// require count as indirect, since pin would add them as indirect
+// requirements.
+func (f *filterFlags) selector(m *gomod.Module) func(path string) bool {
+	if f.Empty() {
+		return nil
+	}
+	direct := make(map[string]bool)
+	for _, r := range m.Requires() {
+		direct[r.Path] = !r.Indirect
+	}
+	fn := f.Matcher()
+	return func(path string) bool { return fn(path, !direct[path]) }
+}
+
+// paths returns the paths among args that the flags select or, without
+// args, the paths of all selected requirements of m that are not replaced.
+// It fails if nothing is selected.
+func (f *filterFlags) paths(m *gomod.Module, args []string) ([]string, error) {
+	sel := f.selector(m)
+	var paths []string
+	if len(args) > 0 {
+		for _, p := range args {
+			if sel(p) {
+				paths = append(paths, p)
+			}
+		}
+	} else {
+		for _, r := range m.Requires() {
+			if r.Replace == nil && sel(r.Path) {
+				paths = append(paths, r.Path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%s: no requirement is selected by --only, --exclude, --direct-only or --indirect-only", m.Filename)
+	}
+	return paths, nil
+}
+
+// excluding returns a function reporting whether a module is matched by
+// exclude or not selected by sel; either may be nil.
+func excluding(exclude, sel func(string) bool) func(string) bool {
+	if sel == nil {
+		return exclude
+	}
+	return func(path string) bool {
+		return exclude != nil && exclude(path) || !sel(path)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/generate"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// generateFixResult is the JSON result of scan-generate --fix, like
+// scanFixResult.
+type generateFixResult struct {
+	Findings []generate.Finding `json:"findings"`
+	Pinned   map[string]string  `json:"pinned"`
+}
+
+func newScanGenerateCmd() *cobra.Command {
+	var (
+		file        string
+		format      string
+		fix         bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scan-generate [packages...]",
+		Short: "Find go:generate directives running tools with go run at a floating version",
+		Long: `Find the go:generate directives of the given packages that run a remote tool
+with go run at @latest, at a branch or another version query, or without a
+version when the main module does not require the tool. Packages are
+directories, recursive when they end in /..., by default the whole module of
+--file. Files of every build constraint are scanned, and aliases defined with
+go:generate -command are followed.
+
+With --fix, each directive is rewritten in place to the latest tagged
+release of the module providing the tool; only the version changes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "text", "json"); err != nil {
+				return err
+			}
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			findings, err := generate.Walk(m, args)
+			if err != nil {
+				return err
+			}
+			if findings == nil {
+				findings = []generate.Finding{}
+			}
+
+			if !fix {
+				err := rep.Result(findings, func(out io.Writer) error {
+					if format == "json" {
+						return writeJSON(out, findings)
+					}
+					for _, f := range findings {
+						fmt.Fprintf(out, "%s:%d:%d: go run %s (%s)\n", f.File, f.Line, f.Column, f.Argument(), generateReason(f))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				if len(findings) > 0 {
+					return &exitError{code: report.ExitViolations}
+				}
+				return nil
+			}
+
+			var pkgs []string
+			for _, f := range findings {
+				if f.Fixable() {
+					pkgs = append(pkgs, f.Package)
+				} else {
+					slog.Warn("cannot rewrite quoted argument with escapes", "file", f.File, "line", f.Line, "package", f.Package)
+				}
+			}
+			pinned, resolveErr := pinTools(cmd.Context(), pkgs, concurrency)
+			byFile := make(map[string][]generate.Finding)
+			var files []string
+			for _, f := range findings {
+				if byFile[f.File] == nil {
+					files = append(files, f.File)
+				}
+				byFile[f.File] = append(byFile[f.File], f)
+			}
+			for _, name := range files {
+				if err := generate.Fix(name, byFile[name], pinned); err != nil {
+					return err
+				}
+			}
+			res := generateFixResult{Findings: findings, Pinned: pinned}
+			err = rep.Result(res, func(out io.Writer) error {
+				for _, f := range findings {
+					if v, ok := pinned[f.Package]; ok && f.Fixable() {
+						fmt.Fprintf(out, "%s:%d:%d: %s -> %s@%s\n", f.File, f.Line, f.Column, f.Argument(), f.Package, v)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return resolveErr
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file of the main module")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text or json")
+	cmd.Flags().BoolVar(&fix, "fix", false, "rewrite each directive to the latest tagged version of its module")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy requests")
+	return cmd
+}
+
+// generateReason describes why the version of f floats.
+func generateReason(f generate.Finding) string {
+	reason := f.Reason
+	if f.Alias != "" {
+		reason += ", through -command " + f.Alias
+	}
+	return reason
+}
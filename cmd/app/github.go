@@ -0,0 +1,18 @@
+package main
+
+import "pin-go-dependencies/internal/ghactions"
+
+// githubOutput reports whether --output selects GitHub Actions
+// annotations. Outside of a workflow, github falls back to the plain output.
+func githubOutput() bool {
+	return outputFlag == outputGitHub && ghactions.Enabled()
+}
+
+// annotationLevel returns the level of an annotation for a finding that
+// makes the command fail or merely reports.
+func annotationLevel(fails bool) string {
+	if fails {
+		return ghactions.LevelError
+	}
+	return ghactions.LevelWarning
+}
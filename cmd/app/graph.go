@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/modgraph"
+)
+
+func newGraphCmd() *cobra.Command {
+	var (
+		file        string
+		format      string
+		focus       string
+		depth       int
+		allVersions bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Print the module requirement graph",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "dot", "json", "mermaid"); err != nil {
+				return err
+			}
+			g, err := modgraph.Load(cmd.Context(), filepath.Dir(file))
+			if err != nil {
+				return err
+			}
+			if !allVersions {
+				g = g.Pinned()
+			}
+			if depth > 0 {
+				g = g.Limit(depth)
+			}
+			if focus != "" {
+				if _, ok := g.Selected()[focus]; !ok {
+					return fmt.Errorf("%s is not in the module graph", focus)
+				}
+				g = g.Focus(focus)
+			}
+
+			return rep.Result(g.Adjacency(), func(out io.Writer) error {
+				switch format {
+				case "json":
+					return writeJSON(out, g.Adjacency())
+				case "mermaid":
+					return g.WriteMermaid(out)
+				}
+				return g.WriteDOT(out)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&format, "format", "dot", "output format: dot, json or mermaid")
+	cmd.Flags().StringVar(&focus, "focus", "", "only show the paths from the main module to this module")
+	cmd.Flags().IntVar(&depth, "depth", 0, "only show modules at most this many requirements away from the main module (0 for no limit)")
+	cmd.Flags().BoolVar(&allVersions, "all-versions", false, "show every module version in the graph, not only the selected ones")
+	return cmd
+}
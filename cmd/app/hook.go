@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/githook"
+)
+
+// Hooks installed by hook install.
+const (
+	hookPreCommit = "pre-commit"
+	hookPrePush   = "pre-push"
+)
+
+// hookResult is the JSON result of hook install and hook uninstall, one per
+// hook.
+type hookResult struct {
+	Hook string `json:"hook"`
+	File string `json:"file"`
+	// Changed is set if the managed block was written or removed.
+	Changed bool `json:"changed"`
+}
+
+func newHookCmd() *cobra.Command {
+	var preCommit, prePush bool
+
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Install git hooks that run check before commits or pushes",
+	}
+	cmd.PersistentFlags().BoolVar(&preCommit, "pre-commit", false, "the pre-commit hook")
+	cmd.PersistentFlags().BoolVar(&prePush, "pre-push", false, "the pre-push hook")
+
+	// hooks returns the names and paths of the selected hooks. Without
+	// --pre-commit or --pre-push, those are both hooks if all is set and
+	// pre-commit otherwise.
+	hooks := func(all bool) ([]string, []string, error) {
+		dir, err := githook.Dir(".")
+		if err != nil {
+			return nil, nil, err
+		}
+		none := !preCommit && !prePush
+		var names []string
+		if preCommit || none {
+			names = append(names, hookPreCommit)
+		}
+		if prePush || none && all {
+			names = append(names, hookPrePush)
+		}
+		files := make([]string, len(names))
+		for i, n := range names {
+			files[i] = filepath.Join(dir, n)
+		}
+		return names, files, nil
+	}
+
+	var (
+		file  string
+		force bool
+	)
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Install a hook running check --quiet, pre-commit unless --pre-push is set",
+		Long: `Install a git hook that runs check --quiet on the go.mod and stops the commit
+or push if it fails. A hook that exists already gets a block between marker
+comments, which install updates and uninstall removes; a hook this tool did
+not install is only appended to with --force.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			top, err := githook.TopLevel(".")
+			if err != nil {
+				return err
+			}
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+			if top, err = filepath.EvalSymlinks(top); err != nil {
+				return err
+			}
+			if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+				abs = filepath.Join(dir, filepath.Base(abs))
+			}
+			rel, err := filepath.Rel(top, abs)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return usageErrorf("%s is outside of the repository at %s", file, top)
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			if temporaryBuild(exe) {
+				return fmt.Errorf("%s is a temporary build of go run, removed when it exits; install the tool with go install and run hook install with the installed binary", exe)
+			}
+			names, files, err := hooks(false)
+			if err != nil {
+				return err
+			}
+			var results []hookResult
+			for i, name := range names {
+				if err := githook.Install(files[i], hookBlock(name, exe, filepath.ToSlash(rel)), force); err != nil {
+					return err
+				}
+				results = append(results, hookResult{Hook: name, File: files[i], Changed: true})
+			}
+			return rep.Result(results, func(out io.Writer) error {
+				for _, r := range results {
+					fmt.Fprintf(out, "installed %s hook in %s\n", r.Hook, r.File)
+				}
+				return nil
+			})
+		},
+	}
+	install.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file the hook checks")
+	install.Flags().BoolVar(&force, "force", false, "append to an existing hook this tool did not install")
+
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove what hook install added, from both hooks unless one is selected",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, files, err := hooks(true)
+			if err != nil {
+				return err
+			}
+			var results []hookResult
+			for i, name := range names {
+				removed, err := githook.Uninstall(files[i])
+				if err != nil {
+					return err
+				}
+				results = append(results, hookResult{Hook: name, File: files[i], Changed: removed})
+			}
+			return rep.Result(results, func(out io.Writer) error {
+				for _, r := range results {
+					if r.Changed {
+						fmt.Fprintf(out, "removed %s hook from %s\n", r.Hook, r.File)
+					} else {
+						fmt.Fprintf(out, "%s: nothing installed by hook install\n", r.File)
+					}
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.AddCommand(install, uninstall)
+	return cmd
+}
+
+// hookBlock returns the commands the hook name runs: check --quiet on file,
+// relative to the root of the working tree where git runs hooks, with the
+// executable exe, or app on the PATH if exe is gone. A missing binary is
+// reported apart from a failed check.
+func hookBlock(name, exe, file string) string {
+	return fmt.Sprintf(`# Installed by "app hook install"; remove it with "app hook uninstall".
+pin_exe=%s
+[ -x "$pin_exe" ] || pin_exe=$(command -v app) || {
+	echo %s >&2
+	exit 1
+}
+"$pin_exe" check --quiet --file %s || {
+	echo "%s: check of %s failed; run \"app check --file %s\" for details" >&2
+	exit 1
+}
+`, shellQuote(exe), shellQuote(fmt.Sprintf(`%s: binary %s not found, nor app on the PATH; run "app hook install" again`, name, exe)), shellQuote(file), name, file, file)
+}
+
+// temporaryBuild reports whether exe was built by go run, which runs the
+// binary from a go-build directory of the temporary directory and removes
+// it when the program exits.
+func temporaryBuild(exe string) bool {
+	return strings.Contains(filepath.ToSlash(exe), "/go-build") && filepath.Base(filepath.Dir(exe)) == "exe"
+}
+
+// shellQuote quotes s as a single word for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
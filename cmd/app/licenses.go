@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/audit"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/licenses"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newLicensesCmd() *cobra.Command {
+	var (
+		file         string
+		deny         []string
+		unknownFails bool
+		format       string
+		concurrency  int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "licenses",
+		Short: "Report the licenses of the build list",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "text", "csv", "json"); err != nil {
+				return err
+			}
+			deny, _ = listSetting(cmd, "deny", deny, cfg.Licenses.Deny)
+			unknownFails = unknownFails || cfg.Licenses.UnknownFails
+
+			mods, err := audit.Modules(cmd.Context(), file)
+			if err != nil {
+				return err
+			}
+			f, err := newLicenseFinder()
+			if err != nil {
+				return err
+			}
+			found, lookupErr := f.Find(cmd.Context(), mods, concurrency, failFast)
+			if found == nil {
+				found = []licenses.License{}
+			}
+			err = rep.Result(found, func(out io.Writer) error {
+				switch format {
+				case "json":
+					return writeJSON(out, found)
+				case "csv":
+					return writeLicensesCSV(out, found)
+				}
+				return printLicenses(out, found)
+			})
+			if err != nil {
+				return err
+			}
+			if lookupErr != nil {
+				return lookupErr
+			}
+
+			var failed []string
+			for _, l := range found {
+				if denied := deniedLicenses(l, deny); len(denied) > 0 {
+					failed = append(failed, fmt.Sprintf("%s@%s: denied license %s", l.Path, l.Version, strings.Join(denied, ", ")))
+				} else if unknownFails && l.Unknown() {
+					failed = append(failed, fmt.Sprintf("%s@%s: unknown license", l.Path, l.Version))
+				}
+			}
+			if len(failed) > 0 {
+				return &exitError{code: report.ExitViolations, err: fmt.Errorf("%s", strings.Join(failed, "\n"))}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringSliceVar(&deny, "deny", nil, "comma-separated SPDX IDs of licenses that make the command exit with 1, such as GPL-3.0,AGPL-3.0")
+	cmd.Flags().BoolVar(&unknownFails, "unknown-fails", false, "exit with 1 if a license cannot be classified")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text, csv or json")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module downloads")
+	return cmd
+}
+
+// newLicenseFinder returns a finder reading modules from the module cache
+// and the module proxy, caching its results next to the proxy cache unless
+// caching is disabled.
+func newLicenseFinder() (*licenses.Finder, error) {
+	env, err := gocmd.Env("GOMODCACHE")
+	if err != nil {
+		return nil, err
+	}
+	c, err := newProxyClient()
+	if err != nil {
+		return nil, err
+	}
+	f := &licenses.Finder{Proxy: c, ModCache: env["GOMODCACHE"]}
+	if disabled, _ := noCache(); !disabled {
+		dir, err := proxy.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		f.CacheDir = filepath.Join(dir, "licenses")
+	}
+	return f, nil
+}
+
+// deniedLicenses returns the licenses of l that are in deny. SPDX IDs are
+// compared case-insensitively.
+func deniedLicenses(l licenses.License, deny []string) []string {
+	var denied []string
+	for _, id := range l.IDs {
+		for _, d := range deny {
+			if strings.EqualFold(id, strings.TrimSpace(d)) {
+				denied = append(denied, id)
+				break
+			}
+		}
+	}
+	return denied
+}
+
+func printLicenses(out io.Writer, list []licenses.License) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tVERSION\tLICENSE\tCONFIDENCE")
+	for _, l := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f%%\n", l.Path, l.Version, strings.Join(l.IDs, ", "), l.Confidence)
+	}
+	return tw.Flush()
+}
+
+func writeLicensesCSV(out io.Writer, list []licenses.License) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"module", "version", "license", "confidence", "files"})
+	for _, l := range list {
+		w.Write([]string{l.Path, l.Version, strings.Join(l.IDs, " "), fmt.Sprintf("%.1f", l.Confidence), strings.Join(l.Files, " ")})
+	}
+	w.Flush()
+	return w.Error()
+}
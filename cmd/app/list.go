@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/render"
+	"pin-go-dependencies/internal/tools"
+	"pin-go-dependencies/internal/versions"
+)
+
+// listEntry is one row of the list output. The JSON and YAML field names are
+// part of the command's interface.
+type listEntry struct {
+	Path       string  `json:"path" yaml:"path"`
+	Version    string  `json:"version" yaml:"version"`
+	Indirect   bool    `json:"indirect" yaml:"indirect"`
+	ReplacedBy *string `json:"replacedBy" yaml:"replacedBy"`
+	// Effective is what the build actually uses: the replacement if there
+	// is one, path@version otherwise.
+	Effective string `json:"effective" yaml:"effective"`
+	// AboveExcluded is the excluded version that Version directly follows,
+	// which usually makes the requirement a deliberate pin past a bad
+	// release.
+	AboveExcluded *string `json:"aboveExcluded,omitempty" yaml:"aboveExcluded,omitempty"`
+}
+
+func newListCmd() *cobra.Command {
+	var (
+		file   string
+		format string
+		tools  bool
+		filter filterFlags
+		tf     templateFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the modules required by go.mod",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries := []listEntry{}
+			if tf.help {
+				if tools {
+					return describeToolsTemplate()
+				}
+				return describeTemplate(entries, `{{range .}}{{padRight 40 .Path}} {{shortSHA .Version}}{{"\n"}}{{end}}`)
+			}
+			if err := checkFormat(format, "table", "json", "yaml"); err != nil {
+				return err
+			}
+			t, err := tf.parse(cmd)
+			if err != nil {
+				return err
+			}
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			if tools {
+				return listTools(m, format, t)
+			}
+
+			sel := filter.selector(m)
+			for _, r := range m.Requires() {
+				if sel != nil && !sel(r.Path) {
+					continue
+				}
+				e := listEntry{Path: r.Path, Version: r.Version, Indirect: r.Indirect, Effective: r.Path + "@" + r.Version}
+				if r.Replace != nil {
+					s := r.Replace.String()
+					e.ReplacedBy = &s
+					e.Effective = s
+				}
+				e.AboveExcluded = aboveExcluded(m, r)
+				entries = append(entries, e)
+			}
+
+			return rep.Result(entries, func(out io.Writer) error {
+				if t != nil {
+					return t.Execute(out, entries)
+				}
+				switch format {
+				case "json":
+					return writeJSON(out, entries)
+				case "yaml":
+					return writeYAML(out, entries)
+				}
+				notes := false
+				for _, e := range entries {
+					notes = notes || e.AboveExcluded != nil
+				}
+				tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				if notes {
+					fmt.Fprintln(tw, "MODULE\tVERSION\tTYPE\tREPLACED BY\tEFFECTIVE\tNOTE")
+				} else {
+					fmt.Fprintln(tw, "MODULE\tVERSION\tTYPE\tREPLACED BY\tEFFECTIVE")
+				}
+				for _, e := range entries {
+					kind := "direct"
+					if e.Indirect {
+						kind = "indirect"
+					}
+					replacedBy := "-"
+					if e.ReplacedBy != nil {
+						replacedBy = *e.ReplacedBy
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s", e.Path, e.Version, kind, replacedBy, e.Effective)
+					if e.AboveExcluded != nil {
+						fmt.Fprintf(tw, "\tabove excluded %s", *e.AboveExcluded)
+					}
+					fmt.Fprintln(tw)
+				}
+				return tw.Flush()
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: table, json or yaml")
+	cmd.Flags().BoolVar(&tools, "tools", false, "list the tools of tool directives and tools.go files instead")
+	filter.register(cmd)
+	tf.register(cmd)
+	return cmd
+}
+
+// aboveExcluded returns the version excluded by m that the version of r is
+// the next release after, or nil.
+func aboveExcluded(m *gomod.Module, r gomod.Require) *string {
+	for _, x := range m.File.Exclude {
+		if x.Mod.Path == r.Path && versions.NextRelease(x.Mod.Version, r.Version) {
+			v := x.Mod.Version
+			return &v
+		}
+	}
+	return nil
+}
+
+// describeToolsTemplate prints the data model of list --tools templates.
+func describeToolsTemplate() error {
+	return describeTemplate([]tools.Tool{}, `{{range .}}{{padRight 40 .Path}} {{.Module}}{{"\n"}}{{end}}`)
+}
+
+// listTools prints the tools of m and the modules providing them, through t
+// if it is not nil.
+func listTools(m *gomod.Module, format string, t *render.Template) error {
+	ts, err := tools.Find(m)
+	if err != nil {
+		return err
+	}
+	if ts == nil {
+		ts = []tools.Tool{}
+	}
+	return rep.Result(ts, func(out io.Writer) error {
+		if t != nil {
+			return t.Execute(out, ts)
+		}
+		switch format {
+		case "json":
+			return writeJSON(out, ts)
+		case "yaml":
+			return writeYAML(out, ts)
+		}
+		dash := func(s string) string {
+			if s == "" {
+				return "-"
+			}
+			return s
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TOOL\tSOURCE\tMODULE\tVERSION")
+		for _, t := range ts {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.Path, t.Source, dash(t.Module), dash(t.Version))
+		}
+		return tw.Flush()
+	})
+}
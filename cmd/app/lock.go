@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/lockfile"
+	"pin-go-dependencies/internal/pin"
+)
+
+// lockPath resolves the --lock flag: a plain file name is taken relative to
+// the directory of the go.mod file.
+func lockPath(file, lock string) string {
+	if filepath.Base(lock) == lock {
+		return filepath.Join(filepath.Dir(file), lock)
+	}
+	return lock
+}
+
+// freezeResult is the JSON result of the freeze command.
+type freezeResult struct {
+	Lock    string `json:"lock"`
+	Modules int    `json:"modules"`
+}
+
+func newFreezeCmd() *cobra.Command {
+	var (
+		file string
+		lock string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "freeze",
+		Short: "Record the current resolution in " + lockfile.FileName,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			l, err := lockfile.Capture(cmd.Context(), file)
+			if err != nil {
+				return err
+			}
+			name := lockPath(file, lock)
+			if err := fsutil.ReplaceFile(name, l.Format()); err != nil {
+				return err
+			}
+			res := freezeResult{Lock: name, Modules: len(l.Entries)}
+			return rep.Result(res, func(out io.Writer) error {
+				_, err := fmt.Fprintf(out, "%s: %d modules locked\n", name, len(l.Entries))
+				return err
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&lock, "lock", lockfile.FileName, "lock file to write")
+	return cmd
+}
+
+func newRestoreCmd() *cobra.Command {
+	var (
+		file   string
+		lock   string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Rewrite go.mod and go.sum to the resolution in " + lockfile.FileName,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := lockPath(file, lock)
+			l, err := lockfile.Read(name)
+			if err != nil {
+				return err
+			}
+			res, err := pin.PlanRestore(cmd.Context(), file, l)
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				if err := applyResult(cmd, res); err != nil {
+					return err
+				}
+			}
+			return reportChange(res, dryRun, func(out io.Writer, res *pin.Result) {
+				if !res.Modified() {
+					fmt.Fprintf(out, "%s: already matches %s\n", res.File, name)
+					return
+				}
+				printPinSummary(out, newChangeResult(res, false))
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&lock, "lock", lockfile.FileName, "lock file to restore")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of the pending changes instead of writing them")
+	return cmd
+}
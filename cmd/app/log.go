@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/spf13/pflag"
+)
+
+// logFlags configures the diagnostic logs of all subcommands.
+var logFlags struct {
+	verbosity int
+	format    string
+}
+
+func addLogFlags(fs *pflag.FlagSet) {
+	fs.CountVarP(&logFlags.verbosity, "verbose", "v", "log the decisions of the tool to standard error; -vv also logs every request and go command")
+	fs.StringVar(&logFlags.format, "log-format", "text", "format of the logs: text or json")
+}
+
+// setupLogging directs the default logger to w, which is standard error so
+// that logs never mix with the results on standard output. Only warnings are
+// logged by default, -v adds the selected versions and -vv every rejected
+// candidate, HTTP request and go command.
+func setupLogging(w io.Writer) error {
+	level := slog.LevelWarn
+	switch {
+	case logFlags.verbosity == 1:
+		level = slog.LevelInfo
+	case logFlags.verbosity > 1:
+		level = slog.LevelDebug
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch logFlags.format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return usageErrorf("invalid --log-format %q, expected text or json", logFlags.format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
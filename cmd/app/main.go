@@ -1,21 +1,171 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/config"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/report"
+)
+
+var (
+	// timeoutFlag is the global --timeout flag; runCtx is the context of
+	// the run, which it bounds and which carries --offline for the go
+	// command, and cancelRun releases it.
+	timeoutFlag time.Duration
+	runCtx      = context.Background()
+	cancelRun   = func() {}
+	// failFast is the global --fail-fast flag.
+	failFast bool
+	// statsFlag is the global --stats flag; recorder collects the
+	// statistics of the run if it is set.
+	statsFlag bool
+	recorder  *metrics.Recorder
 )
 
+// prepare runs before every subcommand: it checks the flags, sets up the
+// output and the logs, bounds the run by --timeout, applies --offline to
+// the go command, the request settings to the network clients and
+// --fail-fast to the lookups of modules, starts collecting statistics for
+// --stats, and loads the configuration.
+func prepare(cmd *cobra.Command, args []string) error {
+	switch outputFlag {
+	case outputPlain, outputJSON, outputGitHub:
+	default:
+		if cmd.Name() == "sbom" {
+			// sbom named its file --output before the global flag existed.
+			return usageErrorf("invalid --output %q: the file of the SBOM is now set with --out", outputFlag)
+		}
+		return usageErrorf("invalid --output %q, expected plain, json or github", outputFlag)
+	}
+	if err := validateFlags(cmd); err != nil {
+		return err
+	}
+	if err := setupLogging(cmd.ErrOrStderr()); err != nil {
+		return err
+	}
+	rep = report.New(cmd.OutOrStdout(), cmd.ErrOrStderr(), outputFlag == outputJSON)
+	if timeoutFlag < 0 || proxyFlags.requestTimeout < 0 || proxyFlags.retries < 0 {
+		return usageErrorf("--timeout, --request-timeout and --retries cannot be negative")
+	}
+	runCtx = cmd.Context()
+	if timeoutFlag > 0 {
+		runCtx, cancelRun = context.WithTimeout(runCtx, timeoutFlag)
+	}
+	runCtx = gocmd.WithOffline(runCtx, proxyFlags.offline)
+	cmd.SetContext(runCtx)
+	httpx.Timeout = proxyFlags.requestTimeout
+	httpx.Retries = proxyFlags.retries
+	if statsFlag {
+		recorder = metrics.NewRecorder()
+		metrics.Default = recorder
+	}
+	return loadConfig(cmd, args)
+}
+
 func main() {
 	var rootCmd = &cobra.Command{
-		Use:   "app",
-		Short: "MyApp",
+		Use:               "app",
+		Short:             "MyApp",
+		Args:              cobra.NoArgs,
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+		PersistentPreRunE: prepare,
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("Hallo from MyApp!")
+			fmt.Fprintln(rep.Text(), "Hallo from MyApp!")
 		},
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "configuration file to use instead of the closest "+config.FileName)
+	rootCmd.PersistentFlags().StringVar(&outputFlag, "output", outputPlain, "output mode: plain; json for a single JSON document on standard output; github for workflow annotations in GitHub Actions")
+	rootCmd.PersistentFlags().BoolVar(&statsFlag, "stats", false, "print counters and timings of the run to standard error, or include them in the JSON output")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 0, "abort the run if it takes longer than this, such as 10m; 0 for no limit")
+	rootCmd.PersistentFlags().BoolVar(&failFast, "fail-fast", false, "stop at the first module that fails instead of reporting all failures at the end")
+	addProxyFlags(rootCmd.PersistentFlags())
+	addLogFlags(rootCmd.PersistentFlags())
+	rootCmd.SetFlagErrorFunc(flagUsage)
+
+	rootCmd.AddCommand(newPinCmd())
+	rootCmd.AddCommand(newListCmd())
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newOutdatedCmd())
+	rootCmd.AddCommand(newUpdateCmd())
+	rootCmd.AddCommand(newCacheCmd())
+	rootCmd.AddCommand(newVerifyCmd())
+	rootCmd.AddCommand(newSBOMCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newConfigCmd())
+	rootCmd.AddCommand(newGraphCmd())
+	rootCmd.AddCommand(newWhyCmd())
+	rootCmd.AddCommand(newFreezeCmd())
+	rootCmd.AddCommand(newRestoreCmd())
+	rootCmd.AddCommand(newUndoCmd())
+	rootCmd.AddCommand(newScanScriptsCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newLicensesCmd())
+	rootCmd.AddCommand(newPruneCmd())
+	rootCmd.AddCommand(newVendorCmd())
+	rootCmd.AddCommand(newSumCmd())
+	rootCmd.AddCommand(newReportCmd())
+	rootCmd.AddCommand(newHookCmd())
+	rootCmd.AddCommand(newExcludeCmd())
+	rootCmd.AddCommand(newDedupCmd())
+	rootCmd.AddCommand(newScanGenerateCmd())
+	argsUsage(rootCmd)
+
+	// The first SIGINT or SIGTERM cancels the run: requests and go commands
+	// are aborted, and nothing is written after that. A second one kills
+	// the process right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	cancelRun()
+	if err != nil {
+		err = runCancelled(ctx, err)
+	}
+	code := exitCode(err)
+	if outputFlag == outputJSON && !rep.JSON() {
+		// The flags were rejected before prepare could set up the output.
+		rep = report.New(os.Stdout, os.Stderr, true)
+	}
+	if recorder != nil && rep.JSON() {
+		rep.SetStats(recorder.Stats())
+	}
+	if rep.JSON() {
+		if err := rep.Finish(commandName(cmd), code, envelopeErrors(err)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	} else {
+		printErrors(os.Stderr, err)
+		if recorder != nil {
+			printStats(os.Stderr, recorder.Stats())
+		}
+	}
+	os.Exit(code)
+}
+
+// runCancelled replaces err, the error of a run, by the reason the run was
+// cancelled if it was, since every failure after that stems from it: an
+// interruption by sig, whose context is done then, or --timeout.
+func runCancelled(sig context.Context, err error) error {
+	switch {
+	case sig.Err() != nil:
+		return &exitError{code: report.ExitInterrupted, err: errors.New("interrupted")}
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		return &exitError{code: report.ExitNetwork, err: fmt.Errorf("run exceeded --timeout %s", timeoutFlag)}
 	}
+	return err
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/ghactions"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/outdated"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/versions"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newOutdatedCmd() *cobra.Command {
+	var (
+		file        string
+		majorOnly   bool
+		filter      filterFlags
+		failOn      string
+		concurrency int
+		tf          templateFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "List newer versions of the required modules",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if tf.help {
+				return describeTemplate(&outdated.Report{}, `{{range .Updates}}{{padRight 40 .Path}} {{.Current}} -> {{.Latest}} ({{.Delta}}){{"\n"}}{{end}}`)
+			}
+			t, err := tf.parse(cmd)
+			if err != nil {
+				return err
+			}
+			var threshold versions.Delta
+			switch failOn {
+			case "":
+			case "patch", "minor", "major":
+				threshold = versions.Delta(failOn)
+			default:
+				return usageErrorf("invalid --fail-on %q, expected patch, minor or major", failOn)
+			}
+
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			var reqs []gomod.Require
+			sel := filter.selector(m)
+			for _, r := range m.Requires() {
+				if sel == nil || sel(r.Path) {
+					reqs = append(reqs, r)
+				}
+			}
+			if err := requireNetwork(cmd, "compares against the newest versions published"); err != nil {
+				return err
+			}
+			c, err := newProxyClient()
+			if err != nil {
+				return err
+			}
+			found, lookupErr := outdated.Find(cmd.Context(), c, reqs, concurrency, failFast)
+
+			res := &outdated.Report{Updates: []outdated.Update{}, Majors: found.Majors}
+			for _, u := range found.Updates {
+				if !majorOnly || u.Delta == versions.Major {
+					res.Updates = append(res.Updates, u)
+				}
+			}
+			err = rep.Result(res, func(out io.Writer) error {
+				if t != nil {
+					return t.Execute(out, res)
+				}
+				if githubOutput() {
+					return annotateOutdated(out, file, res.Updates, res.Majors, threshold)
+				}
+				printOutdated(out, res.Updates, res.Majors)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if lookupErr != nil {
+				return lookupErr
+			}
+			if threshold != versions.None && exceeds(res.Updates, res.Majors, threshold) {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().BoolVar(&majorOnly, "major-only", false, "only show major version updates")
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "exit with 1 if there are updates of at least this kind: patch, minor or major")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy requests")
+	filter.register(cmd)
+	tf.register(cmd)
+	return cmd
+}
+
+// exceeds reports whether any update is at least as severe as threshold.
+// Upgrades to a new major module path always count as major.
+func exceeds(updates []outdated.Update, majors []outdated.MajorUpgrade, threshold versions.Delta) bool {
+	if len(majors) > 0 {
+		return true
+	}
+	for _, u := range updates {
+		if u.Delta.Rank() >= threshold.Rank() {
+			return true
+		}
+	}
+	return false
+}
+
+func printOutdated(out io.Writer, updates []outdated.Update, majors []outdated.MajorUpgrade) {
+	if len(updates) == 0 && len(majors) == 0 {
+		fmt.Fprintln(out, "all modules are up to date")
+		return
+	}
+	if len(updates) > 0 {
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "MODULE\tCURRENT\tLATEST\tUPDATE")
+		for _, u := range updates {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.Path, u.Current, u.Latest, u.Delta)
+		}
+		tw.Flush()
+	}
+	if len(majors) > 0 {
+		if len(updates) > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, "New major versions under a different module path:")
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "MODULE\tCURRENT\tNEW MODULE\tLATEST")
+		for _, u := range majors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.Path, u.Current, u.NewPath, u.Latest)
+		}
+		tw.Flush()
+	}
+}
+
+// annotateOutdated reports the updates as GitHub Actions annotations on the
+// require directives of file and in the job summary. Updates that make the
+// command fail are errors, the others warnings.
+func annotateOutdated(out io.Writer, file string, updates []outdated.Update, majors []outdated.MajorUpgrade, threshold versions.Delta) error {
+	var rows [][]string
+	for _, u := range updates {
+		fails := threshold != versions.None && u.Delta.Rank() >= threshold.Rank()
+		ghactions.Write(out, ghactions.Annotation{
+			Level:   annotationLevel(fails),
+			File:    file,
+			Line:    u.Line,
+			Title:   "outdated: " + string(u.Delta) + " update",
+			Message: fmt.Sprintf("%s %s can be updated to %s", u.Path, u.Current, u.Latest),
+		})
+		rows = append(rows, []string{u.Path, u.Current, u.Latest, string(u.Delta)})
+	}
+	for _, u := range majors {
+		ghactions.Write(out, ghactions.Annotation{
+			Level:   annotationLevel(threshold != versions.None),
+			File:    file,
+			Line:    u.Line,
+			Title:   "outdated: new major version",
+			Message: fmt.Sprintf("%s %s has a new major version %s %s", u.Path, u.Current, u.NewPath, u.Latest),
+		})
+		rows = append(rows, []string{u.Path, u.Current, u.NewPath + " " + u.Latest, string(versions.Major)})
+	}
+	return ghactions.WriteSummary("app outdated", []string{"Module", "Current", "Latest", "Update"}, rows, "All modules are up to date.")
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/report"
+)
+
+// Values of the global --output flag.
+const (
+	outputPlain  = "plain"
+	outputJSON   = "json"
+	outputGitHub = "github"
+)
+
+var (
+	// outputFlag is the global --output flag; rep receives the output of
+	// the commands.
+	outputFlag string
+	rep        = report.New(os.Stdout, os.Stderr, false)
+)
+
+// exitError makes main exit with a specific code. A nil err means the
+// command already reported everything it had to say.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageError is an invalid flag or argument.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+
+func (e *usageError) Unwrap() error { return e.err }
+
+// usageErrorf returns a usageError with a formatted message.
+func usageErrorf(format string, args ...any) error {
+	return &usageError{err: fmt.Errorf(format, args...)}
+}
+
+// flagUsage marks the flag parsing errors of cobra as usage errors.
+func flagUsage(cmd *cobra.Command, err error) error {
+	return &usageError{err: err}
+}
+
+// argsUsage makes the argument validation of cmd and its subcommands
+// report usage errors.
+func argsUsage(cmd *cobra.Command) {
+	if cmd.Args != nil {
+		args := cmd.Args
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			if err := args(cmd, a); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, c := range cmd.Commands() {
+		argsUsage(c)
+	}
+}
+
+// validateFlags checks the required flags and flag groups of cmd, which
+// cobra only does after the persistent pre-run, so that their errors count
+// as usage errors.
+func validateFlags(cmd *cobra.Command) error {
+	if err := cmd.ValidateRequiredFlags(); err != nil {
+		return &usageError{err: err}
+	}
+	if err := cmd.ValidateFlagGroups(); err != nil {
+		return &usageError{err: err}
+	}
+	return nil
+}
+
+// exitCode maps the error of a command to the exit codes of the report
+// package.
+func exitCode(err error) int {
+	var ee *exitError
+	var ue *usageError
+	switch {
+	case err == nil:
+		return report.ExitOK
+	case errors.As(err, &ee):
+		return ee.code
+	case errors.As(err, &ue):
+		return report.ExitUsage
+	case networkFailure(err):
+		return report.ExitNetwork
+	}
+	return report.ExitViolations
+}
+
+// networkFailure reports whether err comes from a source of module
+// metadata that could not be reached or did not resolve a module.
+func networkFailure(err error) bool {
+	var (
+		ne net.Error
+		he *proxy.HTTPError
+		pe *proxy.PrivateError
+		ge *gocmd.Error
+	)
+	return errors.As(err, &ne) || errors.As(err, &he) || errors.As(err, &pe) ||
+		errors.Is(err, proxy.ErrNotFound) || errors.As(err, &ge) && ge.Network()
+}
+
+// joinType is the type of the errors returned by errors.Join.
+var joinType = reflect.TypeOf(errors.Join(errors.New("")))
+
+// errorList returns the errors of err, one per error joined by errors.Join.
+func errorList(err error) []error {
+	var ee *exitError
+	if errors.As(err, &ee) {
+		err = ee.err
+	}
+	if err == nil {
+		return nil
+	}
+	if reflect.TypeOf(err) == joinType {
+		var list []error
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			list = append(list, errorList(e)...)
+		}
+		return list
+	}
+	return []error{err}
+}
+
+// envelopeErrors returns the errors of err for the JSON envelope, with the
+// module each is attributed to and its class.
+func envelopeErrors(err error) []report.Error {
+	var list []report.Error
+	for _, e := range errorList(err) {
+		re := report.Error{Message: e.Error(), Class: string(failure.Classify(e))}
+		var me *failure.ModuleError
+		if errors.As(e, &me) {
+			re.Module, re.Version = me.Path, me.Version
+		}
+		list = append(list, re)
+	}
+	return list
+}
+
+// failureTitles are the headings of the classes of failures in the text
+// report, in the order they are listed.
+var failureTitles = []struct {
+	class failure.Class
+	title string
+}{
+	{failure.NotFound, "not found"},
+	{failure.Auth, "authentication failed"},
+	{failure.Timeout, "network timeout"},
+	{failure.Network, "network error"},
+	{failure.Parse, "parse error"},
+	{failure.Other, "other"},
+}
+
+// printErrors writes the errors of err to w, one per line. If several
+// modules failed, their errors follow the others, grouped by class, so that
+// the modules failing for one cause are listed together.
+func printErrors(w io.Writer, err error) {
+	var modules []error
+	for _, e := range errorList(err) {
+		var me *failure.ModuleError
+		if errors.As(e, &me) {
+			modules = append(modules, e)
+		} else {
+			fmt.Fprintln(w, e)
+		}
+	}
+	if len(modules) == 1 {
+		fmt.Fprintln(w, modules[0])
+		return
+	}
+	if len(modules) == 0 {
+		return
+	}
+	byClass := make(map[failure.Class][]error)
+	for _, e := range modules {
+		c := failure.Classify(e)
+		byClass[c] = append(byClass[c], e)
+	}
+	fmt.Fprintf(w, "%d modules failed:\n", len(modules))
+	for _, t := range failureTitles {
+		if errs := byClass[t.class]; len(errs) > 0 {
+			fmt.Fprintf(w, "  %s (%d):\n", t.title, len(errs))
+			for _, e := range errs {
+				fmt.Fprintf(w, "    %v\n", e)
+			}
+		}
+	}
+}
+
+// printStats writes the statistics of --stats to w as a table: the wall
+// time, the counters and the total time of every phase.
+func printStats(w io.Writer, s metrics.Stats) {
+	fmt.Fprintln(w, "stats:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  wall time\t%s\n", millis(s.WallMillis))
+	names := make([]string, 0, len(s.Counters))
+	for name := range s.Counters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%d\n", name, s.Counters[name])
+	}
+	for _, p := range s.Phases {
+		fmt.Fprintf(tw, "  %s time\t%s\t(%d)\n", p.Name, millis(p.Millis), p.Count)
+	}
+	tw.Flush()
+}
+
+// millis formats a duration in milliseconds.
+func millis(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Millisecond).String()
+}
+
+// commandName returns the name of cmd in the JSON envelope: its path below
+// the root command.
+func commandName(cmd *cobra.Command) string {
+	if cmd == nil || !cmd.HasParent() {
+		return ""
+	}
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
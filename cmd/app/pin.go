@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/interactive"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/modfind"
+	"pin-go-dependencies/internal/pin"
+	"pin-go-dependencies/internal/progress"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+	"pin-go-dependencies/pkg/pinner"
+)
+
+func newPinCmd() *cobra.Command {
+	var (
+		file      string
+		dryRun    bool
+		recursive bool
+		skip      []string
+
+		workspace   bool
+		noWorkspace bool
+
+		asOf              string
+		includePrerelease bool
+		concurrency       int
+		allowRetracted    bool
+		pinToolchain      bool
+		fixMVS            bool
+		filter            filterFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pin [module@commit...]",
+		Short: "Rewrite go.mod so every module in the build list is required at its resolved version",
+		Long: `Rewrite go.mod so every module in the build list is required at its resolved version.
+
+With module@commit arguments, only the given modules are pinned, to the
+pseudo-versions of the commits, which may be abbreviated hashes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			commits, err := parseCommits(args)
+			if err != nil {
+				return err
+			}
+			if len(commits) > 0 && (recursive || asOf != "" || fixMVS) {
+				return usageErrorf("module@commit arguments cannot be combined with --recursive, --as-of or --fix-mvs")
+			}
+			opts := pinner.ResolveOptions{
+				Workspace:         pinner.WorkspaceAuto,
+				Exclude:           matcher(cfg.Exclude),
+				IncludePrerelease: includePrerelease,
+				Commits:           commits,
+				FixMVS:            fixMVS,
+			}
+			switch {
+			case workspace:
+				opts.Workspace = pinner.WorkspaceOn
+			case noWorkspace:
+				opts.Workspace = pinner.WorkspaceOff
+			}
+			if pinToolchain {
+				env, err := gocmd.Env("GOVERSION")
+				if err != nil {
+					return err
+				}
+				opts.Toolchain = env["GOVERSION"]
+			}
+			if asOf != "" {
+				if opts.Before, err = parseCutoff(asOf); err != nil {
+					return err
+				}
+			}
+			p, err := newPinner(concurrency, pinner.WithAllowRetracted(allowRetracted))
+			if err != nil {
+				return err
+			}
+			plan := func(file string) (*pinner.Resolution, error) {
+				o := opts
+				if !filter.Empty() {
+					m, err := gomod.Load(file)
+					if err != nil {
+						return nil, err
+					}
+					o.Exclude = excluding(o.Exclude, filter.selector(m))
+				}
+				return p.ResolveWith(cmd.Context(), file, o)
+			}
+
+			if !recursive {
+				res, err := pinModule(cmd, p, file, plan, dryRun)
+				if err != nil {
+					return err
+				}
+				r := resolutionResult(res, dryRun)
+				return reportChangeResult(r, func(out io.Writer) { printPinSummary(out, r) })
+			}
+
+			files, err := modfind.Find(".", skip)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no go.mod found below the current directory")
+			}
+			// Modules are pinned concurrently and each is reported in one
+			// piece as soon as it is done. The summary and the JSON results
+			// list all of them at the end, in the order of their paths.
+			disp := progress.New(rep.Err, len(files), showProgress())
+			if err := setupLogging(disp.Writer(rep.Err)); err != nil {
+				return err
+			}
+			defer setupLogging(rep.Err)
+			done := make([]*changeResult, len(files))
+			errs := make([]error, len(files))
+			indexes := make([]int, len(files))
+			for i := range indexes {
+				indexes[i] = i
+			}
+			workpool.Map(concurrency, failFast, indexes, func(i int) (struct{}, error) {
+				f := files[i]
+				disp.Start(f)
+				defer disp.Done(f)
+				res, err := pinModule(cmd, p, f, plan, dryRun)
+				if err != nil {
+					disp.Print(rep.Err, []byte(fmt.Sprintf("%s: %v\n", f, err)))
+					done[i] = &changeResult{File: f, Added: []pin.Change{}, Changed: []pin.Change{}, Error: err.Error()}
+					errs[i] = err
+					return struct{}{}, err
+				}
+				r := resolutionResult(res, dryRun)
+				var buf bytes.Buffer
+				if dryRun {
+					buf.WriteString(r.Diff)
+				} else {
+					printPinSummary(&buf, r)
+					buf.WriteString("\n")
+				}
+				disp.Print(rep.Text(), buf.Bytes())
+				done[i] = &r
+				return struct{}{}, nil
+			})
+			disp.Close()
+
+			// With --fail-fast, the modules that were not started are
+			// left out.
+			results := []changeResult{}
+			var failed, pending, offline int
+			for i, r := range done {
+				if r == nil {
+					continue
+				}
+				results = append(results, *r)
+				switch {
+				case errs[i] != nil:
+					failed++
+					if networkFailure(errs[i]) {
+						offline++
+					}
+				case dryRun && r.Modified:
+					pending++
+				}
+			}
+			if !dryRun {
+				printRecursiveSummary(rep.Text(), results)
+				fmt.Fprintf(rep.Text(), "%d modules, %d failed\n", len(files), failed)
+			}
+			rep.Result(results, func(io.Writer) error { return nil })
+			switch {
+			case failed > 0:
+				// Only failures to reach the network make up a network
+				// failure of the run.
+				code := report.ExitViolations
+				if offline == failed {
+					code = report.ExitNetwork
+				}
+				return &exitError{code: code, err: fmt.Errorf("pinning failed for %d of %d modules", failed, len(files))}
+			case pending > 0:
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file to pin")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print a diff of the pending changes instead of writing them")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "pin every module found below the current directory")
+	cmd.Flags().StringSliceVar(&skip, "skip", nil, "directories to skip in recursive mode, by name or relative path")
+	cmd.Flags().BoolVar(&workspace, "workspace", false, "pin to the build list of the go.work workspace, failing if there is none")
+	cmd.Flags().BoolVar(&noWorkspace, "no-workspace", false, "ignore go.work and pin each module on its own")
+	cmd.MarkFlagsMutuallyExclusive("workspace", "no-workspace")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "pin to the newest releases published on or before this date (YYYY-MM-DD or RFC 3339)")
+	cmd.Flags().BoolVar(&includePrerelease, "include-prerelease", false, "allow --as-of to select pre-release versions")
+	cmd.Flags().BoolVar(&allowRetracted, "allow-retracted", false, "allow pinning versions retracted by their authors")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of module proxy requests and go commands running at a time, shared by the modules pinned with --recursive, and of modules pinned at a time")
+	cmd.Flags().BoolVar(&pinToolchain, "pin-toolchain", false, "also set the toolchain directive to the go toolchain in use")
+	cmd.Flags().BoolVar(&fixMVS, "fix-mvs", false, "only raise the requirements below the version minimal version selection picks to that version")
+	cmd.MarkFlagsMutuallyExclusive("fix-mvs", "as-of")
+	cmd.MarkFlagsMutuallyExclusive("fix-mvs", "pin-toolchain")
+	filter.register(cmd)
+	return cmd
+}
+
+// showProgress reports whether recursive runs show their progress on
+// standard error: only if it is a terminal and the output is not JSON.
+func showProgress() bool {
+	f, ok := rep.Err.(*os.File)
+	return ok && !rep.JSON() && interactive.IsTerminal(f)
+}
+
+// printRecursiveSummary prints the outcome of every module of a recursive
+// run, in the order of results.
+func printRecursiveSummary(out io.Writer, results []changeResult) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, r := range results {
+		status := "already pinned"
+		switch {
+		case r.Error != "":
+			status = "failed"
+		case r.Modified:
+			status = fmt.Sprintf("%d added, %d changed", len(r.Added), len(r.Changed))
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", r.File, status)
+	}
+	tw.Flush()
+}
+
+// parseCommits parses module@commit arguments.
+func parseCommits(args []string) ([]module.Version, error) {
+	var commits []module.Version
+	for _, a := range args {
+		path, rev, ok := strings.Cut(a, "@")
+		if !ok || path == "" || rev == "" {
+			return nil, usageErrorf("invalid argument %q, expected module@commit", a)
+		}
+		if !resolve.IsCommit(rev) {
+			return nil, usageErrorf("%s: %q is not a commit hash; pass the commit to pin (at least 7 hex digits), not a branch or tag name", path, rev)
+		}
+		commits = append(commits, module.Version{Path: path, Version: rev})
+	}
+	return commits, nil
+}
+
+// parseCutoff parses the --as-of value and returns the exclusive upper bound
+// for publication times. A plain date includes the whole day in UTC.
+func parseCutoff(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, usageErrorf("invalid --as-of %q: expected YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	return t.Add(time.Nanosecond), nil
+}
+
+// pinModule pins a single go.mod through plan. Unless dryRun is set, it
+// applies the changes with p.
+func pinModule(cmd *cobra.Command, p *pinner.Pinner, file string, plan func(string) (*pinner.Resolution, error), dryRun bool) (*pinner.Resolution, error) {
+	done := metrics.Start(metrics.PhaseResolve)
+	res, err := plan(file)
+	done()
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && res.Modified() {
+		apply := func() error { return p.Apply(cmd.Context(), res) }
+		if err := applyFiles(cmd, res.File, res.Files(), changeSummary(res.Changed, res.Added, nil), apply); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// changeResult is the JSON result of a command rewriting go.mod, or of one
+// module of a recursive run, where Error is set if the module failed.
+type changeResult struct {
+	File      string       `json:"file"`
+	Modified  bool         `json:"modified"`
+	DryRun    bool         `json:"dryRun"`
+	Added     []pin.Change `json:"added"`
+	Changed   []pin.Change `json:"changed"`
+	Removed   []pin.Change `json:"removed"`
+	Toolchain *pin.Change  `json:"toolchain,omitempty"`
+	// Sources are the Go files whose imports were rewritten.
+	Sources []string `json:"sources,omitempty"`
+	// Diff holds the pending changes of a dry run.
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func newChangeResult(res *pin.Result, dryRun bool) changeResult {
+	r := changeResult{
+		File:      res.File,
+		Modified:  res.Modified(),
+		DryRun:    dryRun,
+		Added:     res.Added,
+		Changed:   res.Changed,
+		Removed:   res.Removed,
+		Toolchain: res.Toolchain,
+	}
+	for _, s := range res.Sources {
+		r.Sources = append(r.Sources, s.Name)
+	}
+	return r.complete(res.Diff)
+}
+
+// resolutionResult is the changeResult of a Resolution of the pin command.
+func resolutionResult(res *pinner.Resolution, dryRun bool) changeResult {
+	r := changeResult{
+		File:      res.File,
+		Modified:  res.Modified(),
+		DryRun:    dryRun,
+		Added:     res.Added,
+		Changed:   res.Changed,
+		Toolchain: res.Toolchain,
+	}
+	return r.complete(res.Diff)
+}
+
+// complete returns r with empty lists instead of nil ones, and in a dry run
+// with the diff of the pending changes.
+func (r changeResult) complete(diff func() []byte) changeResult {
+	if r.Added == nil {
+		r.Added = []pin.Change{}
+	}
+	if r.Changed == nil {
+		r.Changed = []pin.Change{}
+	}
+	if r.Removed == nil {
+		r.Removed = []pin.Change{}
+	}
+	if r.DryRun {
+		r.Diff = string(diff())
+	}
+	return r
+}
+
+// reportChange reports res, which has been applied unless dryRun is set:
+// as a diff of the pending changes in a dry run, and through summary
+// otherwise. Changes pending in a dry run are violations.
+func reportChange(res *pin.Result, dryRun bool, summary func(io.Writer, *pin.Result)) error {
+	return reportChangeResult(newChangeResult(res, dryRun), func(out io.Writer) { summary(out, res) })
+}
+
+// reportChangeResult reports r like reportChange, through summary unless it
+// is a dry run.
+func reportChangeResult(r changeResult, summary func(io.Writer)) error {
+	err := rep.Result(r, func(out io.Writer) error {
+		if r.DryRun {
+			_, err := io.WriteString(out, r.Diff)
+			return err
+		}
+		summary(out)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if r.DryRun && r.Modified {
+		return &exitError{code: report.ExitViolations}
+	}
+	return nil
+}
+
+func printPinSummary(out io.Writer, res changeResult) {
+	for _, c := range res.Added {
+		fmt.Fprintf(out, "  + %s %s\n", c.Path, c.New)
+	}
+	for _, c := range res.Changed {
+		fmt.Fprintf(out, "  ~ %s %s -> %s\n", c.Path, c.Old, c.New)
+	}
+	if t := res.Toolchain; t != nil {
+		if t.Old == "" {
+			fmt.Fprintf(out, "  + toolchain %s\n", t.New)
+		} else {
+			fmt.Fprintf(out, "  ~ toolchain %s -> %s\n", t.Old, t.New)
+		}
+	}
+	if !res.Modified {
+		fmt.Fprintf(out, "%s: already pinned\n", res.File)
+		return
+	}
+	fmt.Fprintf(out, "%s: %d added, %d changed\n", res.File, len(res.Added), len(res.Changed))
+}
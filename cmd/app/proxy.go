@@ -0,0 +1,131 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/pkg/pinner"
+)
+
+// proxyFlags configures module proxy access for all subcommands.
+var proxyFlags struct {
+	fs       *pflag.FlagSet
+	goproxy  string
+	offline  bool
+	cacheTTL time.Duration
+	noCache  bool
+
+	requestTimeout time.Duration
+	retries        int
+}
+
+func addProxyFlags(fs *pflag.FlagSet) {
+	proxyFlags.fs = fs
+	fs.StringVar(&proxyFlags.goproxy, "proxy", "", "module proxy list in GOPROXY syntax, instead of the configuration file and GOPROXY")
+	fs.BoolVar(&proxyFlags.offline, "offline", false, "never access the network: resolve versions from the module cache only")
+	fs.DurationVar(&proxyFlags.cacheTTL, "cache-ttl", time.Hour, "how long cached module version lists stay valid")
+	fs.BoolVar(&proxyFlags.noCache, "no-cache", false, "do not read or write the module proxy cache")
+	fs.DurationVar(&proxyFlags.requestTimeout, "request-timeout", httpx.Timeout, "how long a request to a proxy, repository or database may take before it is retried or fails; 0 for no limit")
+	fs.IntVar(&proxyFlags.retries, "retries", httpx.Retries, "how many times a request is retried after a server error or a timeout, waiting twice as long each time")
+}
+
+// requireNetwork fails if --offline is set, for commands that cannot work
+// without the network.
+func requireNetwork(cmd *cobra.Command, why string) error {
+	if proxyFlags.offline {
+		return usageErrorf("%s cannot run with --offline: it %s", cmd.Name(), why)
+	}
+	return nil
+}
+
+// goproxy returns the effective module proxy list and where it comes from.
+func goproxy() (string, string, error) {
+	switch {
+	case proxyFlags.fs.Changed("proxy"):
+		return proxyFlags.goproxy, sourceFlag, nil
+	case cfg.Proxy.URL != "":
+		return cfg.Proxy.URL, sourceFile, nil
+	}
+	env, err := gocmd.Env("GOPROXY")
+	if err != nil {
+		return "", "", err
+	}
+	return env["GOPROXY"], sourceEnv, nil
+}
+
+// cacheTTL returns the effective --cache-ttl and where it comes from.
+func cacheTTL() (time.Duration, string) {
+	switch {
+	case proxyFlags.fs.Changed("cache-ttl"):
+		return proxyFlags.cacheTTL, sourceFlag
+	case cfg.Cache.TTL != nil:
+		return *cfg.Cache.TTL, sourceFile
+	}
+	return proxyFlags.cacheTTL, sourceDefault
+}
+
+// noCache returns the effective --no-cache and where it comes from.
+func noCache() (bool, string) {
+	switch {
+	case proxyFlags.fs.Changed("no-cache"):
+		return proxyFlags.noCache, sourceFlag
+	case cfg.Cache.Disabled != nil:
+		return *cfg.Cache.Disabled, sourceFile
+	}
+	return proxyFlags.noCache, sourceDefault
+}
+
+// newProxyClient returns a client for the module proxy of the configuration
+// file or else the go environment, configured by the proxy flags.
+func newProxyClient() (*proxy.Client, error) {
+	if proxyFlags.offline {
+		env, err := gocmd.Env("GOMODCACHE")
+		if err != nil {
+			return nil, err
+		}
+		return proxy.NewOffline(filepath.Join(env["GOMODCACHE"], "cache", "download")), nil
+	}
+	var opts proxy.Options
+	if disabled, _ := noCache(); !disabled {
+		dir, err := proxy.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		ttl, _ := cacheTTL()
+		opts.Cache = &proxy.Cache{Dir: dir, TTL: ttl}
+	}
+	list, _, err := goproxy()
+	if err != nil {
+		return nil, err
+	}
+	return proxy.FromEnv(list, opts)
+}
+
+// newPinner returns a Pinner for the module proxy of newProxyClient,
+// configured by the proxy flags, --fail-fast and opts, making up to
+// concurrency proxy requests at a time.
+func newPinner(concurrency int, opts ...pinner.Option) (*pinner.Pinner, error) {
+	list, _, err := goproxy()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]pinner.Option{
+		pinner.WithProxy(list),
+		pinner.WithConcurrency(concurrency),
+		pinner.WithFailFast(failFast),
+		pinner.WithOffline(proxyFlags.offline),
+	}, opts...)
+	if disabled, _ := noCache(); disabled {
+		opts = append(opts, pinner.WithCacheDir(""))
+	} else {
+		ttl, _ := cacheTTL()
+		opts = append(opts, pinner.WithCacheTTL(ttl))
+	}
+	return pinner.New(opts...)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/pin"
+)
+
+func newPruneCmd() *cobra.Command {
+	var (
+		file   string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove requirements on modules the main module does not need",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := pin.PlanPrune(cmd.Context(), file, matcher(cfg.Exclude))
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				if err := applyResult(cmd, res); err != nil {
+					return err
+				}
+			}
+			return reportChange(res, dryRun, printPruneSummary)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of the requirements and go.sum entries that would be removed instead of removing them")
+	return cmd
+}
+
+func printPruneSummary(out io.Writer, res *pin.Result) {
+	for _, c := range res.Removed {
+		fmt.Fprintf(out, "  - %s %s\n", c.Path, c.Old)
+	}
+	if !res.Modified() {
+		fmt.Fprintf(out, "%s: nothing to prune\n", res.File)
+		return
+	}
+	fmt.Fprintf(out, "%s: %d removed\n", res.File, len(res.Removed))
+}
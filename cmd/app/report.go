@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/audit"
+	"pin-go-dependencies/internal/drift"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newReportCmd() *cobra.Command {
+	var (
+		file        string
+		format      string
+		withVulns   bool
+		dbPath      string
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Export how far the dependencies lag behind their latest releases",
+		Long: `Write a document listing every requirement with its current and latest
+version, when both were published and the days between them, for tracking
+dependency drift across many repositories. The document carries the main
+module path and go version at the top level, and a schemaVersion that is
+raised on incompatible changes.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "json", "renovate"); err != nil {
+				return err
+			}
+			m, err := gomod.Load(file)
+			if err != nil {
+				return err
+			}
+			if err := requireNetwork(cmd, "compares against the newest versions published"); err != nil {
+				return err
+			}
+			c, err := newProxyClient()
+			if err != nil {
+				return err
+			}
+			doc, lookupErr := drift.Find(cmd.Context(), c, m, concurrency, failFast)
+			if withVulns {
+				src, err := vulnSource(cmd, dbPath, concurrency)
+				if err != nil {
+					return err
+				}
+				findings, err := audit.Run(src, doc.Modules(), cfg.Audit.Ignore)
+				if err != nil {
+					return err
+				}
+				drift.AddVulnerabilities(doc, findings)
+			}
+
+			var data []byte
+			if format == "renovate" {
+				data, err = drift.Renovate(doc)
+			} else {
+				data, err = json.MarshalIndent(doc, "", "  ")
+				data = append(data, '\n')
+			}
+			if err != nil {
+				return err
+			}
+			err = rep.Result(json.RawMessage(data), func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return lookupErr
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&format, "format", "json", "output format: json or renovate, the layout of the file report of Renovate")
+	cmd.Flags().BoolVar(&withVulns, "with-vulns", false, "look up the known vulnerabilities of the current versions, like audit")
+	cmd.Flags().StringVar(&dbPath, "db", "", "with --with-vulns, read vulnerabilities from a downloaded OSV database instead of the OSV API")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy and OSV requests")
+	return cmd
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/sbom"
+)
+
+// sbomResult is the JSON result of sbom when the SBOM is written to a file.
+// Written to standard output, the SBOM itself is the result.
+type sbomResult struct {
+	File       string `json:"file"`
+	Format     string `json:"format"`
+	Components int    `json:"components"`
+}
+
+func newSBOMCmd() *cobra.Command {
+	var (
+		file   string
+		format string
+		out    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Write a software bill of materials for the build list",
+		Long: `Write a software bill of materials for the build list of the module of --file,
+in the CycloneDX or SPDX JSON format, to standard output or to the file named
+by --out.
+
+--out was called --output before the global --output flag, which now selects
+the output mode of the run: use --out (or -o) for the file of the SBOM.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "cyclonedx-json", "spdx-json"); err != nil {
+				return err
+			}
+			inv, err := sbom.Collect(cmd.Context(), file)
+			if err != nil {
+				return err
+			}
+			var data []byte
+			if format == "spdx-json" {
+				data, err = sbom.SPDX(inv)
+			} else {
+				data, err = sbom.CycloneDX(inv)
+			}
+			if err != nil {
+				return err
+			}
+			if out == "-" {
+				return rep.Result(json.RawMessage(data), func(w io.Writer) error {
+					_, err := w.Write(data)
+					return err
+				})
+			}
+			if err := fsutil.ReplaceFile(out, data); err != nil {
+				return err
+			}
+			res := sbomResult{File: out, Format: format, Components: len(inv.Components)}
+			return rep.Result(res, func(io.Writer) error { return nil })
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&format, "format", "cyclonedx-json", "output format: cyclonedx-json or spdx-json")
+	cmd.Flags().StringVarP(&out, "out", "o", "-", "file to write the SBOM to, - for standard output")
+	return cmd
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/scripts"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// scanFixResult is the JSON result of scan-scripts --fix: every finding
+// and the version each package was pinned to. Packages that could not be
+// resolved are missing from Pinned and reported as errors.
+type scanFixResult struct {
+	Findings []scripts.Finding `json:"findings"`
+	Pinned   map[string]string `json:"pinned"`
+}
+
+func newScanScriptsCmd() *cobra.Command {
+	var (
+		globs       []string
+		format      string
+		fix         bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scan-scripts [paths...]",
+		Short: "Find go install and go run invocations of unpinned tools in scripts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format, "text", "json"); err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			globs, _ = scriptGlobs(cmd, globs)
+			findings, err := scripts.Walk(args, globs)
+			if err != nil {
+				return err
+			}
+			if findings == nil {
+				findings = []scripts.Finding{}
+			}
+
+			if !fix {
+				err := rep.Result(findings, func(out io.Writer) error {
+					if format == "json" {
+						return writeJSON(out, findings)
+					}
+					for _, f := range findings {
+						fmt.Fprintf(out, "%s:%d:%d: go %s %s\n", f.File, f.Line, f.Column, f.Command, argument(f.Package, f.Version))
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				if len(findings) > 0 {
+					return &exitError{code: report.ExitViolations}
+				}
+				return nil
+			}
+
+			var pkgs []string
+			for _, f := range findings {
+				pkgs = append(pkgs, f.Package)
+			}
+			pinned, resolveErr := pinTools(cmd.Context(), pkgs, concurrency)
+			byFile := make(map[string][]scripts.Finding)
+			var files []string
+			for _, f := range findings {
+				if byFile[f.File] == nil {
+					files = append(files, f.File)
+				}
+				byFile[f.File] = append(byFile[f.File], f)
+			}
+			for _, file := range files {
+				if err := scripts.Fix(file, byFile[file], pinned); err != nil {
+					return err
+				}
+			}
+			res := scanFixResult{Findings: findings, Pinned: pinned}
+			err = rep.Result(res, func(out io.Writer) error {
+				for _, f := range findings {
+					if v, ok := pinned[f.Package]; ok {
+						fmt.Fprintf(out, "%s:%d:%d: %s -> %s@%s\n", f.File, f.Line, f.Column, argument(f.Package, f.Version), f.Package, v)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return resolveErr
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&globs, "glob", nil, "file name pattern to scan, instead of Dockerfile*, Makefile, *.sh and *.yml; can be repeated")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: text or json")
+	cmd.Flags().BoolVar(&fix, "fix", false, "rewrite each invocation to the latest tagged version of its module")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy requests")
+	return cmd
+}
+
+// scriptGlobs returns the effective --glob patterns and where they come
+// from.
+func scriptGlobs(cmd *cobra.Command, flagValue []string) ([]string, string) {
+	globs, source := listSetting(cmd, "glob", flagValue, cfg.Scripts.Globs)
+	if len(globs) == 0 {
+		return scripts.DefaultGlobs, sourceDefault
+	}
+	return globs, source
+}
+
+// argument formats a package argument as found in a script.
+func argument(pkg, version string) string {
+	if version == "" {
+		return pkg
+	}
+	return pkg + "@" + version
+}
+
+// pinTools resolves the latest tagged version of the modules providing the
+// packages, which may repeat, keyed by package. Packages whose module has no
+// tagged release are reported in the error and left out.
+func pinTools(ctx context.Context, packages []string, concurrency int) (map[string]string, error) {
+	c, err := newProxyClient()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var pkgs []string
+	for _, pkg := range packages {
+		if !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	found, errs := workpool.Map(concurrency, failFast, pkgs, func(pkg string) (string, error) {
+		mod, v, err := resolve.Package(ctx, c, pkg)
+		if err != nil {
+			return "", err
+		}
+		if module.IsPseudoVersion(v) {
+			return "", fmt.Errorf("%s: module %s has no tagged release", pkg, mod)
+		}
+		return v, nil
+	})
+	pinned := make(map[string]string)
+	for i, pkg := range pkgs {
+		if errs[i] == nil {
+			pinned[pkg] = found[i]
+		}
+	}
+	return pinned, errors.Join(errs...)
+}
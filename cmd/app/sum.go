@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/pin"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/sumcheck"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// sumResult is the JSON result of sum check.
+type sumResult struct {
+	File     string             `json:"file"`
+	Findings []sumcheck.Finding `json:"findings"`
+}
+
+func newSumCmd() *cobra.Command {
+	var (
+		file        string
+		dryRun      bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sum",
+		Short: "Check and fix the go.sum entries of the module graph",
+	}
+	cmd.PersistentFlags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.PersistentFlags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent proxy requests")
+
+	// load returns the module, its go.sum and what sumcheck finds in it.
+	load := func(ctx context.Context, opts sumcheck.Options) (*gomod.Module, []byte, *gosum.Sum, []sumcheck.Finding, error) {
+		m, err := gomod.Load(file)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		sumFile := filepath.Join(filepath.Dir(file), "go.sum")
+		data, err := os.ReadFile(sumFile)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, nil, nil, err
+		}
+		sum, err := gosum.Parse(sumFile, data)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		found, err := sumcheck.Check(ctx, m, sum, opts)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return m, data, sum, found, nil
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "check",
+		Short: "Fail on orphaned, missing or conflicting go.sum entries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newProxyClient()
+			if err != nil {
+				return err
+			}
+			_, _, _, found, err := load(cmd.Context(), sumcheck.Options{Proxy: c, Concurrency: concurrency, FailFast: failFast})
+			if err != nil {
+				return err
+			}
+			if found == nil {
+				found = []sumcheck.Finding{}
+			}
+			sumFile := filepath.Join(filepath.Dir(file), "go.sum")
+			err = rep.Result(sumResult{File: sumFile, Findings: found}, func(out io.Writer) error {
+				if len(found) == 0 {
+					fmt.Fprintf(out, "%s: matches the module graph\n", sumFile)
+					return nil
+				}
+				return printSumFindings(out, found)
+			})
+			if err != nil {
+				return err
+			}
+			if len(found) > 0 {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	})
+
+	fix := &cobra.Command{
+		Use:   "fix",
+		Short: "Drop orphaned go.sum entries and record missing and conflicting hashes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireNetwork(cmd, "downloads the modules whose hashes are missing"); err != nil {
+				return err
+			}
+			c, err := newProxyClient()
+			if err != nil {
+				return err
+			}
+			v, err := newChecksumVerifier()
+			if err != nil {
+				return err
+			}
+			opts := sumcheck.Options{Proxy: c, Checksum: v, Concurrency: concurrency, FailFast: failFast}
+			m, data, sum, found, err := load(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			fixed, err := sumcheck.Fix(cmd.Context(), sum, found, opts)
+			if err != nil {
+				return err
+			}
+			res := &pin.Result{
+				File:    file,
+				Old:     m.Data,
+				New:     m.Data,
+				SumFile: filepath.Join(filepath.Dir(file), "go.sum"),
+				OldSum:  data,
+				NewSum:  fixed.Format(),
+			}
+			if !dryRun {
+				if err := applyResult(cmd, res); err != nil {
+					return err
+				}
+			}
+			return reportChange(res, dryRun, func(out io.Writer, res *pin.Result) {
+				printSumSummary(out, res, found)
+			})
+		},
+	}
+	fix.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of go.sum instead of writing it")
+	cmd.AddCommand(fix)
+	return cmd
+}
+
+func printSumFindings(out io.Writer, found []sumcheck.Finding) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tVERSION\tPROBLEM\tHASHES")
+	for _, f := range found {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Path, f.Version, f.Kind, strings.Join(f.Hashes, " "))
+	}
+	return tw.Flush()
+}
+
+func printSumSummary(out io.Writer, res *pin.Result, found []sumcheck.Finding) {
+	counts := make(map[sumcheck.Kind]int)
+	for _, f := range found {
+		counts[f.Kind]++
+	}
+	if !res.Modified() {
+		fmt.Fprintf(out, "%s: nothing to fix\n", res.SumFile)
+		return
+	}
+	fmt.Fprintf(out, "%s: %d orphaned removed, %d missing added, %d conflicting resolved\n",
+		res.SumFile, counts[sumcheck.Orphaned], counts[sumcheck.Missing], counts[sumcheck.Conflicting])
+}
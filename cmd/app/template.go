@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/render"
+)
+
+// templateFlags are the custom output flags of list, outdated and check.
+type templateFlags struct {
+	text string
+	file string
+	help bool
+}
+
+func (f *templateFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.text, "template", "", "render the results through this Go text/template instead of the built-in output; see --template-help")
+	cmd.Flags().StringVar(&f.file, "template-file", "", "like --template, with the template read from this file")
+	cmd.Flags().BoolVar(&f.help, "template-help", false, "print the data the template receives, its helper functions and an example, and exit")
+	cmd.MarkFlagsMutuallyExclusive("template", "template-file")
+}
+
+// parse returns the template given by the flags, or nil if there is none.
+// A template replaces the output of --format and of --output, so those
+// cannot be set along with it.
+func (f *templateFlags) parse(cmd *cobra.Command) (*render.Template, error) {
+	if f.text == "" && f.file == "" {
+		return nil, nil
+	}
+	if cmd.Flags().Changed("format") {
+		return nil, usageErrorf("--template cannot be combined with --format")
+	}
+	if outputFlag != outputPlain {
+		return nil, usageErrorf("--template cannot be combined with --output %s", outputFlag)
+	}
+	name, text := "--template", f.text
+	if f.file != "" {
+		data, err := os.ReadFile(f.file)
+		if err != nil {
+			return nil, err
+		}
+		name, text = f.file, string(data)
+	}
+	t, err := render.Parse(name, text)
+	if err != nil {
+		return nil, usageErrorf("invalid template: %v", err)
+	}
+	return t, nil
+}
+
+// describeTemplate prints the data model of templates receiving values of
+// the type of v for --template-help, with an example template.
+func describeTemplate(v any, example string) error {
+	return render.Describe(rep.Text(), v, example)
+}
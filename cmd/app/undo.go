@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/backup"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/pin"
+)
+
+// backupKeep returns the number of backups to keep and where it comes from.
+func backupKeep() (int, string) {
+	if cfg.Backup.Keep != nil {
+		return *cfg.Backup.Keep, sourceFile
+	}
+	return backup.DefaultKeep, sourceDefault
+}
+
+// applyResult snapshots the files res rewrites and then applies it, unless
+// the run was cancelled. The snapshot is summarized by the name of the
+// running command and the changes.
+func applyResult(cmd *cobra.Command, res *pin.Result) error {
+	if !res.Modified() {
+		return nil
+	}
+	return applyFiles(cmd, res.File, res.Files(), changeSummary(res.Changed, res.Added, res.Removed), res.Apply)
+}
+
+// applyFiles snapshots files, the files a rewrite of the go.mod file
+// writes, and then writes them with apply, like applyResult.
+func applyFiles(cmd *cobra.Command, file string, files []string, summary string, apply func() error) error {
+	// Once the run is cancelled, nothing is written anymore; files that
+	// are being written are written completely.
+	if err := cmd.Context().Err(); err != nil {
+		return err
+	}
+	defer metrics.Start(metrics.PhaseWrite)()
+	if keep, _ := backupKeep(); keep > 0 {
+		if _, err := backup.Save(filepath.Dir(file), keep, cmd.Name()+": "+summary, files...); err != nil {
+			return fmt.Errorf("backing up %s: %w", file, err)
+		}
+	}
+	return apply()
+}
+
+// changeSummary describes the changed, added and removed requirements on
+// one line.
+func changeSummary(changed, added, removed []pin.Change) string {
+	const shown = 3
+	var parts []string
+	for _, c := range changed {
+		parts = append(parts, fmt.Sprintf("%s %s -> %s", c.Path, c.Old, c.New))
+	}
+	for _, c := range added {
+		parts = append(parts, fmt.Sprintf("+%s %s", c.Path, c.New))
+	}
+	for _, c := range removed {
+		parts = append(parts, fmt.Sprintf("-%s %s", c.Path, c.Old))
+	}
+	switch {
+	case len(parts) == 0:
+		return "no version changes"
+	case len(parts) > shown:
+		return fmt.Sprintf("%s and %d more", strings.Join(parts[:shown], ", "), len(parts)-shown)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// snapshotResult is a backup in the JSON results of undo: the restored one,
+// or each available one with --list.
+type snapshotResult struct {
+	Name    string    `json:"name"`
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary"`
+}
+
+func newUndoCmd() *cobra.Command {
+	var (
+		file string
+		at   string
+		list bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Restore go.mod and go.sum from the backup taken before the last change",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir := filepath.Dir(file)
+			if list {
+				snaps, err := backup.List(dir)
+				if err != nil {
+					return err
+				}
+				res := []snapshotResult{}
+				for _, s := range snaps {
+					res = append(res, snapshotResult{Name: s.Name, Time: s.Time, Summary: s.Summary})
+				}
+				return rep.Result(res, func(out io.Writer) error {
+					if len(snaps) == 0 {
+						_, err := fmt.Fprintf(out, "no backups in %s\n", filepath.Join(dir, backup.DirName))
+						return err
+					}
+					tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+					fmt.Fprintln(tw, "TIMESTAMP\tSUMMARY")
+					for _, s := range snaps {
+						fmt.Fprintf(tw, "%s\t%s\n", s.Name, s.Summary)
+					}
+					return tw.Flush()
+				})
+			}
+
+			s, err := backup.Find(dir, at)
+			if err != nil {
+				return err
+			}
+			if err := backup.Restore(dir, s); err != nil {
+				return err
+			}
+			res := snapshotResult{Name: s.Name, Time: s.Time, Summary: s.Summary}
+			return rep.Result(res, func(out io.Writer) error {
+				_, err := fmt.Fprintf(out, "%s: restored the backup %s (%s)\n", file, s.Name, s.Summary)
+				return err
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().StringVar(&at, "at", "", "timestamp of the backup to restore instead of the newest one")
+	cmd.Flags().BoolVar(&list, "list", false, "list the available backups")
+	cmd.MarkFlagsMutuallyExclusive("at", "list")
+	return cmd
+}
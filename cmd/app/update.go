@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/interactive"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/pin"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/toolchain"
+	"pin-go-dependencies/internal/versions"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newUpdateCmd() *cobra.Command {
+	var (
+		file     string
+		all      bool
+		within   string
+		dryRun   bool
+		tc       string
+		major    bool
+		interact bool
+		filter   filterFlags
+		opts     pin.UpdateOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update [module...]",
+		Short: "Move pinned modules to newer versions within a semver constraint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interact && len(args) == 0 {
+				all = true
+			}
+			if all && len(args) > 0 || !all && len(args) == 0 && tc == "" {
+				return usageErrorf("pass either module paths or --all")
+			}
+			if major && len(args) == 0 {
+				return usageErrorf("--major needs the paths of the modules to move")
+			}
+			if opts.RewriteImports && !major {
+				return usageErrorf("--rewrite-imports only applies to --major")
+			}
+			if interact && !interactive.IsTerminal(os.Stdin) {
+				return usageErrorf("--interactive needs a terminal on standard input")
+			}
+			if tc == "latest" {
+				if err := requireNetwork(cmd, "looks up the newest Go release"); err != nil {
+					return err
+				}
+				var err error
+				if tc, err = toolchain.Latest(cmd.Context(), toolchain.DownloadURL); err != nil {
+					return err
+				}
+			}
+			opts.Toolchain = tc
+			opts.FailFast = failFast
+			if !cmd.Flags().Changed("within") && cfg.Update.Within != "" {
+				within = cfg.Update.Within
+			}
+			switch within {
+			case "patch", "minor", "major":
+				opts.Within = versions.Delta(within)
+			default:
+				return usageErrorf("invalid --within %q, expected patch, minor or major", within)
+			}
+			opts.ForbidMajor = matcher(cfg.ForbidMajor)
+			if !filter.Empty() && (all || len(args) > 0) {
+				m, err := gomod.Load(file)
+				if err != nil {
+					return err
+				}
+				if args, err = filter.paths(m, args); err != nil {
+					return err
+				}
+			}
+			var err error
+			if opts.Proxy, err = newProxyClient(); err != nil {
+				return err
+			}
+
+			var res *pin.Result
+			done := metrics.Start(metrics.PhaseResolve)
+			switch {
+			case interact:
+				res, err = planInteractive(cmd.Context(), file, args, opts)
+			case major:
+				res, err = pin.PlanMajor(cmd.Context(), file, args, opts)
+			case !all && len(args) == 0:
+				res, err = pin.PlanToolchain(file, tc)
+			default:
+				res, err = pin.PlanUpdate(cmd.Context(), file, args, opts)
+			}
+			done()
+			if err != nil {
+				return err
+			}
+			if !dryRun {
+				if err := applyResult(cmd, res); err != nil {
+					return err
+				}
+			}
+			return reportChange(res, dryRun, printUpdateSummary)
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().BoolVar(&all, "all", false, "update all required modules")
+	cmd.Flags().StringVar(&within, "within", "minor", "largest allowed update: patch, minor or major")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print a diff of the pending changes instead of writing them")
+	cmd.Flags().StringVar(&tc, "toolchain", "", "also set the toolchain directive: a name such as go1.22.3, or latest for the newest Go release")
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent module proxy requests")
+	cmd.Flags().BoolVar(&major, "major", false, "move the given modules to the module path of their highest major version, such as /v2")
+	cmd.Flags().BoolVar(&opts.RewriteImports, "rewrite-imports", false, "with --major, also rewrite the imports of the moved modules in the Go files of the module")
+	cmd.Flags().BoolVarP(&interact, "interactive", "i", false, "ask about every module with an update: accept it, skip it or pick another version")
+	cmd.MarkFlagsMutuallyExclusive("major", "all")
+	cmd.MarkFlagsMutuallyExclusive("major", "within")
+	cmd.MarkFlagsMutuallyExclusive("major", "toolchain")
+	cmd.MarkFlagsMutuallyExclusive("major", "interactive")
+	filter.register(cmd)
+	return cmd
+}
+
+// planInteractive asks about the updates of the requirements on paths (all
+// requirements if paths is empty) on the terminal and plans the accepted
+// ones. The questions go to standard error, so that standard output only
+// carries the result.
+func planInteractive(ctx context.Context, file string, paths []string, opts pin.UpdateOptions) (*pin.Result, error) {
+	found, err := pin.FindUpdates(ctx, file, paths, opts)
+	if err != nil {
+		return nil, err
+	}
+	infos, errs := workpool.Map(opts.Concurrency, opts.FailFast, found, func(c pin.Candidate) (*proxy.Info, error) {
+		return opts.Proxy.Info(ctx, c.Path, c.Newer[0])
+	})
+	cands := make([]interactive.Candidate, len(found))
+	for i, c := range found {
+		cands[i] = interactive.Candidate{
+			Path:     c.Path,
+			Current:  c.Version,
+			Versions: c.Newer,
+			Delta:    versions.DeltaOf(c.Version, c.Newer[0]),
+			Repo:     interactive.RepoURL(c.Path),
+		}
+		// A missing release date is no reason to stop.
+		if errs[i] == nil {
+			cands[i].Time = infos[i].Time
+		}
+	}
+	if len(cands) == 0 {
+		return pin.PlanVersions(ctx, file, nil, opts)
+	}
+	targets, err := interactive.Select(cands, interactive.NewTerminal(os.Stdin, rep.Err))
+	if err != nil {
+		return nil, err
+	}
+	return pin.PlanVersions(ctx, file, targets, opts)
+}
+
+func printUpdateSummary(out io.Writer, res *pin.Result) {
+	if len(res.Added) == 0 && len(res.Changed) == 0 && len(res.Removed) == 0 && res.Toolchain == nil {
+		fmt.Fprintln(out, "no updates available")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tOLD\tNEW")
+	for _, c := range res.Changed {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Path, c.Old, c.New)
+	}
+	for _, c := range res.Removed {
+		fmt.Fprintf(tw, "%s\t%s\t-\n", c.Path, c.Old)
+	}
+	for _, c := range res.Added {
+		fmt.Fprintf(tw, "%s\t-\t%s\n", c.Path, c.New)
+	}
+	if t := res.Toolchain; t != nil {
+		old := t.Old
+		if old == "" {
+			old = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", t.Path, old, t.New)
+	}
+	tw.Flush()
+	if len(res.Sources) > 0 {
+		n := 0
+		for _, s := range res.Sources {
+			n += s.Imports
+		}
+		fmt.Fprintf(out, "rewrote %d imports in %d files\n", n, len(res.Sources))
+	}
+}
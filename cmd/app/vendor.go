@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/vendored"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// vendorResult is the JSON result of vendor verify and vendor sync, where
+// Synced is set if the vendor directory was rebuilt.
+type vendorResult struct {
+	File   string           `json:"file"`
+	Drift  []vendored.Drift `json:"drift"`
+	Synced bool             `json:"synced"`
+}
+
+func newVendorCmd() *cobra.Command {
+	var (
+		file        string
+		noHash      bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "Verify and sync the vendor directory",
+	}
+	cmd.PersistentFlags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.PersistentFlags().BoolVar(&noHash, "no-hash", false, "only compare the versions of vendor/modules.txt with go.mod, not the vendored files")
+	cmd.PersistentFlags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of modules read at the same time")
+
+	verify := func(ctx context.Context) ([]vendored.Drift, error) {
+		m, err := gomod.Load(file)
+		if err != nil {
+			return nil, err
+		}
+		v := &vendored.Verifier{Hashes: !noHash, Concurrency: concurrency, FailFast: failFast}
+		if v.Hashes {
+			env, err := gocmd.Env("GOMODCACHE")
+			if err != nil {
+				return nil, err
+			}
+			v.ModCache = env["GOMODCACHE"]
+			if v.Proxy, err = newProxyClient(); err != nil {
+				return nil, err
+			}
+		}
+		drift, err := v.Verify(ctx, m)
+		if drift == nil {
+			drift = []vendored.Drift{}
+		}
+		return drift, err
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Fail when vendor/ does not match go.mod or the vendored module versions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			drift, err := verify(cmd.Context())
+			if err != nil {
+				return err
+			}
+			err = rep.Result(vendorResult{File: file, Drift: drift}, func(out io.Writer) error {
+				if len(drift) == 0 {
+					fmt.Fprintf(out, "%s: vendor directory matches\n", file)
+					return nil
+				}
+				printDrift(out, drift)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(drift) > 0 {
+				return &exitError{code: report.ExitViolations}
+			}
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "sync",
+		Short: "Run go mod vendor if vendor/ has drifted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			drift, err := verify(cmd.Context())
+			if err != nil {
+				return err
+			}
+			res := vendorResult{File: file, Drift: drift}
+			if len(drift) > 0 {
+				if err := gocmd.Vendor(cmd.Context(), filepath.Dir(file)); err != nil {
+					return err
+				}
+				res.Synced = true
+			}
+			return rep.Result(res, func(out io.Writer) error {
+				if !res.Synced {
+					fmt.Fprintf(out, "%s: vendor directory matches, nothing to do\n", file)
+					return nil
+				}
+				printDrift(out, drift)
+				fmt.Fprintf(out, "%s: ran go mod vendor\n", file)
+				return nil
+			})
+		},
+	})
+	return cmd
+}
+
+func printDrift(out io.Writer, drift []vendored.Drift) {
+	for _, d := range drift {
+		switch {
+		case d.Path == "":
+			fmt.Fprintf(out, "vendor/%s: %s\n", d.File, d.Reason)
+		case d.File != "":
+			fmt.Fprintf(out, "%s %s: vendor/%s: %s\n", d.Path, d.Version, d.File, d.Reason)
+		default:
+			fmt.Fprintf(out, "%s %s: %s\n", d.Path, d.Version, d.Reason)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/checksum"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/report"
+	"pin-go-dependencies/internal/workpool"
+)
+
+func newVerifyCmd() *cobra.Command {
+	var (
+		file        string
+		jsonOut     bool
+		concurrency int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify go.sum hashes against the checksum database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := requireNetwork(cmd, "queries the checksum database"); err != nil {
+				return err
+			}
+			sum, err := gosum.Read(file)
+			if err != nil {
+				return err
+			}
+			v, err := newChecksumVerifier()
+			if err != nil {
+				return err
+			}
+			results, err := v.Verify(cmd.Context(), sum.Lines, concurrency, failFast)
+			if err != nil {
+				return err
+			}
+
+			if results == nil {
+				results = []checksum.Result{}
+			}
+			err = rep.Result(results, func(out io.Writer) error {
+				if jsonOut {
+					return writeJSON(out, results)
+				}
+				tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "MODULE\tVERSION\tSTATUS\tNOTE")
+				for _, r := range results {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Path, r.Version, r.Status, r.Note)
+				}
+				return tw.Flush()
+			})
+			if err != nil {
+				return err
+			}
+			for _, r := range results {
+				if r.Status == checksum.StatusMismatch {
+					return &exitError{code: report.ExitViolations}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.sum", "path to the go.sum file to verify")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the results as JSON")
+	cmd.Flags().IntVar(&concurrency, "concurrency", workpool.DefaultSize(), "maximum number of concurrent checksum database lookups")
+	return cmd
+}
+
+// newChecksumVerifier returns a verifier for the checksum database of the go
+// environment, keeping its state next to the proxy cache.
+func newChecksumVerifier() (*checksum.Verifier, error) {
+	dir, err := proxy.DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	disabled, _ := noCache()
+	return checksum.FromEnv(checksum.Options{
+		Dir:     filepath.Join(dir, "sumdb"),
+		NoCache: disabled,
+		Log:     rep.Err,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"pin-go-dependencies/internal/modgraph"
+	"pin-go-dependencies/internal/report"
+)
+
+// whyResult is the JSON output of the why command.
+type whyResult struct {
+	Module  string     `json:"module"`
+	Version string     `json:"version"`
+	Chains  [][]string `json:"chains"`
+}
+
+func newWhyCmd() *cobra.Command {
+	var (
+		file    string
+		all     bool
+		jsonOut bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "why <module>",
+		Short: "Show the requirement chains that bring a module into the build list",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := args[0]
+			g, err := modgraph.Load(cmd.Context(), filepath.Dir(file))
+			if err != nil {
+				return err
+			}
+			g = g.Pinned()
+			version, ok := g.Selected()[target]
+			if !ok || target == g.Main.Path {
+				return &exitError{code: report.ExitViolations, err: fmt.Errorf("%s is not in the build list", target)}
+			}
+			chains := g.ShortestChains(target)
+			if all {
+				chains = g.AllChains(target)
+			}
+
+			res := whyResult{Module: target, Version: version, Chains: [][]string{}}
+			for _, c := range chains {
+				names := make([]string, len(c))
+				for i, n := range c {
+					names[i] = modgraph.Node(n)
+				}
+				res.Chains = append(res.Chains, names)
+			}
+			return rep.Result(res, func(out io.Writer) error {
+				if jsonOut {
+					return writeJSON(out, res)
+				}
+				fmt.Fprintf(out, "# %s@%s\n", target, version)
+				for i, c := range res.Chains {
+					if i > 0 {
+						fmt.Fprintln(out)
+					}
+					for depth, n := range c {
+						fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", depth), n)
+					}
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "go.mod", "path to the go.mod file")
+	cmd.Flags().BoolVar(&all, "all", false, "print every requirement chain instead of only the shortest ones")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the chains as JSON")
+	return cmd
+}
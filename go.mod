@@ -1,10 +1,13 @@
 module pin-go-dependencies
 
-go 1.20
-
-require github.com/spf13/cobra v1.8.0
+go 1.21
 
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/google/licensecheck v0.3.1
+	github.com/spf13/cobra v1.8.0
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/mod v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
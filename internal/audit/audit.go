@@ -0,0 +1,99 @@
+// Package audit reports known vulnerabilities of the modules in a build
+// list.
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/osv"
+)
+
+// Finding is a vulnerability affecting a module version of the build list.
+type Finding struct {
+	Path     string       `json:"path"`
+	Version  string       `json:"version"`
+	ID       string       `json:"id"`
+	Aliases  []string     `json:"aliases,omitempty"`
+	Summary  string       `json:"summary"`
+	Severity osv.Severity `json:"severity"`
+	// Fixed is the first version without the vulnerability, if known.
+	Fixed string `json:"fixed,omitempty"`
+}
+
+// Modules returns the module versions built by the module whose go.mod is
+// file: the build list with replacements applied. Modules replaced by a
+// directory have no published version and are left out.
+func Modules(ctx context.Context, file string) ([]module.Version, error) {
+	mods, err := gocmd.ListModules(ctx, filepath.Dir(file), gocmd.ListOptions{GoWork: "off"})
+	if err != nil {
+		return nil, err
+	}
+	var list []module.Version
+	for _, m := range mods {
+		switch {
+		case m.Main || m.Version == "":
+		case m.Replace == nil:
+			list = append(list, module.Version{Path: m.Path, Version: m.Version})
+		case m.Replace.Version != "":
+			list = append(list, module.Version{Path: m.Replace.Path, Version: m.Replace.Version})
+		}
+	}
+	return list, nil
+}
+
+// Run looks up the vulnerabilities of mods in src. Vulnerabilities whose ID
+// or one of whose aliases is in ignore are left out. Findings are sorted
+// from most to least severe, then by module and ID.
+func Run(src osv.Source, mods []module.Version, ignore []string) ([]Finding, error) {
+	vulns, err := src.Query(mods)
+	if err != nil {
+		return nil, err
+	}
+	ignored := make(map[string]bool, len(ignore))
+	for _, id := range ignore {
+		ignored[id] = true
+	}
+
+	var findings []Finding
+	for i, mv := range mods {
+	Vulns:
+		for _, v := range vulns[i] {
+			for _, id := range append([]string{v.ID}, v.Aliases...) {
+				if ignored[id] {
+					continue Vulns
+				}
+			}
+			_, fixed := v.Affects(mv)
+			summary := v.Summary
+			if summary == "" {
+				summary, _, _ = strings.Cut(strings.TrimSpace(v.Details), "\n")
+			}
+			findings = append(findings, Finding{
+				Path:     mv.Path,
+				Version:  mv.Version,
+				ID:       v.ID,
+				Aliases:  v.Aliases,
+				Summary:  summary,
+				Severity: v.Rating(),
+				Fixed:    fixed,
+			})
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.ID < b.ID
+	})
+	return findings, nil
+}
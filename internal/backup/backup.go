@@ -0,0 +1,239 @@
+// Package backup keeps snapshots of go.mod and go.sum, and of any Go files
+// whose imports are rewritten, taken before the tool rewrites them, so that
+// a bad run can be undone.
+//
+// Snapshots live in a .pin-backup directory next to go.mod, one directory
+// per snapshot named after the UTC time it was taken, with a manifest
+// recording a one-line summary of the change and which files existed.
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"pin-go-dependencies/internal/fsutil"
+)
+
+const (
+	// DirName is the name of the backup directory next to go.mod.
+	DirName = ".pin-backup"
+	// DefaultKeep is the number of snapshots kept by default.
+	DefaultKeep = 5
+
+	manifestName = "manifest.json"
+	timeLayout   = "20060102T150405.000Z"
+)
+
+// Snapshot is a saved state of the files of a module.
+type Snapshot struct {
+	// Name identifies the snapshot; it is the name of its directory.
+	Name    string    `json:"-"`
+	Time    time.Time `json:"-"`
+	Summary string    `json:"summary"`
+	// Files are the saved files, by slash-separated path relative to the
+	// module directory. Absent lists the files that did not exist, which
+	// undoing removes.
+	Files  []string `json:"files"`
+	Absent []string `json:"absent,omitempty"`
+}
+
+// Save snapshots files, which must all be in dir or below it, into the
+// backup directory of dir and then removes all but the newest keep
+// snapshots. Files that do not exist are recorded as absent.
+func Save(dir string, keep int, summary string, files ...string) (*Snapshot, error) {
+	root := filepath.Join(dir, DirName)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	// Keep the backups out of version control without touching the
+	// repository's own ignore rules.
+	ignore := filepath.Join(root, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := os.WriteFile(ignore, []byte("*\n"), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	s := &Snapshot{Time: now, Summary: summary}
+	s.Name = now.Format(timeLayout)
+	path := filepath.Join(root, s.Name)
+	for i := 2; ; i++ {
+		err := os.Mkdir(path, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		s.Name = fmt.Sprintf("%s-%d", now.Format(timeLayout), i)
+		path = filepath.Join(root, s.Name)
+	}
+
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil || !filepath.IsLocal(rel) {
+			os.RemoveAll(path)
+			return nil, fmt.Errorf("%s is not in %s", f, dir)
+		}
+		data, err := os.ReadFile(f)
+		switch {
+		case os.IsNotExist(err):
+			s.Absent = append(s.Absent, filepath.ToSlash(rel))
+			continue
+		case err != nil:
+			os.RemoveAll(path)
+			return nil, err
+		}
+		saved := filepath.Join(path, rel)
+		if err := os.MkdirAll(filepath.Dir(saved), 0o755); err != nil {
+			os.RemoveAll(path)
+			return nil, err
+		}
+		if err := os.WriteFile(saved, data, 0o644); err != nil {
+			os.RemoveAll(path)
+			return nil, err
+		}
+		s.Files = append(s.Files, filepath.ToSlash(rel))
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	// The manifest is written last: a directory without one is an
+	// interrupted snapshot, which List ignores.
+	if err := fsutil.WriteFileAtomic(filepath.Join(path, manifestName), buf.Bytes(), 0o644); err != nil {
+		os.RemoveAll(path)
+		return nil, err
+	}
+	return s, Prune(dir, keep)
+}
+
+// List returns the snapshots in the backup directory of dir, newest first.
+func List(dir string) ([]Snapshot, error) {
+	root := filepath.Join(dir, DirName)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		stamp, _, _ := strings.Cut(e.Name(), "-")
+		t, err := time.Parse(timeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(root, e.Name(), manifestName))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := Snapshot{Name: e.Name(), Time: t}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Join(root, e.Name(), manifestName), err)
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return newer(list[i], list[j]) })
+	return list, nil
+}
+
+// newer reports whether a was taken after b. Snapshots taken in the same
+// millisecond are ordered by their numeric suffix.
+func newer(a, b Snapshot) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.After(b.Time)
+	}
+	if len(a.Name) != len(b.Name) {
+		return len(a.Name) > len(b.Name)
+	}
+	return a.Name > b.Name
+}
+
+// Find returns the snapshot of dir called name, or the newest one if name
+// is empty.
+func Find(dir, name string) (*Snapshot, error) {
+	list, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no backups in %s", filepath.Join(dir, DirName))
+	}
+	if name == "" {
+		return &list[0], nil
+	}
+	for i := range list {
+		if list[i].Name == name {
+			return &list[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no backup %s in %s", name, filepath.Join(dir, DirName))
+}
+
+// Restore puts the files of s back into dir, removing the ones that were
+// absent, and then removes s and every newer snapshot, so that undoing
+// again steps further back.
+func Restore(dir string, s *Snapshot) error {
+	path := filepath.Join(dir, DirName, s.Name)
+	for _, f := range s.Files {
+		data, err := os.ReadFile(filepath.Join(path, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+		if err := fsutil.ReplaceFile(filepath.Join(dir, filepath.FromSlash(f)), data); err != nil {
+			return err
+		}
+	}
+	for _, f := range s.Absent {
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(f))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	list, err := List(dir)
+	if err != nil {
+		return err
+	}
+	for _, o := range list {
+		if o.Name == s.Name || newer(o, *s) {
+			if err := os.RemoveAll(filepath.Join(dir, DirName, o.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Prune removes all but the newest keep snapshots of dir.
+func Prune(dir string, keep int) error {
+	list, err := List(dir)
+	if err != nil || len(list) <= keep {
+		return err
+	}
+	for _, s := range list[keep:] {
+		if err := os.RemoveAll(filepath.Join(dir, DirName, s.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
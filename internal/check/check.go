@@ -0,0 +1,296 @@
+// Package check reports requirements of a go.mod that are not reproducibly
+// pinned.
+package check
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/generate"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/lockfile"
+	"pin-go-dependencies/internal/mvs"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/toolchain"
+	"pin-go-dependencies/internal/tools"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Rules identifying the kind of a violation.
+const (
+	RulePseudoVersion     = "pseudo-version"
+	RuleUnpinnedTool      = "unpinned-tool"
+	RuleToolPseudoVersion = "tool-pseudo-version"
+	RuleMissingSum        = "missing-sum"
+	RuleRetracted         = "retracted"
+	RuleLock              = "lock"
+	RuleGoVersion         = "go-version"
+	RuleToolchain         = "toolchain"
+	RuleMVS               = "mvs"
+	RuleGenerate          = "unpinned-generate"
+)
+
+// Options selects the optional policies enforced by Run.
+type Options struct {
+	// NoPseudo reports requirements on pseudo-versions.
+	NoPseudo bool
+	// Proxy, if set, is used to report requirements on versions retracted
+	// by their authors.
+	Proxy       *proxy.Client
+	Concurrency int
+	// FailFast stops the lookups at the first module that fails.
+	FailFast bool
+	// Ignore, if set, reports modules whose violations are left out.
+	Ignore func(path string) bool
+	// Lock, if set, reports every difference between the build list and
+	// the lock.
+	Lock *lockfile.Lock
+	// MinGo, if set, reports a go directive older than this Go version.
+	MinGo string
+	// RequireToolchain reports a missing toolchain directive.
+	RequireToolchain bool
+	// AllowPseudoTool, if set, reports tools that may be provided at a
+	// pseudo-version.
+	AllowPseudoTool func(path string) bool
+	// MVS reports requirements on a lower version than minimal version
+	// selection over the requirement graph picks. It needs Proxy.
+	MVS bool
+	// Generate reports go:generate directives of the module running a tool
+	// with go run at a floating version.
+	Generate bool
+	// Rules is the policy of .pin.yaml.
+	Rules Rules
+	// Exceptions mark the violations they cover, which are still reported.
+	Exceptions []Exception
+}
+
+// Violation is a single finding.
+type Violation struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+	// File and Line locate the directive or import the violation is
+	// about; Line is 0 if there is none, such as for a missing directive.
+	File string `json:"file"`
+	Line int    `json:"line,omitempty"`
+	// Exception is the justification of the exception covering the
+	// violation, if any. Such violations do not fail the check.
+	Exception string `json:"exception,omitempty"`
+}
+
+// Run checks m against the go.sum next to it. If the retractions of some
+// requirements cannot be looked up, Run still checks the others and returns
+// their violations, as a non-nil slice, along with the lookup errors.
+func Run(ctx context.Context, m *gomod.Module, opts Options) ([]Violation, error) {
+	sum, err := gosum.Read(filepath.Join(filepath.Dir(m.Filename), "go.sum"))
+	if err != nil {
+		return nil, err
+	}
+
+	var vs []Violation
+	var lookupErr error
+	for _, r := range m.Requires() {
+		if opts.NoPseudo && module.IsPseudoVersion(r.Version) {
+			vs = append(vs, Violation{
+				Path:    r.Path,
+				Version: r.Version,
+				Rule:    RulePseudoVersion,
+				Reason:  "pseudo-version of an untagged commit",
+				Line:    r.Line,
+			})
+		}
+		if v, ok := missingSum(sum, r); ok {
+			vs = append(vs, Violation{
+				Path:    v.Path,
+				Version: v.Version,
+				Rule:    RuleMissingSum,
+				Reason:  "no go.sum entry for " + v.String() + "/go.mod",
+				Line:    r.Line,
+			})
+		}
+	}
+	if opts.Proxy != nil {
+		var rvs []Violation
+		rvs, lookupErr = retracted(ctx, opts.Proxy, m, opts.Concurrency, opts.FailFast)
+		vs = append(vs, rvs...)
+	}
+	if opts.MVS {
+		if opts.Proxy == nil {
+			return nil, errors.New("a module proxy is needed to run minimal version selection")
+		}
+		found, err := mvs.NewReqs(opts.Proxy, m).Understated(ctx, opts.Concurrency, opts.FailFast)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range found {
+			vs = append(vs, Violation{Path: u.Path, Version: u.Version, Rule: RuleMVS, Reason: "minimal version selection picks " + u.Selected, Line: u.Line})
+		}
+	}
+	if opts.Lock != nil {
+		got, err := lockfile.Capture(ctx, m.Filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, mm := range lockfile.Compare(opts.Lock, got) {
+			vs = append(vs, Violation{Path: mm.Path, Version: mm.Version, Rule: RuleLock, Reason: mm.Reason, Line: m.Line(mm.Path)})
+		}
+	}
+	vs = append(vs, policy(m, opts.Rules, time.Now())...)
+	vs = append(vs, directives(m, opts)...)
+	tvs, err := toolViolations(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	vs = append(vs, tvs...)
+	if opts.Generate {
+		found, err := generate.Walk(m, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range found {
+			vs = append(vs, Violation{
+				Path:    f.Package,
+				Version: f.Version,
+				Rule:    RuleGenerate,
+				Reason:  "run by go:generate at a floating version, " + f.Reason,
+				File:    f.File,
+				Line:    f.Line,
+			})
+		}
+	}
+
+	if opts.Ignore != nil {
+		kept := vs[:0]
+		for _, v := range vs {
+			if !opts.Ignore(v.Path) {
+				kept = append(kept, v)
+			}
+		}
+		vs = kept
+	}
+	except(vs, opts.Exceptions)
+	for i := range vs {
+		if vs[i].File == "" {
+			vs[i].File = m.Filename
+		}
+	}
+	sort.SliceStable(vs, func(i, j int) bool { return vs[i].Path < vs[j].Path })
+	if vs == nil && lookupErr != nil {
+		vs = []Violation{}
+	}
+	return vs, lookupErr
+}
+
+// directives reports a go directive older than opts.MinGo and a missing
+// toolchain directive. Their violations carry the directive name as path.
+func directives(m *gomod.Module, opts Options) []Violation {
+	var vs []Violation
+	if opts.MinGo != "" {
+		switch goVersion := m.GoVersion(); {
+		case goVersion == "":
+			vs = append(vs, Violation{Path: "go", Version: "none", Rule: RuleGoVersion, Reason: "no go directive, expected at least " + opts.MinGo})
+		case toolchain.Compare(goVersion, opts.MinGo) < 0:
+			vs = append(vs, Violation{Path: "go", Version: goVersion, Rule: RuleGoVersion, Reason: "go directive older than the minimum " + opts.MinGo, Line: m.GoLine()})
+		}
+	}
+	if opts.RequireToolchain && m.File.Toolchain == nil {
+		vs = append(vs, Violation{Path: "toolchain", Version: "none", Rule: RuleToolchain, Reason: "no toolchain directive; run pin --pin-toolchain"})
+	}
+	return vs
+}
+
+// retracted reports the requirements pinned to retracted versions, and the
+// errors of the requirements it could not look up. Replaced requirements
+// are skipped since the build does not use them.
+func retracted(ctx context.Context, c *proxy.Client, m *gomod.Module, concurrency int, failFast bool) ([]Violation, error) {
+	var reqs []gomod.Require
+	for _, r := range m.Requires() {
+		if r.Replace == nil {
+			reqs = append(reqs, r)
+		}
+	}
+	found, errs := workpool.Map(concurrency, failFast, reqs, func(r gomod.Require) (*resolve.Retraction, error) {
+		rt, err := resolve.CheckRetracted(ctx, c, r.Path, r.Version)
+		return rt, failure.Module(r.Path, r.Version, err)
+	})
+	var vs []Violation
+	for i, rt := range found {
+		if rt == nil {
+			continue
+		}
+		reason := "retracted by " + rt.String()
+		if rt.Rationale != "" {
+			reason += ": " + rt.Rationale
+		}
+		vs = append(vs, Violation{Path: reqs[i].Path, Version: reqs[i].Version, Rule: RuleRetracted, Reason: reason, Line: reqs[i].Line})
+	}
+	return vs, errors.Join(errs...)
+}
+
+// missingSum returns the module version whose go.mod hash must be recorded
+// for r, and whether it is missing from sum. Requirements replaced by a
+// directory have no checksum at all.
+func missingSum(sum *gosum.Sum, r gomod.Require) (module.Version, bool) {
+	mv := module.Version{Path: r.Path, Version: r.Version}
+	if r.Replace != nil {
+		if r.Replace.Version == "" {
+			return mv, false
+		}
+		mv = *r.Replace
+	}
+	return mv, !sum.Has(mv.Path, mv.Version+"/go.mod")
+}
+
+// toolViolations reports the tools of tool directives and tools.go files
+// that no required module provides, and those provided at a pseudo-version
+// unless opts allows it. A tool imported by several tools.go files is
+// reported once.
+func toolViolations(m *gomod.Module, opts Options) ([]Violation, error) {
+	ts, err := tools.Find(m)
+	if err != nil {
+		return nil, err
+	}
+	var vs []Violation
+	seen := make(map[string]bool)
+	for _, t := range ts {
+		if seen[t.Path] {
+			continue
+		}
+		seen[t.Path] = true
+		file := m.Filename
+		if t.Source != tools.SourceDirective {
+			file = filepath.Join(filepath.Dir(m.Filename), filepath.FromSlash(t.Source))
+		}
+		switch {
+		case !t.Provided():
+			vs = append(vs, Violation{
+				Path:    t.Path,
+				Version: "latest",
+				Rule:    RuleUnpinnedTool,
+				Reason:  "tool of " + t.Source + " has no require entry and resolves to the latest version",
+				File:    file,
+				Line:    t.Line,
+			})
+		case module.IsPseudoVersion(t.Version) && !opts.NoPseudo && (opts.AllowPseudoTool == nil || !opts.AllowPseudoTool(t.Path)):
+			// With NoPseudo the requirement itself is reported already.
+			vs = append(vs, Violation{
+				Path:    t.Path,
+				Version: t.Version,
+				Rule:    RuleToolPseudoVersion,
+				Reason:  "tool provided by " + t.Module + " at a pseudo-version of an untagged commit",
+				File:    file,
+				Line:    t.Line,
+			})
+		}
+	}
+	return vs, nil
+}
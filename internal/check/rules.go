@@ -0,0 +1,144 @@
+package check
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gomod"
+)
+
+// Rules of the policy configured in .pin.yaml.
+const (
+	RuleForbidPseudoVersions = "forbid-pseudo-versions"
+	RuleForbidModules        = "forbid-modules"
+	RuleMajorVersionSuffix   = "require-major-version-suffix-match"
+	RuleMinVersion           = "min-version"
+)
+
+// IsRule reports whether name is the name of a rule Run reports violations
+// of, which an exception may refer to.
+func IsRule(name string) bool {
+	switch name {
+	case RulePseudoVersion, RuleUnpinnedTool, RuleToolPseudoVersion, RuleMissingSum,
+		RuleRetracted, RuleLock, RuleGoVersion, RuleToolchain, RuleMVS, RuleGenerate,
+		RuleForbidPseudoVersions, RuleForbidModules, RuleMajorVersionSuffix, RuleMinVersion:
+		return true
+	}
+	return false
+}
+
+// Rules is the policy configured in .pin.yaml. Since pin requires the whole
+// build list, the rules are evaluated on the requirements of go.mod.
+type Rules struct {
+	// ForbidPseudo reports requirements on pseudo-versions. If MaxPseudoAge
+	// is set, only those whose commit is older are reported.
+	ForbidPseudo bool
+	MaxPseudoAge time.Duration
+	// ForbidModule, if set, reports modules that must not be required,
+	// either directly or as a replacement.
+	ForbidModule func(path string) bool
+	// MajorSuffix reports requirements whose major version does not match
+	// the major version suffix of their path, including +incompatible
+	// versions, which have none.
+	MajorSuffix bool
+	MinVersions []MinVersion
+}
+
+// MinVersion is the lowest version allowed for the modules matching Pattern.
+type MinVersion struct {
+	Pattern string
+	Version string
+}
+
+// Exception leaves out the violations of Rule for the modules matching
+// Module. The violations are still reported, with the justification.
+type Exception struct {
+	Rule          string
+	Module        string
+	Justification string
+}
+
+// policy reports the violations of rules by the requirements of m, judging
+// the age of pseudo-versions as of now.
+func policy(m *gomod.Module, rules Rules, now time.Time) []Violation {
+	var vs []Violation
+	for _, r := range m.Requires() {
+		add := func(rule, reason string) {
+			vs = append(vs, Violation{Path: r.Path, Version: r.Version, Rule: rule, Reason: reason, Line: r.Line})
+		}
+		if rules.ForbidPseudo && module.IsPseudoVersion(r.Version) {
+			t, err := module.PseudoVersionTime(r.Version)
+			switch {
+			case rules.MaxPseudoAge == 0:
+				add(RuleForbidPseudoVersions, "pseudo-version of an untagged commit")
+			case err == nil && now.Sub(t) > rules.MaxPseudoAge:
+				add(RuleForbidPseudoVersions, "pseudo-version of a commit from "+t.Format("2006-01-02")+", older than the maximum age")
+			}
+		}
+		if rules.ForbidModule != nil {
+			if rules.ForbidModule(r.Path) {
+				add(RuleForbidModules, "forbidden module")
+			} else if r.Replace != nil && rules.ForbidModule(r.Replace.Path) {
+				add(RuleForbidModules, "replaced by the forbidden module "+r.Replace.Path)
+			}
+		}
+		if rules.MajorSuffix {
+			if reason := majorMismatch(r.Path, r.Version); reason != "" {
+				add(RuleMajorVersionSuffix, reason)
+			}
+		}
+		for _, mv := range rules.MinVersions {
+			if module.MatchPrefixPatterns(mv.Pattern, r.Path) && semver.Compare(r.Version, mv.Version) < 0 {
+				add(RuleMinVersion, "older than the minimum version "+mv.Version)
+				break
+			}
+		}
+	}
+	return vs
+}
+
+// majorMismatch describes how version does not match the major version
+// suffix of path, or returns "" if it does.
+func majorMismatch(path, version string) string {
+	_, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok {
+		return ""
+	}
+	if strings.HasSuffix(version, "+incompatible") {
+		return "+incompatible version without the major version suffix " + semver.Major(version) + " in its path"
+	}
+	if err := module.CheckPathMajor(version, pathMajor); err != nil {
+		want := semver.Major(version)
+		if want == "v0" || want == "v1" {
+			return "major version " + want + " of a module path with the suffix " + strings.TrimLeft(pathMajor, "/.")
+		}
+		return "major version " + want + " without the matching suffix /" + want + " in the module path"
+	}
+	return ""
+}
+
+// except marks the violations covered by an exception.
+func except(vs []Violation, exceptions []Exception) {
+	for i := range vs {
+		for _, e := range exceptions {
+			if e.Rule == vs[i].Rule && module.MatchPrefixPatterns(e.Module, vs[i].Path) {
+				vs[i].Exception = e.Justification
+				break
+			}
+		}
+	}
+}
+
+// Failing returns the number of violations no exception covers.
+func Failing(vs []Violation) int {
+	n := 0
+	for _, v := range vs {
+		if v.Exception == "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,206 @@
+// Package checksum verifies go.sum entries against a checksum database as
+// described in `go help module-auth`.
+package checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Statuses of a verified go.sum line.
+const (
+	StatusOK       = "OK"
+	StatusMismatch = "MISMATCH"
+	StatusSkipped  = "SKIPPED"
+)
+
+// knownKeys are the verifier keys of the databases the go command knows by
+// name alone.
+var knownKeys = map[string]string{
+	"sum.golang.org":       "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8",
+	"sum.golang.google.cn": "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8",
+}
+
+// Database identifies a checksum database.
+type Database struct {
+	Name string
+	Key  string
+	// URL is where the database is served if it is not reachable through
+	// the module proxy.
+	URL string
+}
+
+// ParseGOSUMDB parses a value of the GOSUMDB environment variable. It
+// returns nil if checksum verification is turned off.
+func ParseGOSUMDB(s string) (*Database, error) {
+	f := strings.Fields(s)
+	if len(f) == 0 {
+		f = []string{"sum.golang.org"}
+	}
+	if f[0] == "off" {
+		return nil, nil
+	}
+	if len(f) > 2 {
+		return nil, fmt.Errorf("invalid GOSUMDB %q: too many fields", s)
+	}
+	db := &Database{Key: f[0]}
+	if key, ok := knownKeys[f[0]]; ok {
+		db.Key = key
+		db.URL = "https://" + f[0]
+	}
+	name, err := noteVerifier(db.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GOSUMDB %q: %w", s, err)
+	}
+	db.Name = name
+	if db.URL == "" {
+		db.URL = "https://" + db.Name
+	}
+	if len(f) == 2 {
+		db.URL = strings.TrimSuffix(f[1], "/")
+		if !strings.Contains(db.URL, "://") {
+			db.URL = "https://" + db.URL
+		}
+	}
+	return db, nil
+}
+
+// noteVerifier returns the server name of a verifier key of the form
+// name+hash+key.
+func noteVerifier(key string) (string, error) {
+	name, _, ok := strings.Cut(key, "+")
+	if !ok || name == "" {
+		return "", errors.New("malformed verifier key")
+	}
+	return name, nil
+}
+
+// Result is the verification outcome of a single go.sum line.
+type Result struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Status  string `json:"status"`
+	Note    string `json:"note,omitempty"`
+}
+
+// Options configures a Verifier.
+type Options struct {
+	// Dir holds the verified tree state and, unless NoCache is set, the
+	// downloaded lookups and tiles.
+	Dir     string
+	NoCache bool
+	// Log, if set, receives the diagnostics and security errors of the
+	// sumdb client.
+	Log io.Writer
+}
+
+// Verifier checks go.sum lines against a checksum database.
+type Verifier struct {
+	db      *Database
+	ops     *ops
+	private string
+	// privateVar names the variable private came from, for notes.
+	privateVar string
+}
+
+// FromEnv returns a Verifier for the checksum database configured in the go
+// environment through GOSUMDB, GONOSUMDB, GOPRIVATE and GOPROXY.
+func FromEnv(opts Options) (*Verifier, error) {
+	env, err := gocmd.Env("GOSUMDB", "GONOSUMDB", "GOPRIVATE", "GOPROXY")
+	if err != nil {
+		return nil, err
+	}
+	db, err := ParseGOSUMDB(env["GOSUMDB"])
+	if err != nil {
+		return nil, err
+	}
+	v := &Verifier{db: db, private: env["GONOSUMDB"], privateVar: "GONOSUMDB"}
+	if v.private == "" {
+		v.private, v.privateVar = env["GOPRIVATE"], "GOPRIVATE"
+	}
+	if db != nil {
+		v.ops = newOps(db, env["GOPROXY"], opts)
+	}
+	return v, nil
+}
+
+// Verify checks every line against the database. Lines of modules matched
+// by GONOSUMDB (or GOPRIVATE) and lines with hashes other than h1 are
+// skipped. Lookups run on up to concurrency goroutines and, if failFast is
+// set, stop at the first one that fails; the results are in the order of
+// lines. The requests to the database stop when ctx is done.
+func (v *Verifier) Verify(ctx context.Context, lines []gosum.Line, concurrency int, failFast bool) ([]Result, error) {
+	results := make([]Result, len(lines))
+	var todo []int
+	for i, l := range lines {
+		results[i] = Result{Path: l.Path, Version: l.Version}
+		switch {
+		case v.db == nil:
+			results[i].Status, results[i].Note = StatusSkipped, "GOSUMDB=off"
+		case module.MatchPrefixPatterns(v.private, l.Path):
+			results[i].Status, results[i].Note = StatusSkipped, "matched by "+v.privateVar
+		case !strings.HasPrefix(l.Hash, "h1:"):
+			results[i].Status, results[i].Note = StatusSkipped, "unsupported hash "+l.Hash
+		default:
+			todo = append(todo, i)
+		}
+	}
+
+	// The client answers the go.mod line of a module version from the
+	// lookup of the module line, so each version is fetched only once.
+	var client *sumdb.Client
+	if v.db != nil {
+		client = sumdb.NewClient(remote{ops: v.ops, ctx: ctx})
+	}
+	found, errs := workpool.Map(concurrency, failFast, todo, func(i int) ([]string, error) {
+		return client.Lookup(lines[i].Path, lines[i].Version)
+	})
+	for n, i := range todo {
+		if errs[n] != nil {
+			// A version the database does not know is a mismatch, not a
+			// failure. Other remote errors keep their type, which the sumdb
+			// client drops from its message, so that they count as network
+			// failures.
+			own := v.ops.lookupErr(lines[i].Path, lines[i].Version)
+			switch last := v.ops.lastRemoteErr(); {
+			case errors.Is(own, proxy.ErrNotFound):
+				errs[n] = nil
+				results[i].Status, results[i].Note = StatusMismatch, "not in "+v.db.Name
+			case own != nil:
+				errs[n] = fmt.Errorf("%s@%s: %w", lines[i].Path, lines[i].Version, own)
+			case last != nil && !errors.Is(last, proxy.ErrNotFound):
+				errs[n] = fmt.Errorf("%w (%w)", errs[n], last)
+			}
+			continue
+		}
+		want := ""
+		for _, line := range found[n] {
+			if f := strings.Fields(line); len(f) == 3 && f[1] == lines[i].Version {
+				want = f[2]
+			}
+		}
+		switch {
+		case want == "":
+			results[i].Status, results[i].Note = StatusMismatch, "not in "+v.db.Name
+		case want != lines[i].Hash:
+			results[i].Status, results[i].Note = StatusMismatch, v.db.Name+" has "+want
+		default:
+			results[i].Status = StatusOK
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,224 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb"
+
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/proxy"
+)
+
+// ops implements sumdb.ClientOps. The latest verified tree is kept in
+// Dir/<name>/latest, lookups and tiles in Dir/<name>/cache. Like the go
+// command, the database is reached through the first module proxy that
+// supports it and directly otherwise.
+type ops struct {
+	db      *Database
+	goproxy string
+	dir     string
+	noCache bool
+	log     io.Writer
+	http    *http.Client
+
+	once sync.Once
+	base string
+
+	// remoteErrs holds the error of every failed remote read by path, and
+	// lastErr the latest one, since the sumdb client only keeps their
+	// messages.
+	errMu      sync.Mutex
+	remoteErrs map[string]error
+	lastErr    error
+
+	// mu makes the compare-and-swap in WriteConfig atomic within the
+	// process.
+	mu sync.Mutex
+}
+
+func newOps(db *Database, goproxy string, opts Options) *ops {
+	return &ops{
+		db:      db,
+		goproxy: goproxy,
+		dir:     filepath.Join(opts.Dir, db.Name),
+		noCache: opts.NoCache,
+		log:     opts.Log,
+		http:    httpx.NewClient(nil),
+	}
+}
+
+// get requests url, stopping when ctx is done.
+func (o *ops) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return o.http.Do(req)
+}
+
+// remoteBase returns the URL that database paths are appended to.
+func (o *ops) remoteBase(ctx context.Context) string {
+	o.once.Do(func() {
+		o.base = o.db.URL
+		for _, entry := range strings.FieldsFunc(o.goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+			entry = strings.TrimSpace(entry)
+			if entry == "direct" || entry == "off" {
+				break
+			}
+			url := strings.TrimSuffix(entry, "/") + "/sumdb/" + o.db.Name
+			resp, err := o.get(ctx, url+"/supported")
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				o.base = url
+				break
+			}
+		}
+	})
+	return o.base
+}
+
+// remote is the sumdb.ClientOps of one Verify call. Since the interface
+// takes no context, it carries the one of the call, which bounds its remote
+// reads; everything else is shared through ops.
+type remote struct {
+	*ops
+	ctx context.Context
+}
+
+func (r remote) ReadRemote(path string) ([]byte, error) {
+	data, err := r.readRemote(r.ctx, path)
+	if err != nil {
+		r.errMu.Lock()
+		if r.remoteErrs == nil {
+			r.remoteErrs = make(map[string]error)
+		}
+		r.remoteErrs[path] = err
+		r.lastErr = err
+		r.errMu.Unlock()
+	}
+	return data, err
+}
+
+func (o *ops) readRemote(ctx context.Context, path string) ([]byte, error) {
+	url := o.remoteBase(ctx) + path
+	resp, err := o.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &proxy.HTTPError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return data, nil
+}
+
+// lookupErr returns the error of the remote lookup of path at vers, or nil
+// if it did not fail or was not made.
+func (o *ops) lookupErr(path, vers string) error {
+	epath, err := module.EscapePath(path)
+	if err != nil {
+		return nil
+	}
+	evers, err := module.EscapeVersion(strings.TrimSuffix(vers, "/go.mod"))
+	if err != nil {
+		return nil
+	}
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	return o.remoteErrs["/lookup/"+epath+"@"+evers]
+}
+
+// lastRemoteErr returns the error of the latest remote read that failed,
+// such as of a tile, or nil if none did.
+func (o *ops) lastRemoteErr() error {
+	o.errMu.Lock()
+	defer o.errMu.Unlock()
+	return o.lastErr
+}
+
+func (o *ops) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.db.Key), nil
+	}
+	data, err := os.ReadFile(o.configFile(file))
+	if os.IsNotExist(err) {
+		// Start from an empty tree.
+		return nil, nil
+	}
+	return data, err
+}
+
+func (o *ops) WriteConfig(file string, old, data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	name := o.configFile(file)
+	cur, err := os.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if !bytes.Equal(cur, old) {
+		return sumdb.ErrWriteConflict
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(name, data, 0o644)
+}
+
+// configFile maps a config file name such as "sum.golang.org/latest" below
+// the database directory.
+func (o *ops) configFile(file string) string {
+	return filepath.Join(o.dir, filepath.Base(filepath.FromSlash(file)))
+}
+
+func (o *ops) ReadCache(file string) ([]byte, error) {
+	if o.noCache {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(o.cacheFile(file))
+}
+
+func (o *ops) WriteCache(file string, data []byte) {
+	if o.noCache {
+		return
+	}
+	// The cache is only an optimization; failing to write it is harmless.
+	name := o.cacheFile(file)
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err == nil {
+		_ = fsutil.WriteFileAtomic(name, data, 0o644)
+	}
+}
+
+// cacheFile maps a cache file name, which starts with the database name,
+// below the database directory.
+func (o *ops) cacheFile(file string) string {
+	file = strings.TrimPrefix(file, o.db.Name+"/")
+	return filepath.Join(o.dir, "cache", filepath.FromSlash(file))
+}
+
+func (o *ops) Log(msg string) {
+	if o.log != nil {
+		fmt.Fprintln(o.log, msg)
+	}
+}
+
+func (o *ops) SecurityError(msg string) {
+	o.Log(msg)
+}
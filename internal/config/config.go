@@ -0,0 +1,303 @@
+// Package config reads the .pin.yaml file holding the project settings of
+// the tool.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+
+	"pin-go-dependencies/internal/check"
+	"pin-go-dependencies/internal/toolchain"
+)
+
+// FileName is the name of the configuration file.
+const FileName = ".pin.yaml"
+
+// Config is the content of a configuration file. Module lists hold patterns
+// in the syntax of GOPRIVATE: a pattern matches a module path and all paths
+// below it, and may contain glob characters.
+type Config struct {
+	// Exclude lists modules that pin leaves alone.
+	Exclude []string `yaml:"exclude"`
+	// ForbidMajor lists modules that must not be updated to a new major
+	// version.
+	ForbidMajor []string `yaml:"forbidMajor"`
+	Update      Update   `yaml:"update"`
+	Proxy       Proxy    `yaml:"proxy"`
+	Cache       Cache    `yaml:"cache"`
+	Audit       Ignore   `yaml:"audit"`
+	Check       Check    `yaml:"check"`
+	Backup      Backup   `yaml:"backup"`
+	Scripts     Scripts  `yaml:"scripts"`
+	Licenses    Licenses `yaml:"licenses"`
+	Rules       Rules    `yaml:"rules"`
+}
+
+// Update holds the defaults of the update command.
+type Update struct {
+	Within string `yaml:"within"`
+}
+
+// Proxy configures module proxy access.
+type Proxy struct {
+	// URL overrides GOPROXY and may be a list in the same syntax.
+	URL string `yaml:"url"`
+}
+
+// Cache configures the module proxy cache. Unset fields are nil.
+type Cache struct {
+	TTL      *time.Duration `yaml:"ttl"`
+	Disabled *bool          `yaml:"disabled"`
+}
+
+// Backup configures the snapshots taken before go.mod is rewritten. Keep is
+// nil if unset; 0 disables backups.
+type Backup struct {
+	Keep *int `yaml:"keep"`
+}
+
+// Scripts holds the settings of the scan-scripts command.
+type Scripts struct {
+	// Globs are the file name patterns to scan.
+	Globs []string `yaml:"globs"`
+}
+
+// Licenses holds the settings of the licenses command.
+type Licenses struct {
+	// Deny lists the SPDX IDs of licenses that make the command fail.
+	Deny []string `yaml:"deny"`
+	// UnknownFails makes the command fail for modules whose license could
+	// not be classified.
+	UnknownFails bool `yaml:"unknownFails"`
+}
+
+// Check holds the settings of the check command.
+type Check struct {
+	Ignore []string `yaml:"ignore"`
+	// MinGo is the oldest go directive allowed, such as 1.21.
+	MinGo string `yaml:"minGo"`
+	// RequireToolchain reports go.mod files without a toolchain directive.
+	RequireToolchain bool `yaml:"requireToolchain"`
+	// AllowPseudoTools lists tools that may be pinned to a pseudo-version.
+	AllowPseudoTools []string `yaml:"allowPseudoTools"`
+}
+
+// Rules holds the policy rules evaluated by the check command.
+type Rules struct {
+	ForbidPseudoVersions PseudoVersions `yaml:"forbid-pseudo-versions"`
+	// ForbidModules lists modules that must not be in the build list.
+	ForbidModules []string `yaml:"forbid-modules"`
+	// RequireMajorVersionSuffixMatch reports requirements whose major
+	// version does not match the major version suffix of their path.
+	RequireMajorVersionSuffixMatch bool `yaml:"require-major-version-suffix-match"`
+	// MinVersions maps module patterns to the lowest version allowed, in the
+	// form ">= v1.2.3" or just "v1.2.3".
+	MinVersions map[string]string `yaml:"min-version"`
+	Exceptions  []Exception       `yaml:"exceptions"`
+}
+
+// PseudoVersions configures the rule on pseudo-versions. It is set either
+// to true, forbidding all of them, or to a mapping with a max-age, which only
+// forbids those whose commit is older.
+type PseudoVersions struct {
+	Enabled bool `yaml:"-"`
+	// MaxAge is a duration such as 90d or 36h; empty forbids every
+	// pseudo-version.
+	MaxAge string `yaml:"max-age"`
+}
+
+// UnmarshalYAML accepts a boolean as well as a mapping.
+func (p *PseudoVersions) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&p.Enabled)
+	}
+	type plain PseudoVersions
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	p.Enabled = true
+	return nil
+}
+
+// Exception suppresses the violations of a rule for the matching modules.
+// The justification is required and shows up in the report.
+type Exception struct {
+	Rule          string `yaml:"rule"`
+	Module        string `yaml:"module"`
+	Justification string `yaml:"justification"`
+}
+
+// ParseAge parses a duration such as 90d, 2w or 36h: in addition to the
+// units of time.ParseDuration, d stands for days and w for weeks.
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+				return time.Duration(days) * unit, nil
+			}
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// ParseMinVersion parses a minimum version constraint, ">= v1.2.3" or just
+// "v1.2.3", and returns the version.
+func ParseMinVersion(s string) (string, error) {
+	v := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), ">="))
+	if !semver.IsValid(v) {
+		return "", fmt.Errorf("invalid minimum version %q, expected \">= v1.2.3\"", s)
+	}
+	return v, nil
+}
+
+// Ignore holds the findings a command leaves out.
+type Ignore struct {
+	Ignore []string `yaml:"ignore"`
+}
+
+// Find returns the configuration file in dir or the closest of its parent
+// directories, or "" if there is none.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := filepath.Join(dir, FileName)
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and validates the configuration file name.
+func Load(name string) (*Config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data)
+}
+
+// Parse parses configuration file content; name is only used in error
+// messages. Keys that do not belong to Config are an error.
+func Parse(name string, data []byte) (*Config, error) {
+	c := &Config{}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(doc.Content) == 0 {
+		return c, nil
+	}
+	if err := checkKeys(doc.Content[0], reflect.TypeOf(*c), ""); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if w := c.Update.Within; w != "" && w != "patch" && w != "minor" && w != "major" {
+		return nil, fmt.Errorf("%s: invalid update.within %q, expected patch, minor or major", name, w)
+	}
+	if v := c.Check.MinGo; v != "" && !toolchain.IsValid(v) {
+		return nil, fmt.Errorf("%s: invalid check.minGo %q, expected a Go version such as 1.21", name, v)
+	}
+	if k := c.Backup.Keep; k != nil && *k < 0 {
+		return nil, fmt.Errorf("%s: invalid backup.keep %d, expected 0 or more", name, *k)
+	}
+	if err := c.Rules.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return c, nil
+}
+
+// validate checks the values of the rules the decoder cannot check.
+func (r *Rules) validate() error {
+	if a := r.ForbidPseudoVersions.MaxAge; a != "" {
+		if _, err := ParseAge(a); err != nil {
+			return fmt.Errorf("invalid rules.forbid-pseudo-versions.max-age %q, expected a duration such as 90d", a)
+		}
+	}
+	for pattern, v := range r.MinVersions {
+		if _, err := ParseMinVersion(v); err != nil {
+			return fmt.Errorf("rules.min-version.%s: %w", pattern, err)
+		}
+	}
+	for i, e := range r.Exceptions {
+		switch {
+		case e.Rule == "" || e.Module == "":
+			return fmt.Errorf("rules.exceptions[%d]: rule and module are required", i)
+		case !check.IsRule(e.Rule):
+			return fmt.Errorf("rules.exceptions[%d]: unknown rule %q", i, e.Rule)
+		case strings.TrimSpace(e.Justification) == "":
+			return fmt.Errorf("rules.exceptions[%d]: an exception for %s on %s needs a justification", i, e.Rule, e.Module)
+		}
+	}
+	return nil
+}
+
+// checkKeys reports the first mapping key in node that has no field in the
+// struct type t, naming it by its dotted path below prefix.
+func checkKeys(node *yaml.Node, t reflect.Type, prefix string) error {
+	if node.Kind != yaml.MappingNode || t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		fields[tag] = t.Field(i).Type
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		ft, ok := fields[key.Value]
+		if !ok {
+			return fmt.Errorf("line %d: unknown key %q", key.Line, prefix+key.Value)
+		}
+		if err := checkKeys(node.Content[i+1], ft, prefix+key.Value+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether path matches one of the patterns.
+func Match(patterns []string, path string) bool {
+	return module.MatchPrefixPatterns(strings.Join(patterns, ","), path)
+}
+
+// Resolve loads the configuration file name or, if name is empty, the one
+// found from dir upward. It returns the file that was loaded, or "" and an
+// empty Config if there is none.
+func Resolve(name, dir string) (*Config, string, error) {
+	if name == "" {
+		var err error
+		if name, err = Find(dir); err != nil || name == "" {
+			return &Config{}, "", err
+		}
+	}
+	c, err := Load(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, name, nil
+}
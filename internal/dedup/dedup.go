@@ -0,0 +1,268 @@
+// Package dedup finds modules of a build list that hold the same code more
+// than once: several major versions of one module, or one module under
+// different paths.
+package dedup
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/modgraph"
+	"pin-go-dependencies/internal/versions"
+)
+
+// Kinds of duplicates.
+const (
+	// KindMajor is a module present at several major versions, such as
+	// example.com/m and example.com/m/v4.
+	KindMajor = "major"
+	// KindAlias is a module present under several paths, such as a vanity
+	// path and the path of its repository.
+	KindAlias = "alias"
+)
+
+// Duplicate is a set of modules of the build list that hold the same
+// module.
+type Duplicate struct {
+	Kind string `json:"kind"`
+	// Module is what the variants have in common: the path without major
+	// version suffix for KindMajor, the canonical path for KindAlias.
+	Module   string    `json:"module"`
+	Variants []Variant `json:"variants"`
+	// Suggestions are the changes that would consolidate the variants, set
+	// by Suggest.
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// Variant is one of the modules of a Duplicate.
+type Variant struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Declared is the path the go.mod of the module declares, if it differs
+	// from Path, which is possible for replaced modules.
+	Declared string `json:"declared,omitempty"`
+	// Direct is set if the main module requires the variant directly.
+	Direct bool `json:"direct"`
+	// RequiredBy lists the direct requirements of the main module that
+	// pull the variant in, and Chains the shortest requirement chains from
+	// the main module to it, as in the why command.
+	RequiredBy []string   `json:"requiredBy"`
+	Chains     [][]string `json:"chains"`
+}
+
+// successor describes a known module path being replaced by another one.
+type successor struct {
+	path string
+	// dropIn is set if the new path holds the same code, so that a replace
+	// directive consolidates them; otherwise the users have to migrate.
+	dropIn bool
+}
+
+// knownAliases maps module paths to the paths that superseded them without
+// the go.mod files telling.
+var knownAliases = map[string]successor{
+	"github.com/golang/protobuf":       {path: "google.golang.org/protobuf"},
+	"github.com/Sirupsen/logrus":       {path: "github.com/sirupsen/logrus", dropIn: true},
+	"github.com/codahale/hdrhistogram": {path: "github.com/HdrHistogram/hdrhistogram-go"},
+	"github.com/dgrijalva/jwt-go":      {path: "github.com/golang-jwt/jwt"},
+	"github.com/satori/go.uuid":        {path: "github.com/gofrs/uuid"},
+}
+
+// Find returns the duplicates among the modules of the build list mods of
+// the main module whose requirement graph, at the selected versions, is g.
+// direct holds the paths the main module requires directly, not marked
+// indirect, which are the starting points of RequiredBy. The path a
+// replaced module declares is read from the go.mod the go command reported
+// for its replacement.
+func Find(g *modgraph.Graph, mods []gocmd.Module, direct map[string]bool) []Duplicate {
+	type entry struct {
+		mod      gocmd.Module
+		declared string
+	}
+	var entries []entry
+	for _, m := range mods {
+		if m.Main {
+			continue
+		}
+		e := entry{mod: m}
+		if m.Replace != nil {
+			e.declared = declaredPath(m.Replace.GoMod)
+		}
+		if e.declared == m.Path {
+			e.declared = ""
+		}
+		entries = append(entries, e)
+	}
+
+	byMajor := make(map[string][]entry)
+	byAlias := make(map[string][]entry)
+	for _, e := range entries {
+		if prefix, _, ok := module.SplitPathVersion(e.mod.Path); ok {
+			byMajor[prefix] = append(byMajor[prefix], e)
+		}
+		canonical := e.mod.Path
+		if e.declared != "" {
+			canonical = e.declared
+		}
+		if s, ok := knownAliases[canonical]; ok {
+			canonical = s.path
+		}
+		byAlias[canonical] = append(byAlias[canonical], e)
+	}
+
+	chains := chainer(g, direct)
+	var dups []Duplicate
+	add := func(kind, path string, es []entry) {
+		if len(es) < 2 {
+			return
+		}
+		d := Duplicate{Kind: kind, Module: path}
+		for _, e := range es {
+			v := Variant{Path: e.mod.Path, Version: e.mod.Version, Declared: e.declared, Direct: direct[e.mod.Path]}
+			v.RequiredBy, v.Chains = chains(e.mod.Path)
+			d.Variants = append(d.Variants, v)
+		}
+		sort.Slice(d.Variants, func(i, j int) bool { return d.Variants[i].Path < d.Variants[j].Path })
+		dups = append(dups, d)
+	}
+	for path, es := range byMajor {
+		add(KindMajor, path, es)
+	}
+	for path, es := range byAlias {
+		add(KindAlias, path, es)
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].Module != dups[j].Module {
+			return dups[i].Module < dups[j].Module
+		}
+		return dups[i].Kind < dups[j].Kind
+	})
+	return dups
+}
+
+// declaredPath returns the module path declared by the go.mod file at name,
+// or "" if it cannot be read.
+func declaredPath(name string) string {
+	if name == "" {
+		return ""
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		slog.Debug("reading go.mod of replacement", "file", name, "err", err)
+		return ""
+	}
+	return modfile.ModulePath(data)
+}
+
+// chainer returns a function returning the direct requirements pulling a
+// module path in and the shortest chains through them. Requirements of the
+// main module marked indirect only record the build list, so the chains
+// start with the direct requirements; a module only reached through the
+// others gets its chains in the whole graph.
+func chainer(g *modgraph.Graph, direct map[string]bool) func(string) ([]string, [][]string) {
+	dg := &modgraph.Graph{Main: g.Main, Nodes: g.Nodes, Edges: make(map[module.Version][]module.Version, len(g.Edges))}
+	for n, to := range g.Edges {
+		dg.Edges[n] = to
+	}
+	var roots []module.Version
+	for _, to := range g.Edges[g.Main] {
+		if direct[to.Path] {
+			roots = append(roots, to)
+		}
+	}
+	dg.Edges[g.Main] = roots
+
+	return func(path string) ([]string, [][]string) {
+		cs := dg.ShortestChains(path)
+		if cs == nil {
+			cs = g.ShortestChains(path)
+		}
+		requiredBy := []string{}
+		seen := make(map[string]bool)
+		chains := [][]string{}
+		for _, c := range cs {
+			names := make([]string, len(c))
+			for i, n := range c {
+				names[i] = modgraph.Node(n)
+			}
+			chains = append(chains, names)
+			if len(c) > 2 && !seen[names[1]] {
+				seen[names[1]] = true
+				requiredBy = append(requiredBy, names[1])
+			}
+		}
+		return requiredBy, chains
+	}
+}
+
+// Suggest sets the Suggestions of d: for several major versions, moving to
+// the highest one with update --major where the main module requires an
+// older one, and updating the direct requirements that pull in the older
+// ones; for aliases, a replace directive pointing the other paths to the
+// canonical one if it holds the same code, and else updating the direct
+// requirements that pull in the superseded paths.
+func Suggest(d *Duplicate) {
+	d.Suggestions = nil
+	if d.Kind == KindMajor {
+		newest := d.Variants[0]
+		for _, v := range d.Variants[1:] {
+			if versions.PathMajor(v.Path, v.Version) > versions.PathMajor(newest.Path, newest.Version) {
+				newest = v
+			}
+		}
+		for _, v := range d.Variants {
+			if v.Path == newest.Path {
+				continue
+			}
+			if v.Direct {
+				d.Suggestions = append(d.Suggestions, "app update --major "+v.Path)
+			}
+			if len(v.RequiredBy) > 0 {
+				d.Suggestions = append(d.Suggestions, fmt.Sprintf("app update --within major %s  # to versions requiring %s instead of %s", strings.Join(modulePaths(v.RequiredBy), " "), newest.Path, v.Path))
+			}
+		}
+		return
+	}
+
+	var canonical *Variant
+	for i, v := range d.Variants {
+		if v.Path == d.Module {
+			canonical = &d.Variants[i]
+		}
+	}
+	for _, v := range d.Variants {
+		if canonical != nil && v.Path == canonical.Path {
+			continue
+		}
+		s, known := knownAliases[v.Path]
+		switch {
+		case v.Declared != "" && canonical != nil:
+			// The go.mod of the replacement says it is the canonical
+			// module, so both can be the same version.
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("replace %s => %s %s", v.Path, v.Declared, canonical.Version))
+		case known && s.dropIn && canonical != nil:
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("replace %s => %s %s", v.Path, s.path, canonical.Version))
+		case len(v.RequiredBy) > 0:
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("app update --within major %s  # to versions using %s instead of %s", strings.Join(modulePaths(v.RequiredBy), " "), d.Module, v.Path))
+		}
+		if v.Direct && !(known && s.dropIn) {
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("# migrate the imports of %s in the main module to %s", v.Path, d.Module))
+		}
+	}
+}
+
+// modulePaths returns the module paths of path@version nodes.
+func modulePaths(nodes []string) []string {
+	paths := make([]string, len(nodes))
+	for i, n := range nodes {
+		paths[i], _, _ = strings.Cut(n, "@")
+	}
+	return paths
+}
@@ -0,0 +1,152 @@
+// Package drift measures how far the requirements of a module lag behind
+// their latest releases, as a report meant to be collected across many
+// repositories.
+package drift
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/audit"
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// SchemaVersion is the version of the report schema. It is raised whenever
+// a field changes meaning or is removed; fields may be added without
+// raising it.
+const SchemaVersion = 1
+
+// Report is the drift of the requirements of a main module. The module
+// path and go version identify the repository, so that the reports of many
+// repositories can be concatenated and grouped.
+type Report struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Module        string       `json:"module"`
+	GoVersion     string       `json:"goVersion"`
+	File          string       `json:"file"`
+	Generated     time.Time    `json:"generated"`
+	Dependencies  []Dependency `json:"dependencies"`
+}
+
+// Dependency is the drift of one requirement.
+type Dependency struct {
+	Path        string     `json:"path"`
+	Indirect    bool       `json:"indirect"`
+	Current     string     `json:"current"`
+	CurrentTime *time.Time `json:"currentTime,omitempty"`
+	Latest      string     `json:"latest"`
+	LatestTime  *time.Time `json:"latestTime,omitempty"`
+	// DriftDays is the number of days between the publication of the
+	// current and of the latest version, 0 if the current version is the
+	// latest or either time is unknown.
+	DriftDays int `json:"driftDays"`
+	// Vulnerable is set if vulnerabilities were looked up, and tells
+	// whether one affects the current version; Vulnerabilities lists their
+	// IDs.
+	Vulnerable      *bool    `json:"vulnerable,omitempty"`
+	Vulnerabilities []string `json:"vulnerabilities,omitempty"`
+	Line            int      `json:"line"`
+}
+
+// Find returns the drift of the requirements of m, querying the proxy for
+// up to concurrency modules at a time. Requirements replaced by a directory
+// are skipped. Lookups that fail are joined into the returned error, or stop
+// the others if failFast is set; the report still contains the other
+// requirements.
+func Find(ctx context.Context, c *proxy.Client, m *gomod.Module, concurrency int, failFast bool) (*Report, error) {
+	var todo []gomod.Require
+	for _, r := range m.Requires() {
+		if r.Replace != nil && r.Replace.Version == "" {
+			continue
+		}
+		todo = append(todo, r)
+	}
+	found, errs := workpool.Map(concurrency, failFast, todo, func(r gomod.Require) (*Dependency, error) {
+		d, err := find(ctx, c, r)
+		return d, failure.Module(r.Path, r.Version, err)
+	})
+	rep := &Report{
+		SchemaVersion: SchemaVersion,
+		Module:        m.ModulePath(),
+		GoVersion:     m.GoVersion(),
+		File:          m.Filename,
+		Generated:     time.Now().UTC().Truncate(time.Second),
+		Dependencies:  []Dependency{},
+	}
+	for _, d := range found {
+		if d != nil {
+			rep.Dependencies = append(rep.Dependencies, *d)
+		}
+	}
+	return rep, errors.Join(errs...)
+}
+
+func find(ctx context.Context, c *proxy.Client, r gomod.Require) (*Dependency, error) {
+	latest, err := resolve.Latest(ctx, c, r.Path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Dependency{Path: r.Path, Indirect: r.Indirect, Current: r.Version, Latest: latest, Line: r.Line}
+	if d.CurrentTime, err = published(ctx, c, r.Path, r.Version); err != nil {
+		return nil, err
+	}
+	if latest == r.Version {
+		d.LatestTime = d.CurrentTime
+		return d, nil
+	}
+	if d.LatestTime, err = published(ctx, c, r.Path, latest); err != nil {
+		return nil, err
+	}
+	if d.CurrentTime != nil && d.LatestTime != nil && d.LatestTime.After(*d.CurrentTime) {
+		d.DriftDays = int(d.LatestTime.Sub(*d.CurrentTime) / (24 * time.Hour))
+	}
+	return d, nil
+}
+
+// published returns the publication time of path@version from the .info
+// file of the proxy, or nil if the proxy does not record one.
+func published(ctx context.Context, c *proxy.Client, path, version string) (*time.Time, error) {
+	info, err := c.Info(ctx, path, version)
+	if err != nil {
+		return nil, err
+	}
+	if info.Time.IsZero() {
+		return nil, nil
+	}
+	t := info.Time.UTC()
+	return &t, nil
+}
+
+// Modules returns the current versions of the dependencies of r, whose
+// vulnerabilities AddVulnerabilities records.
+func (r *Report) Modules() []module.Version {
+	mods := make([]module.Version, len(r.Dependencies))
+	for i, d := range r.Dependencies {
+		mods[i] = module.Version{Path: d.Path, Version: d.Current}
+	}
+	return mods
+}
+
+// AddVulnerabilities records in r which current versions the findings of
+// an audit of the requirements affect. Every dependency gets Vulnerable
+// set, so that the report tells clean versions from unchecked ones.
+func AddVulnerabilities(r *Report, findings []audit.Finding) {
+	ids := make(map[string][]string)
+	for _, f := range findings {
+		key := f.Path + "@" + f.Version
+		ids[key] = append(ids[key], f.ID)
+	}
+	for i := range r.Dependencies {
+		d := &r.Dependencies[i]
+		d.Vulnerabilities = ids[d.Path+"@"+d.Current]
+		vulnerable := len(d.Vulnerabilities) > 0
+		d.Vulnerable = &vulnerable
+	}
+}
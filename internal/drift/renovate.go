@@ -0,0 +1,110 @@
+package drift
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"pin-go-dependencies/internal/versions"
+)
+
+// The subset of the file report of Renovate written by Renovate, with the
+// repository metadata of the report and the drift of every dependency as
+// additional fields.
+type (
+	renovateReport struct {
+		SchemaVersion int                           `json:"schemaVersion"`
+		Module        string                        `json:"module"`
+		GoVersion     string                        `json:"goVersion"`
+		Generated     string                        `json:"generated"`
+		Problems      []string                      `json:"problems"`
+		Repositories  map[string]renovateRepository `json:"repositories"`
+	}
+	renovateRepository struct {
+		Problems     []string                         `json:"problems"`
+		Branches     []string                         `json:"branches"`
+		PackageFiles map[string][]renovatePackageFile `json:"packageFiles"`
+	}
+	renovatePackageFile struct {
+		PackageFile string        `json:"packageFile"`
+		Deps        []renovateDep `json:"deps"`
+	}
+	renovateDep struct {
+		DepName                 string           `json:"depName"`
+		DepType                 string           `json:"depType"`
+		Datasource              string           `json:"datasource"`
+		CurrentValue            string           `json:"currentValue"`
+		CurrentVersion          string           `json:"currentVersion"`
+		CurrentVersionTimestamp string           `json:"currentVersionTimestamp,omitempty"`
+		Updates                 []renovateUpdate `json:"updates"`
+		DriftDays               int              `json:"driftDays"`
+		Vulnerable              *bool            `json:"vulnerable,omitempty"`
+		Vulnerabilities         []string         `json:"vulnerabilities,omitempty"`
+	}
+	renovateUpdate struct {
+		UpdateType       string `json:"updateType"`
+		NewValue         string `json:"newValue"`
+		NewVersion       string `json:"newVersion"`
+		ReleaseTimestamp string `json:"releaseTimestamp,omitempty"`
+	}
+)
+
+// Renovate returns r in the layout of the JSON file report of Renovate,
+// with the main module as the repository and its go.mod as the only package
+// file of the gomod manager, so that tools aggregating such reports can read
+// it. Dependencies on their latest version have no updates.
+func Renovate(r *Report) ([]byte, error) {
+	pf := renovatePackageFile{PackageFile: filepath.ToSlash(r.File), Deps: []renovateDep{}}
+	for _, d := range r.Dependencies {
+		dep := renovateDep{
+			DepName:                 d.Path,
+			DepType:                 "require",
+			Datasource:              "go",
+			CurrentValue:            d.Current,
+			CurrentVersion:          d.Current,
+			CurrentVersionTimestamp: timestamp(d.CurrentTime),
+			Updates:                 []renovateUpdate{},
+			DriftDays:               d.DriftDays,
+			Vulnerable:              d.Vulnerable,
+			Vulnerabilities:         d.Vulnerabilities,
+		}
+		if d.Indirect {
+			dep.DepType = "indirect"
+		}
+		if delta := versions.DeltaOf(d.Current, d.Latest); delta != versions.None {
+			dep.Updates = append(dep.Updates, renovateUpdate{
+				UpdateType:       string(delta),
+				NewValue:         d.Latest,
+				NewVersion:       d.Latest,
+				ReleaseTimestamp: timestamp(d.LatestTime),
+			})
+		}
+		pf.Deps = append(pf.Deps, dep)
+	}
+	doc := renovateReport{
+		SchemaVersion: r.SchemaVersion,
+		Module:        r.Module,
+		GoVersion:     r.GoVersion,
+		Generated:     r.Generated.Format(time.RFC3339),
+		Problems:      []string{},
+		Repositories: map[string]renovateRepository{
+			r.Module: {
+				Problems:     []string{},
+				Branches:     []string{},
+				PackageFiles: map[string][]renovatePackageFile{"gomod": {pf}},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func timestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,118 @@
+// Package failure attributes errors to the modules they occurred for and
+// classifies them, so that a run can continue past the modules that fail
+// and report all of them at the end.
+package failure
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/proxy"
+)
+
+// ModuleError is the failure of an operation on a single module.
+type ModuleError struct {
+	Path string
+	// Version is empty if the operation was not about a specific version.
+	Version string
+	Err     error
+}
+
+func (e *ModuleError) Error() string {
+	if e.Version == "" {
+		return e.Path + ": " + e.Err.Error()
+	}
+	return e.Path + "@" + e.Version + ": " + e.Err.Error()
+}
+
+func (e *ModuleError) Unwrap() error { return e.Err }
+
+// Module attributes err to the module path at version, which may be empty.
+// It returns nil if err is nil, and err itself if it is already attributed
+// to a module.
+func Module(path, version string, err error) error {
+	var me *ModuleError
+	if err == nil || errors.As(err, &me) {
+		return err
+	}
+	return &ModuleError{Path: path, Version: version, Err: err}
+}
+
+// Class is the kind of a failure, which tells what to do about it.
+type Class string
+
+// Classes of failures. Their names are part of the JSON output.
+const (
+	// Timeout means a server did not answer in time.
+	Timeout Class = "timeout"
+	// NotFound means the module or version does not exist.
+	NotFound Class = "not-found"
+	// Auth means the credentials for a proxy or repository are missing or
+	// were rejected.
+	Auth Class = "auth"
+	// Network means a server could not be reached or failed otherwise.
+	Network Class = "network"
+	// Parse means a file, such as a go.mod, is malformed.
+	Parse Class = "parse"
+	// Other covers all other failures.
+	Other Class = "other"
+)
+
+// authMarkers appear in the messages of the go command and git when
+// credentials are missing or rejected.
+var authMarkers = []string{"terminal prompts disabled", "could not read Username", "Authentication failed", "401 Unauthorized"}
+
+// Classify returns the class of err.
+func Classify(err error) Class {
+	var (
+		he *proxy.HTTPError
+		ne net.Error
+		ge *gocmd.Error
+		pv *proxy.PrivateError
+		pe modfile.ErrorList
+		me *modfile.Error
+	)
+	switch {
+	case errors.As(err, &pv) && len(pv.Attempts) > 0:
+		// The attempt of the last source, typically direct, is the most
+		// telling, unless another was turned away for its credentials.
+		for _, a := range pv.Attempts {
+			if Classify(a.Err) == Auth {
+				return Auth
+			}
+		}
+		return Classify(pv.Attempts[len(pv.Attempts)-1].Err)
+	case errors.As(err, &he) && (he.StatusCode == http.StatusUnauthorized || he.StatusCode == http.StatusProxyAuthRequired ||
+		he.StatusCode == http.StatusForbidden && !proxy.Public(he.URL)):
+		return Auth
+	case errors.Is(err, proxy.ErrNotFound), errors.As(err, &he) && (he.StatusCode == http.StatusForbidden || he.StatusCode == http.StatusBadRequest):
+		// Public proxies refuse modules they cannot serve with 400 or 403.
+		return NotFound
+	case errors.As(err, &ne) && ne.Timeout():
+		return Timeout
+	case errors.As(err, &pe), errors.As(err, &me):
+		return Parse
+	case errors.As(err, &ge):
+		for _, m := range authMarkers {
+			if strings.Contains(ge.Msg, m) {
+				return Auth
+			}
+		}
+		switch {
+		case strings.Contains(ge.Msg, "i/o timeout"):
+			return Timeout
+		case strings.Contains(ge.Msg, "no matching versions"), strings.Contains(ge.Msg, "unknown revision"):
+			return NotFound
+		case ge.Network():
+			return Network
+		}
+	case errors.As(err, &ne), errors.As(err, &he):
+		return Network
+	}
+	return Other
+}
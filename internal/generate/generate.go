@@ -0,0 +1,358 @@
+// Package generate finds go:generate directives that run a remote tool with
+// `go run` at a floating version, and rewrites them to concrete versions.
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/scripts"
+	"pin-go-dependencies/internal/tools"
+)
+
+// Reasons a version floats.
+const (
+	// ReasonLatest is a version of @latest.
+	ReasonLatest = "latest"
+	// ReasonBranch is a version naming a branch, such as @master.
+	ReasonBranch = "branch"
+	// ReasonQuery is a version query other than latest, such as @v1.2 or
+	// @upgrade, which selects the highest matching version.
+	ReasonQuery = "query"
+	// ReasonNoVersion is a package without version that the main module
+	// does not require, so that go run resolves it to the latest version.
+	ReasonNoVersion = "no version"
+)
+
+// Finding is a go:generate directive running a remote package with go run
+// at a floating version.
+type Finding struct {
+	File string `json:"file" yaml:"file"`
+	// Line and Column locate the package argument; Column counts bytes
+	// from 1. With an alias, the argument is either in the directive or in
+	// the -command directive defining the alias.
+	Line    int    `json:"line" yaml:"line"`
+	Column  int    `json:"column" yaml:"column"`
+	Package string `json:"package" yaml:"package"`
+	// Version is the version of the argument, empty if it has none.
+	Version string `json:"version" yaml:"version"`
+	Reason  string `json:"reason" yaml:"reason"`
+	// Alias is the name of the -command alias the directive runs go run
+	// through, if any.
+	Alias string `json:"alias,omitempty" yaml:"alias,omitempty"`
+
+	// start and end delimit the bytes of the file that a pinned version
+	// replaces, like for scripts.Finding; start is -1 if the argument
+	// cannot be rewritten, such as a quoted one with escapes.
+	start, end int
+}
+
+// Fixable reports whether Pin can rewrite the version of f.
+func (f Finding) Fixable() bool { return f.start >= 0 }
+
+// Walk scans the Go files of the packages matched by patterns, which are
+// directories, recursive if they end in /..., for the main module m, by
+// default all of m. Files of every build constraint and test files are
+// scanned, since any of them can be generated from. Recursive patterns skip
+// directories holding another module, vendor and testdata directories, and
+// directories starting with . or _, like the go command does. The findings
+// are sorted by file and position.
+func Walk(m *gomod.Module, patterns []string) ([]Finding, error) {
+	if len(patterns) == 0 {
+		patterns = []string{filepath.Join(filepath.Dir(m.Filename), "...")}
+	}
+	var findings []Finding
+	scanDir := func(dir string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !strings.HasSuffix(e.Name(), ".go") || !e.Type().IsRegular() {
+				continue
+			}
+			found, err := ScanFile(m, filepath.Join(dir, e.Name()))
+			if err != nil {
+				return err
+			}
+			findings = append(findings, found...)
+		}
+		return nil
+	}
+	for _, p := range patterns {
+		root, recursive := strings.CutSuffix(filepath.ToSlash(p), "/...")
+		if root == "..." {
+			root, recursive = ".", true
+		}
+		root = filepath.FromSlash(root)
+		if !recursive {
+			if err := scanDir(root); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != root {
+				name := d.Name()
+				if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+					return filepath.SkipDir
+				}
+				if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+					return filepath.SkipDir
+				}
+			}
+			return scanDir(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line || findings[i].Line == findings[j].Line && findings[i].Column < findings[j].Column
+	})
+	return findings, nil
+}
+
+// ScanFile returns the findings of the Go file name of the main module m.
+func ScanFile(m *gomod.Module, name string) ([]Finding, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Scan(m, name, data)
+}
+
+// Scan returns the findings of data, the content of the Go file name of the
+// main module m. Like go generate, it only considers comments starting with
+// //go:generate at the beginning of a line, and expands the aliases of
+// -command directives from their definition to the end of the file. A
+// package argument reached from several directives is reported once.
+func Scan(m *gomod.Module, name string, data []byte) ([]Finding, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	seen := make(map[int]bool)
+	aliases := make(map[string][]word)
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			pos := fset.Position(c.Slash)
+			if pos.Column != 1 {
+				continue
+			}
+			words, ok := directive(data, pos.Offset)
+			if !ok || len(words) == 0 {
+				continue
+			}
+			if words[0].text == "-command" {
+				if len(words) >= 3 {
+					aliases[words[1].text] = words[2:]
+				}
+				continue
+			}
+			alias := ""
+			if def, ok := aliases[words[0].text]; ok {
+				alias = words[0].text
+				words = append(append([]word(nil), def...), words[1:]...)
+			}
+			w, ok := goRunPackage(words)
+			if !ok || seen[w.start] {
+				continue
+			}
+			if fd, ok := finding(m, name, data, w); ok {
+				seen[w.start] = true
+				fd.Alias = alias
+				findings = append(findings, fd)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// word is an argument of a directive. start is its offset in the file,
+// after the quote of a quoted argument; exact is set if its text is its
+// bytes in the file, which is not the case for quoted ones with escapes.
+type word struct {
+	text  string
+	start int
+	exact bool
+}
+
+// directive returns the arguments of the //go:generate comment at offset of
+// data, split like go generate does: at spaces and tabs, with quoted Go
+// strings as single arguments.
+func directive(data []byte, offset int) ([]word, bool) {
+	end := bytes.IndexByte(data[offset:], '\n')
+	if end < 0 {
+		end = len(data) - offset
+	}
+	line := bytes.TrimSuffix(data[offset:offset+end], []byte("\r"))
+	const prefix = "//go:generate"
+	if !bytes.HasPrefix(line, []byte(prefix)) || len(line) == len(prefix) || (line[len(prefix)] != ' ' && line[len(prefix)] != '\t') {
+		return nil, false
+	}
+	var words []word
+	for i := len(prefix); i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(string(line[i:]))
+			if err != nil {
+				// go generate rejects the directive.
+				return nil, false
+			}
+			text, _ := strconv.Unquote(quoted)
+			words = append(words, word{text: text, start: offset + i + 1, exact: quoted[1:len(quoted)-1] == text})
+			i += len(quoted)
+		default:
+			j := i
+			for j < len(line) && line[j] != ' ' && line[j] != '\t' {
+				j++
+			}
+			words = append(words, word{text: string(line[i:j]), start: offset + i, exact: true})
+			i = j
+		}
+	}
+	return words, true
+}
+
+// goRunPackage returns the package argument of words if they run go run.
+func goRunPackage(words []word) (word, bool) {
+	if len(words) < 3 || (words[0].text != "go" && !strings.HasSuffix(words[0].text, "/go")) || words[1].text != "run" {
+		return word{}, false
+	}
+	for i := 2; i < len(words); i++ {
+		arg := words[i].text
+		if !strings.HasPrefix(arg, "-") {
+			return words[i], true
+		}
+		if !strings.Contains(arg, "=") && scripts.ValueFlags[strings.TrimLeft(arg, "-")] {
+			i++
+		}
+	}
+	return word{}, false
+}
+
+// commit matches a commit hash or a prefix of one, which does not float.
+var commit = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// finding reports whether the package argument w runs a remote package at a
+// floating version.
+func finding(m *gomod.Module, name string, data []byte, w word) (Finding, bool) {
+	pkg, version, hasVersion := strings.Cut(w.text, "@")
+	first, _, _ := strings.Cut(pkg, "/")
+	if !strings.Contains(first, ".") || module.CheckImportPath(pkg) != nil {
+		return Finding{}, false
+	}
+	var reason string
+	switch {
+	case !hasVersion:
+		if mod, _ := tools.Provider(m, pkg); mod != "" {
+			// go run uses the version of the build list.
+			return Finding{}, false
+		}
+		reason = ReasonNoVersion
+	case version == "latest":
+		reason = ReasonLatest
+	case module.CanonicalVersion(version) == version || commit.MatchString(version):
+		return Finding{}, false
+	case semver.IsValid(version) || version == "upgrade" || version == "patch" || strings.HasPrefix(version, "<") || strings.HasPrefix(version, ">"):
+		reason = ReasonQuery
+	default:
+		reason = ReasonBranch
+	}
+	f := Finding{
+		File:    name,
+		Line:    bytes.Count(data[:w.start], []byte("\n")) + 1,
+		Column:  w.start - bytes.LastIndexByte(data[:w.start], '\n'),
+		Package: pkg,
+		Version: version,
+		Reason:  reason,
+		start:   -1,
+	}
+	if !w.exact {
+		return f, true
+	}
+	f.start = w.start + len(pkg)
+	f.end = f.start
+	if hasVersion {
+		f.start++
+		f.end = f.start + len(version)
+	}
+	return f, true
+}
+
+// Argument formats the package argument of f as found in the directive.
+func (f Finding) Argument() string {
+	if f.Version == "" {
+		return f.Package
+	}
+	return f.Package + "@" + f.Version
+}
+
+// Pin returns data with the version of every fixable finding replaced by the
+// version of its package in pinned. Findings without a version in pinned
+// are left alone, and all other bytes are kept as they are.
+func Pin(data []byte, findings []Finding, pinned map[string]string) []byte {
+	sorted := append([]Finding(nil), findings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	var buf bytes.Buffer
+	last := 0
+	for _, f := range sorted {
+		v, ok := pinned[f.Package]
+		if !ok || !f.Fixable() || f.start < last || f.end > len(data) {
+			continue
+		}
+		buf.Write(data[last:f.start])
+		if f.Version == "" {
+			buf.WriteByte('@')
+		}
+		buf.WriteString(v)
+		last = f.end
+	}
+	buf.Write(data[last:])
+	return buf.Bytes()
+}
+
+// Fix rewrites the file name, whose findings are findings, to the versions
+// in pinned.
+func Fix(name string, findings []Finding, pinned map[string]string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	fixed := Pin(data, findings, pinned)
+	if bytes.Equal(fixed, data) {
+		return nil
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), name, fixed, parser.ParseComments); err != nil {
+		return fmt.Errorf("rewriting %s: %w", name, err)
+	}
+	return fsutil.ReplaceFile(name, fixed)
+}
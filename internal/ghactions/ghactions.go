@@ -0,0 +1,110 @@
+// Package ghactions writes the workflow commands and step summaries of
+// GitHub Actions, which turn findings into annotations on the offending
+// line of a file.
+package ghactions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Levels of an annotation.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNotice  = "notice"
+)
+
+// Enabled reports whether the process runs in a GitHub Actions workflow.
+func Enabled() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// Annotation is a message attached to a line of a file.
+type Annotation struct {
+	Level string
+	// File is the path of the file relative to the repository root, and
+	// Line its line; a Line of 0 annotates the file as a whole.
+	File    string
+	Line    int
+	Title   string
+	Message string
+}
+
+// Write writes a as a workflow command, such as
+// "::error file=go.mod,line=12,title=Pseudo-version::message".
+func Write(w io.Writer, a Annotation) error {
+	var props []string
+	if a.File != "" {
+		props = append(props, "file="+escapeProperty(a.File))
+		if a.Line > 0 {
+			props = append(props, fmt.Sprintf("line=%d", a.Line))
+		}
+	}
+	if a.Title != "" {
+		props = append(props, "title="+escapeProperty(a.Title))
+	}
+	cmd := "::" + a.Level
+	if len(props) > 0 {
+		cmd += " " + strings.Join(props, ",")
+	}
+	_, err := fmt.Fprintf(w, "%s::%s\n", cmd, escapeData(a.Message))
+	return err
+}
+
+var (
+	dataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	propertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func escapeData(s string) string { return dataEscaper.Replace(s) }
+
+func escapeProperty(s string) string { return propertyEscaper.Replace(s) }
+
+// WriteSummary appends a markdown section with a heading and a table to the
+// job summary named by GITHUB_STEP_SUMMARY. It does nothing when the
+// variable is unset. A table without rows is replaced by empty, a line
+// saying that nothing was found.
+func WriteSummary(heading string, header []string, rows [][]string, empty string) error {
+	name := os.Getenv("GITHUB_STEP_SUMMARY")
+	if name == "" {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", heading)
+	if len(rows) == 0 {
+		fmt.Fprintf(&b, "%s\n\n", empty)
+	} else {
+		writeRow(&b, header)
+		sep := make([]string, len(header))
+		for i := range sep {
+			sep[i] = "---"
+		}
+		writeRow(&b, sep)
+		for _, r := range rows {
+			writeRow(&b, r)
+		}
+		b.WriteString("\n")
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+var cellEscaper = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+
+func writeRow(b *strings.Builder, cells []string) {
+	b.WriteString("|")
+	for _, c := range cells {
+		b.WriteString(" " + cellEscaper.Replace(c) + " |")
+	}
+	b.WriteString("\n")
+}
@@ -0,0 +1,183 @@
+// Package githook installs and removes a block of shell commands in the git
+// hooks of a repository, between markers that set it apart from the rest of
+// the hook.
+package githook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"pin-go-dependencies/internal/fsutil"
+)
+
+// Markers delimiting the managed block of a hook.
+const (
+	BeginMarker = "# >>> pin-go-dependencies >>>"
+	EndMarker   = "# <<< pin-go-dependencies <<<"
+)
+
+const shebang = "#!/bin/sh\n"
+
+// ErrUnmanaged is returned by Install for an existing hook without a
+// managed block.
+var ErrUnmanaged = errors.New("hook exists and was not installed by this tool")
+
+// Dir returns the directory git runs the hooks of the repository at dir
+// from. It honors core.hooksPath and finds the git directory of worktrees
+// and submodules, where .git is a file.
+func Dir(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	hooks := strings.TrimSpace(out)
+	if !filepath.IsAbs(hooks) {
+		hooks = filepath.Join(dir, hooks)
+	}
+	return hooks, nil
+}
+
+// TopLevel returns the root of the working tree containing dir, where git
+// runs the hooks.
+func TopLevel(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+	}
+	return string(out), nil
+}
+
+// Install writes block, between the markers, to the hook file name. A new
+// hook gets a shell shebang; in a hook that already has a managed block,
+// that block is replaced. Install fails with ErrUnmanaged for any other
+// existing hook unless force is set, in which case block is appended and
+// the content of the hook is kept.
+func Install(name, block string, force bool) error {
+	data, err := os.ReadFile(name)
+	switch {
+	case os.IsNotExist(err):
+		data = []byte(shebang)
+	case err != nil:
+		return err
+	}
+	managed := BeginMarker + "\n" + strings.TrimSuffix(block, "\n") + "\n" + EndMarker + "\n"
+	rest, found, err := cut(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	var content string
+	switch {
+	case found:
+		content = rest.before + managed + rest.after
+	case string(data) == shebang:
+		content = shebang + managed
+	case !force:
+		return fmt.Errorf("%s: %w; pass --force to append to it", name, ErrUnmanaged)
+	default:
+		content = string(data)
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += "\n" + managed
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(name, []byte(content), 0o755)
+}
+
+// Uninstall removes the managed block from the hook file name and reports
+// whether there was one. A hook left with nothing but its shebang is
+// deleted; other content is kept as it is.
+func Uninstall(name string) (bool, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	rest, found, err := cut(string(data))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+	if !found {
+		return false, nil
+	}
+	content := rest.before + rest.after
+	if strings.TrimSpace(strings.TrimPrefix(content, shebang)) == "" {
+		return true, os.Remove(name)
+	}
+	if rest.after == "" && strings.HasSuffix(content, "\n\n") {
+		// Install separates an appended block from the hook by a blank
+		// line, which goes with the block.
+		content = content[:len(content)-1]
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	return true, fsutil.WriteFileAtomic(name, []byte(content), info.Mode().Perm())
+}
+
+// parts is the content of a hook around its managed block.
+type parts struct {
+	before, after string
+}
+
+// cut splits s around the managed block, including its markers, and
+// reports whether there is one. A begin marker without an end marker is an
+// error, since the end of the block cannot be told.
+func cut(s string) (parts, bool, error) {
+	begin := lineIndex(s, BeginMarker)
+	if begin < 0 {
+		return parts{}, false, nil
+	}
+	end := lineIndex(s[begin:], EndMarker)
+	if end < 0 {
+		return parts{}, false, fmt.Errorf("managed block starting with %q has no %q line", BeginMarker, EndMarker)
+	}
+	end += begin
+	if k := strings.IndexByte(s[end:], '\n'); k >= 0 {
+		end += k + 1
+	} else {
+		end = len(s)
+	}
+	return parts{before: s[:begin], after: s[end:]}, true, nil
+}
+
+// lineIndex returns the index of the first line of s that is line, or -1.
+func lineIndex(s, line string) int {
+	for i := 0; i < len(s); {
+		j := strings.IndexByte(s[i:], '\n')
+		next := len(s)
+		if j >= 0 {
+			next = i + j
+		}
+		if strings.TrimRight(s[i:next], " \t\r") == line {
+			return i
+		}
+		i = next + 1
+	}
+	return -1
+}
@@ -0,0 +1,216 @@
+package githook
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// repo creates a git repository with a commit and returns its directory.
+func repo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	run(t, dir, "init", "-q")
+	run(t, dir, "commit", "-q", "--allow-empty", "-m", "initial")
+	return dir
+}
+
+// gitCmd returns a git command run in dir, isolated from the
+// configuration of the user.
+func gitCmd(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	return cmd
+}
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if out, err := gitCmd(dir, args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// commit makes an empty commit in dir, running its hooks.
+func commit(dir, msg string) (string, error) {
+	out, err := gitCmd(dir, "commit", "-q", "--allow-empty", "-m", msg).CombinedOutput()
+	return string(out), err
+}
+
+func read(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func hook(t *testing.T, dir string) string {
+	t.Helper()
+	hooks, err := Dir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(hooks, "pre-commit")
+}
+
+func TestInstallRuns(t *testing.T) {
+	dir := repo(t)
+	name := hook(t, dir)
+	if err := Install(name, `echo ran >> "$(git rev-parse --show-toplevel)/ran"`, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "#!/bin/sh\n" + BeginMarker + "\necho ran >> \"$(git rev-parse --show-toplevel)/ran\"\n" + EndMarker + "\n"
+	if got := read(t, name); got != want {
+		t.Errorf("hook =\n%s\nwant\n%s", got, want)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Fatalf("hook is not executable: %v", err)
+	}
+
+	if out, err := commit(dir, "second"); err != nil {
+		t.Fatalf("git commit: %v\n%s", err, out)
+	}
+	if got := read(t, filepath.Join(dir, "ran")); got != "ran\n" {
+		t.Errorf("hook wrote %q, want it to run once", got)
+	}
+}
+
+func TestInstalledHookBlocksCommit(t *testing.T) {
+	dir := repo(t)
+	if err := Install(hook(t, dir), "echo refused >&2\nexit 1", false); err != nil {
+		t.Fatal(err)
+	}
+	out, err := commit(dir, "blocked")
+	if err == nil || !strings.Contains(out, "refused") {
+		t.Fatalf("git commit = %v\n%s, want the hook to fail it", err, out)
+	}
+}
+
+func TestInstallReplacesBlock(t *testing.T) {
+	dir := repo(t)
+	name := hook(t, dir)
+	for _, block := range []string{"echo one", "echo two\n"} {
+		if err := Install(name, block, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "#!/bin/sh\n" + BeginMarker + "\necho two\n" + EndMarker + "\n"
+	if got := read(t, name); got != want {
+		t.Errorf("hook =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestInstallForeignHook(t *testing.T) {
+	dir := repo(t)
+	name := hook(t, dir)
+	const foreign = "#!/bin/sh\nmake lint"
+	if err := os.WriteFile(name, []byte(foreign), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Install(name, "echo pin", false); !errors.Is(err, ErrUnmanaged) {
+		t.Fatalf("Install = %v, want ErrUnmanaged", err)
+	}
+	if got := read(t, name); got != foreign {
+		t.Fatalf("refused Install changed the hook to\n%s", got)
+	}
+
+	if err := Install(name, "echo pin", true); err != nil {
+		t.Fatal(err)
+	}
+	want := foreign + "\n\n" + BeginMarker + "\necho pin\n" + EndMarker + "\n"
+	if got := read(t, name); got != want {
+		t.Errorf("forced hook =\n%s\nwant\n%s", got, want)
+	}
+
+	removed, err := Uninstall(name)
+	if err != nil || !removed {
+		t.Fatalf("Uninstall = %v, %v", removed, err)
+	}
+	if got := read(t, name); got != foreign+"\n" {
+		t.Errorf("hook after Uninstall = %q, want %q", got, foreign+"\n")
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	dir := repo(t)
+	name := hook(t, dir)
+	if err := Install(name, "echo pin", false); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := Uninstall(name)
+	if err != nil || !removed {
+		t.Fatalf("Uninstall = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("hook with only a shebang left was kept: %v", err)
+	}
+	if removed, err := Uninstall(name); err != nil || removed {
+		t.Errorf("second Uninstall = %v, %v, want nothing to remove", removed, err)
+	}
+}
+
+func TestUninstallKeepsSurroundingLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "pre-push")
+	content := "#!/bin/sh\nbefore\n" + BeginMarker + "\necho pin\n" + EndMarker + "\nafter\n"
+	if err := os.WriteFile(name, []byte(content), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Uninstall(name); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, name); got != "#!/bin/sh\nbefore\nafter\n" {
+		t.Errorf("hook after Uninstall = %q", got)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("Uninstall changed the mode of the hook: %v, %v", info.Mode(), err)
+	}
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "pre-commit")
+	if err := os.WriteFile(name, []byte("#!/bin/sh\n"+BeginMarker+"\necho pin\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(name, "echo pin", true); err == nil || !strings.Contains(err.Error(), "has no") {
+		t.Errorf("Install = %v, want an error about the missing end marker", err)
+	}
+	if _, err := Uninstall(name); err == nil {
+		t.Error("Uninstall of an unterminated block succeeded")
+	}
+}
+
+func TestDirHooksPath(t *testing.T) {
+	dir := repo(t)
+	run(t, dir, "config", "core.hooksPath", ".githooks")
+	hooks, err := Dir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, ".githooks"); hooks != want {
+		t.Errorf("Dir = %s, want %s", hooks, want)
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	dir := repo(t)
+	sub := filepath.Join(dir, "sub", "dir")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	top, err := TopLevel(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.EvalSymlinks(dir); top != want {
+		t.Errorf("TopLevel = %s, want %s", top, want)
+	}
+}
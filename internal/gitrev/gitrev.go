@@ -0,0 +1,52 @@
+// Package gitrev reads files as they were at a git revision.
+package gitrev
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// WorkingTree is the revision name standing for the files on disk.
+const WorkingTree = ""
+
+// ReadFile returns the content of the file name at the revision rev of the
+// repository containing it, or the file on disk if rev is WorkingTree. A file
+// that does not exist at rev yields an error matching os.ErrNotExist.
+func ReadFile(rev, name string) ([]byte, error) {
+	if rev == WorkingTree {
+		return os.ReadFile(name)
+	}
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	// A path starting with ./ is relative to dir rather than to the root of
+	// the repository.
+	cmd := exec.Command("git", "-C", dir, "show", rev+":./"+filepath.ToSlash(base))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		if strings.Contains(msg, "does not exist in") || strings.Contains(msg, "exists on disk, but not in") {
+			return nil, &os.PathError{Op: "git show " + rev, Path: name, Err: os.ErrNotExist}
+		}
+		return nil, fmt.Errorf("git show %s:%s: %s", rev, name, msg)
+	}
+	return out, nil
+}
+
+// Name returns how rev is shown to users.
+func Name(rev string) string {
+	if rev == WorkingTree {
+		return "the working tree"
+	}
+	return rev
+}
@@ -0,0 +1,320 @@
+// Package gocmd runs the go command and decodes its machine-readable output.
+package gocmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Module is the subset of the `go list -m -json` output used by this tool.
+type Module struct {
+	Path     string
+	Version  string
+	Main     bool
+	Indirect bool
+	Replace  *Module
+	// Time and GoMod are only reported for queried and downloaded modules.
+	Time  *time.Time
+	GoMod string
+}
+
+// ListOptions configures ListModules.
+type ListOptions struct {
+	// ModFile, if set, is passed as -modfile to evaluate an alternate go.mod.
+	ModFile string
+	// UpdateSum runs the go command with -mod=mod instead of -mod=readonly,
+	// allowing it to record missing checksums. It should only be combined
+	// with an alternate ModFile, whose go.sum lives next to it.
+	UpdateSum bool
+	// GoWork is the value of GOWORK for the go command: the path of a
+	// go.work file to list the workspace build list, or "off". When empty
+	// the environment is inherited.
+	GoWork string
+}
+
+// ListModules returns the build list of the module rooted at dir, as reported
+// by `go list -m -json all`. The go command is run with -mod=readonly so that
+// listing never rewrites go.mod or go.sum as a side effect.
+func ListModules(ctx context.Context, dir string, opts ListOptions) ([]Module, error) {
+	mode := "-mod=readonly"
+	if opts.UpdateSum {
+		mode = "-mod=mod"
+	}
+	args := []string{"list", "-m", mode, "-json"}
+	if opts.ModFile != "" {
+		args = append(args, "-modfile="+opts.ModFile)
+	}
+	var env []string
+	if opts.GoWork != "" {
+		env = append(env, "GOWORK="+opts.GoWork)
+	}
+	out, err := run(ctx, dir, env, append(args, "all")...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeModules(out)
+}
+
+// GoModHash returns the go.sum hash of the go.mod file of path@version, as
+// recorded on the "path version/go.mod" line. Only the .mod file is fetched.
+func GoModHash(ctx context.Context, dir, path, version string) (string, error) {
+	out, err := run(ctx, dir, []string{"GOWORK=off"}, "list", "-m", "-mod=readonly", "-json", path+"@"+version)
+	if err != nil {
+		return "", err
+	}
+	mods, err := decodeModules(out)
+	if err != nil {
+		return "", err
+	}
+	if len(mods) != 1 || mods[0].GoMod == "" {
+		return "", fmt.Errorf("go list did not report a go.mod for %s@%s", path, version)
+	}
+	gomod := mods[0].GoMod
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return os.Open(gomod)
+	})
+}
+
+// Query resolves path@query, such as a version or "latest", outside of any
+// module with the extra environment env, as reported by
+// `go list -m -json path@query`. Only the .info and .mod files are
+// fetched.
+func Query(ctx context.Context, path, query string, env []string) (*Module, error) {
+	env = append([]string{"GOWORK=off", "GOFLAGS="}, env...)
+	out, err := run(ctx, os.TempDir(), env, "list", "-m", "-json", path+"@"+query)
+	if err != nil {
+		return nil, err
+	}
+	mods, err := decodeModules(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(mods) != 1 {
+		return nil, fmt.Errorf("go list reported %d modules for %s@%s", len(mods), path, query)
+	}
+	return &mods[0], nil
+}
+
+// Get runs `go get` for the queries (such as "path@version") against the
+// alternate go.mod modFile, which the go command rewrites together with the
+// go.sum next to it. The go.mod and go.sum of the module in dir are not
+// modified.
+func Get(ctx context.Context, dir, modFile string, queries []string) error {
+	args := append([]string{"get", "-modfile=" + modFile}, queries...)
+	_, err := run(ctx, dir, []string{"GOWORK=off"}, args...)
+	return err
+}
+
+// ModGraph returns the output of `go mod graph` for the module in dir,
+// ignoring any workspace: one "from to" edge per line, where the main module
+// appears without a version.
+func ModGraph(ctx context.Context, dir string) ([]byte, error) {
+	return run(ctx, dir, []string{"GOWORK=off"}, "mod", "graph")
+}
+
+// Unneeded returns the modules among paths that provide no package imported,
+// directly or indirectly, by the packages or tests of the main module at dir,
+// as reported by `go mod why -m -vendor`. Like `go mod tidy`, the go command
+// ignores build constraints here, so imports of files for other platforms or
+// guarded by a tools tag count too.
+//
+// go mod why treats an import it cannot load, such as one of a module whose
+// source is missing from the cache in offline mode, as provided by no
+// module, and still exits with 0. The packages and tests of the main module
+// are therefore loaded with `go list` first, and any error loading them is
+// returned rather than a module that may well be needed.
+func Unneeded(ctx context.Context, dir string, paths []string) (map[string]bool, error) {
+	unneeded := make(map[string]bool)
+	if len(paths) == 0 {
+		return unneeded, nil
+	}
+	if _, err := run(ctx, dir, []string{"GOWORK=off"}, "list", "-mod=readonly", "-deps", "-test", "-f", "{{.ImportPath}}", "./..."); err != nil {
+		return nil, fmt.Errorf("loading the packages of the main module: %w", err)
+	}
+	// go mod why has no -mod flag.
+	out, err := run(ctx, dir, []string{"GOWORK=off", "GOFLAGS=-mod=readonly"}, append([]string{"mod", "why", "-m", "-vendor"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	// Every module gets a block starting with "# path", followed by the
+	// import chain that needs it or by a parenthesized note like "(main
+	// module does not need to vendor module path)".
+	var path string
+	for _, line := range strings.Split(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "# "):
+			path = strings.TrimPrefix(line, "# ")
+		case path != "" && strings.HasPrefix(line, "(main module does not need"):
+			unneeded[path] = true
+		}
+	}
+	return unneeded, nil
+}
+
+// PackageModules returns the modules providing the packages imported,
+// directly or indirectly, by the packages and tests of the main module at
+// dir, as reported by `go list -deps -test`, ignoring any workspace. The go
+// command evaluates the alternate go.mod modFile with -mod=mod, so it may
+// download modules and rewrite modFile and the go.sum next to it, but not
+// those of the module. Only Path, Version and Replace are set.
+func PackageModules(ctx context.Context, dir, modFile string) ([]Module, error) {
+	const format = "{{with .Module}}{{if not .Main}}{{.Path}} {{.Version}}{{with .Replace}} {{.Path}} {{.Version}}{{end}}{{end}}{{end}}"
+	out, err := run(ctx, dir, []string{"GOWORK=off"}, "list", "-mod=mod", "-modfile="+modFile, "-e", "-deps", "-test", "-f", format, "./...")
+	if err != nil {
+		return nil, err
+	}
+	var mods []Module
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 || seen[line] {
+			continue
+		}
+		seen[line] = true
+		m := Module{Path: f[0], Version: f[1]}
+		if len(f) > 2 {
+			m.Replace = &Module{Path: f[2]}
+			if len(f) > 3 {
+				m.Replace.Version = f[3]
+			}
+		}
+		mods = append(mods, m)
+	}
+	return mods, nil
+}
+
+// Vendor runs `go mod vendor` for the module in dir, ignoring any workspace.
+func Vendor(ctx context.Context, dir string) error {
+	// go mod vendor has no -mod flag either.
+	_, err := run(ctx, dir, []string{"GOWORK=off", "GOFLAGS=-mod=readonly"}, "mod", "vendor")
+	return err
+}
+
+// Env returns the values of the given go environment variables as reported
+// by `go env`, which takes both the process environment and the settings
+// written by `go env -w` into account.
+func Env(keys ...string) (map[string]string, error) {
+	out, err := run(context.Background(), "", nil, append([]string{"env", "-json"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+	env := make(map[string]string, len(keys))
+	if err := json.Unmarshal(out, &env); err != nil {
+		return nil, fmt.Errorf("decoding go env output: %w", err)
+	}
+	return env, nil
+}
+
+func decodeModules(data []byte) ([]Module, error) {
+	var mods []Module
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var m Module
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding go list output: %w", err)
+		}
+		mods = append(mods, m)
+	}
+	return mods, nil
+}
+
+type offlineKey struct{}
+
+// WithOffline returns a copy of ctx under which, if offline is set, the go
+// command never downloads anything: modules are only taken from the module
+// cache.
+func WithOffline(ctx context.Context, offline bool) context.Context {
+	return context.WithValue(ctx, offlineKey{}, offline)
+}
+
+// Offline reports whether the go command runs offline under ctx.
+func Offline(ctx context.Context) bool {
+	offline, _ := ctx.Value(offlineKey{}).(bool)
+	return offline
+}
+
+// run runs the go command in dir with the extra environment env, once the
+// workpool.Limit of ctx, if any, has a slot for it. The command is killed
+// when ctx is done, and is not started if it is done already.
+func run(ctx context.Context, dir string, env []string, args ...string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	release, err := workpool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	if Offline(ctx) {
+		env = append(env, "GOPROXY=off")
+	}
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := cmd.Output()
+	metrics.Add(metrics.GoCommands, 1)
+	metrics.Time(metrics.PhaseGo, time.Since(start))
+	slog.Debug("go command", "dir", dir, "args", strings.Join(args, " "), "env", strings.Join(env, " "), "duration", time.Since(start), "error", err)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("go %s: %w", strings.Join(args, " "), ctxErr)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, &Error{Args: args, Msg: msg}
+	}
+	return out, nil
+}
+
+// Error is a failed run of the go command.
+type Error struct {
+	Args []string
+	// Msg is what the command printed to standard error.
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return "go " + strings.Join(e.Args, " ") + ": " + e.Msg
+}
+
+// networkMarkers appear in the messages of the go command when it cannot
+// download or resolve a module.
+var networkMarkers = []string{
+	"dial tcp", "no such host", "i/o timeout", "connection refused", "TLS handshake",
+	"reading https://", "reading http://", "unrecognized import path",
+	"no matching versions", "unknown revision", "module lookup disabled",
+	"terminal prompts disabled", "git ls-remote",
+}
+
+// Network reports whether the go command failed to reach a module proxy or
+// repository, or to resolve a module or version.
+func (e *Error) Network() bool {
+	for _, m := range networkMarkers {
+		if strings.Contains(e.Msg, m) {
+			return true
+		}
+	}
+	return false
+}
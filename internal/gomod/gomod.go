@@ -0,0 +1,131 @@
+// Package gomod loads go.mod files into a representation shared by the
+// subcommands.
+package gomod
+
+import (
+	"os"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// Module is a parsed go.mod file.
+type Module struct {
+	Filename string
+	Data     []byte
+	File     *modfile.File
+}
+
+// Require is a require directive together with the replace directive that
+// applies to it, if any.
+type Require struct {
+	Path     string
+	Version  string
+	Indirect bool
+	Replace  *module.Version
+	// Line is the line of the directive in go.mod.
+	Line int
+}
+
+// Load reads and parses the go.mod file at path.
+func Load(path string) (*Module, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse parses go.mod content; filename is used in error messages.
+func Parse(filename string, data []byte) (*Module, error) {
+	f, err := modfile.Parse(filename, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Module{Filename: filename, Data: data, File: f}, nil
+}
+
+// ModulePath returns the path declared by the module directive.
+func (m *Module) ModulePath() string {
+	if m.File.Module == nil {
+		return ""
+	}
+	return m.File.Module.Mod.Path
+}
+
+// GoVersion returns the version of the go directive, or "" if there is none.
+func (m *Module) GoVersion() string {
+	if m.File.Go == nil {
+		return ""
+	}
+	return m.File.Go.Version
+}
+
+// Requires returns the require directives in file order.
+func (m *Module) Requires() []Require {
+	reqs := make([]Require, 0, len(m.File.Require))
+	for _, r := range m.File.Require {
+		req := Require{Path: r.Mod.Path, Version: r.Mod.Version, Indirect: r.Indirect, Line: line(r.Syntax)}
+		if rep := m.Replacement(r.Mod); rep != nil {
+			req.Replace = &rep.New
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// Line returns the line of the require directive of the module path, or of
+// the one replaced by path, or 0 if there is none.
+func (m *Module) Line(path string) int {
+	for _, r := range m.Requires() {
+		if r.Path == path || (r.Replace != nil && r.Replace.Path == path) {
+			return r.Line
+		}
+	}
+	return 0
+}
+
+// GoLine returns the line of the go directive, or 0 if there is none.
+func (m *Module) GoLine() int {
+	if m.File.Go == nil {
+		return 0
+	}
+	return line(m.File.Go.Syntax)
+}
+
+func line(l *modfile.Line) int {
+	if l == nil {
+		return 0
+	}
+	return l.Start.Line
+}
+
+// Excluded reports whether an exclude directive of m excludes path at
+// version, which the go command then never selects.
+func (m *Module) Excluded(path, version string) bool {
+	for _, x := range m.File.Exclude {
+		if x.Mod.Path == path && x.Mod.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Replacement returns the replace directive applying to mod, or nil. As in the
+// go command, a replacement of a specific version takes precedence over one
+// for all versions of the module.
+func (m *Module) Replacement(mod module.Version) *modfile.Replace {
+	var all *modfile.Replace
+	for _, r := range m.File.Replace {
+		if r.Old.Path != mod.Path {
+			continue
+		}
+		if r.Old.Version == mod.Version {
+			return r
+		}
+		if r.Old.Version == "" {
+			all = r
+		}
+	}
+	return all
+}
@@ -0,0 +1,122 @@
+// Package gosum reads and writes go.sum files.
+package gosum
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// Line is a single go.sum entry. Version carries the "/go.mod" suffix for
+// go.mod hashes, exactly as it appears in the file.
+type Line struct {
+	Path    string
+	Version string
+	Hash    string
+}
+
+// Sum is the parsed content of a go.sum file.
+type Sum struct {
+	Lines []Line
+}
+
+// Read parses the go.sum at name. A missing file yields an empty Sum.
+func Read(name string) (*Sum, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &Sum{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data)
+}
+
+// Parse parses go.sum content; name is only used in error messages.
+func Parse(name string, data []byte) (*Sum, error) {
+	s := &Sum{}
+	for i, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 {
+			return nil, fmt.Errorf("%s:%d: malformed go.sum line", name, i+1)
+		}
+		s.Lines = append(s.Lines, Line{Path: f[0], Version: f[1], Hash: f[2]})
+	}
+	return s, nil
+}
+
+// Has reports whether s contains any hash for path at version.
+func (s *Sum) Has(path, version string) bool {
+	for _, l := range s.Lines {
+		if l.Path == path && l.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Hash returns the hash recorded for path at version, or "" if there is none.
+func (s *Sum) Hash(path, version string) string {
+	for _, l := range s.Lines {
+		if l.Path == path && l.Version == version {
+			return l.Hash
+		}
+	}
+	return ""
+}
+
+// Add appends an entry unless the exact same line is already present.
+func (s *Sum) Add(l Line) {
+	for _, have := range s.Lines {
+		if have == l {
+			return
+		}
+	}
+	s.Lines = append(s.Lines, l)
+}
+
+// Set records l as the only hash for its path and version.
+func (s *Sum) Set(l Line) {
+	kept := s.Lines[:0]
+	for _, have := range s.Lines {
+		if have.Path != l.Path || have.Version != l.Version {
+			kept = append(kept, have)
+		}
+	}
+	s.Lines = append(kept, l)
+}
+
+// Format returns the go.sum content sorted the way the go command writes it.
+func (s *Sum) Format() []byte {
+	lines := append([]Line(nil), s.Lines...)
+	sortLines(lines)
+	var buf bytes.Buffer
+	for _, l := range lines {
+		fmt.Fprintf(&buf, "%s %s %s\n", l.Path, l.Version, l.Hash)
+	}
+	return buf.Bytes()
+}
+
+func sortLines(lines []Line) {
+	mods := make([]module.Version, len(lines))
+	byMod := make(map[module.Version][]Line)
+	for i, l := range lines {
+		mv := module.Version{Path: l.Path, Version: l.Version}
+		mods[i] = mv
+		byMod[mv] = append(byMod[mv], l)
+	}
+	module.Sort(mods)
+	lines = lines[:0]
+	for i, mv := range mods {
+		if i > 0 && mods[i-1] == mv {
+			continue
+		}
+		lines = append(lines, byMod[mv]...)
+	}
+}
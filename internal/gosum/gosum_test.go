@@ -0,0 +1,145 @@
+package gosum
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// canonical is a go.sum as the go command writes it: sorted by path, then
+// by semantic version, with the go.mod hash after the zip hash.
+const canonical = `example.com/a v1.0.0 h1:aaaa0000aaaa0000aaaa0000aaaa0000aaaa0000aaa=
+example.com/a v1.0.0/go.mod h1:aaaa1111aaaa1111aaaa1111aaaa1111aaaa1111aaa=
+example.com/a v1.2.0/go.mod h1:aaaa2222aaaa2222aaaa2222aaaa2222aaaa2222aaa=
+example.com/a v1.10.0 h1:aaaa3333aaaa3333aaaa3333aaaa3333aaaa3333aaa=
+example.com/a v1.10.0/go.mod h1:aaaa4444aaaa4444aaaa4444aaaa4444aaaa4444aaa=
+example.com/b v0.0.0-20240101000000-abcdefabcdef/go.mod h1:bbbb0000bbbb0000bbbb0000bbbb0000bbbb0000bbb=
+example.com/b v0.1.0-rc.1 h1:bbbb1111bbbb1111bbbb1111bbbb1111bbbb1111bbb=
+example.com/b v0.1.0 h1:bbbb2222bbbb2222bbbb2222bbbb2222bbbb2222bbb=
+example.com/b/v2 v2.0.0+incompatible/go.mod h1:bbbb3333bbbb3333bbbb3333bbbb3333bbbb3333bbb=
+`
+
+func TestRoundTrip(t *testing.T) {
+	s, err := Parse("go.sum", []byte(canonical))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Lines) != 9 {
+		t.Fatalf("parsed %d lines, want 9", len(s.Lines))
+	}
+	if l := s.Lines[1]; l != (Line{Path: "example.com/a", Version: "v1.0.0/go.mod", Hash: "h1:aaaa1111aaaa1111aaaa1111aaaa1111aaaa1111aaa="}) {
+		t.Errorf("go.mod line parsed as %+v", l)
+	}
+	if got := string(s.Format()); got != canonical {
+		t.Errorf("Format =\n%s\nwant\n%s", got, canonical)
+	}
+}
+
+func TestFormatSorts(t *testing.T) {
+	s, err := Parse("go.sum", []byte(`
+example.com/b/v2 v2.0.0+incompatible/go.mod h1:bbbb3333bbbb3333bbbb3333bbbb3333bbbb3333bbb=
+example.com/a v1.10.0/go.mod h1:aaaa4444aaaa4444aaaa4444aaaa4444aaaa4444aaa=
+example.com/b v0.1.0 h1:bbbb2222bbbb2222bbbb2222bbbb2222bbbb2222bbb=
+example.com/a v1.0.0/go.mod h1:aaaa1111aaaa1111aaaa1111aaaa1111aaaa1111aaa=
+
+example.com/a v1.10.0 h1:aaaa3333aaaa3333aaaa3333aaaa3333aaaa3333aaa=
+example.com/b v0.1.0-rc.1 h1:bbbb1111bbbb1111bbbb1111bbbb1111bbbb1111bbb=
+example.com/a v1.2.0/go.mod   h1:aaaa2222aaaa2222aaaa2222aaaa2222aaaa2222aaa=
+example.com/a v1.0.0 h1:aaaa0000aaaa0000aaaa0000aaaa0000aaaa0000aaa=
+example.com/b v0.0.0-20240101000000-abcdefabcdef/go.mod h1:bbbb0000bbbb0000bbbb0000bbbb0000bbbb0000bbb=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.Format()); got != canonical {
+		t.Errorf("Format =\n%s\nwant\n%s", got, canonical)
+	}
+}
+
+func TestFormatKeepsConflictingHashes(t *testing.T) {
+	const data = "example.com/a v1.0.0 h1:second=\nexample.com/a v1.0.0 h1:first=\n"
+	s, err := Parse("go.sum", []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.Format()); got != data {
+		t.Errorf("Format =\n%s\nwant\n%s", got, data)
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	s, err := Parse("go.sum", []byte("example.com/a v1.0.0 h1:x=\r\nexample.com/a v1.0.0/go.mod h1:y=\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "example.com/a v1.0.0 h1:x=\nexample.com/a v1.0.0/go.mod h1:y=\n"
+	if got := string(s.Format()); got != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, tt := range []struct {
+		name, data, want string
+	}{
+		{"missing hash", "example.com/a v1.0.0 h1:x=\nexample.com/a v1.0.0/go.mod\n", "go.sum:2: malformed go.sum line"},
+		{"extra field", "example.com/a v1.0.0 h1:x= h1:y=\n", "go.sum:1: malformed go.sum line"},
+		{"path only", "\n\nexample.com/a\n", "go.sum:3: malformed go.sum line"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse("go.sum", []byte(tt.data))
+			if err == nil {
+				t.Fatalf("Parse = %+v, want an error", s.Lines)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMissing(t *testing.T) {
+	s, err := Read(filepath.Join(t.TempDir(), "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Lines) != 0 || len(s.Format()) != 0 {
+		t.Errorf("Read of a missing file = %+v, want an empty Sum", s.Lines)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "go.sum")
+	if err := os.WriteFile(name, []byte(canonical), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(s.Format()); got != canonical {
+		t.Errorf("Format =\n%s\nwant\n%s", got, canonical)
+	}
+}
+
+func TestSetAndAdd(t *testing.T) {
+	s := &Sum{}
+	s.Add(Line{Path: "example.com/a", Version: "v1.0.0", Hash: "h1:x="})
+	s.Add(Line{Path: "example.com/a", Version: "v1.0.0", Hash: "h1:x="})
+	s.Add(Line{Path: "example.com/a", Version: "v1.0.0", Hash: "h1:y="})
+	if len(s.Lines) != 2 {
+		t.Fatalf("Add kept %d lines, want 2", len(s.Lines))
+	}
+	s.Set(Line{Path: "example.com/a", Version: "v1.0.0", Hash: "h1:z="})
+	want := []Line{{Path: "example.com/a", Version: "v1.0.0", Hash: "h1:z="}}
+	if !reflect.DeepEqual(s.Lines, want) {
+		t.Errorf("Set left %+v, want %+v", s.Lines, want)
+	}
+	if !s.Has("example.com/a", "v1.0.0") || s.Has("example.com/a", "v1.0.0/go.mod") {
+		t.Error("Has does not tell the zip hash from the go.mod hash")
+	}
+	if h := s.Hash("example.com/a", "v1.0.0"); h != "h1:z=" {
+		t.Errorf("Hash = %q, want h1:z=", h)
+	}
+}
@@ -0,0 +1,34 @@
+package gosum
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// HashGoMod returns the hash of go.mod content data, as recorded on the
+// "path version/go.mod" line.
+func HashGoMod(data []byte) (string, error) {
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// HashZip returns the hash of the module zip archive data, as recorded on
+// the "path version" line.
+func HashZip(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("reading zip: %w", err)
+	}
+	var names []string
+	byName := make(map[string]*zip.File)
+	for _, zf := range zr.File {
+		names = append(names, zf.Name)
+		byName[zf.Name] = zf
+	}
+	return dirhash.Hash1(names, func(name string) (io.ReadCloser, error) { return byName[name].Open() })
+}
@@ -0,0 +1,84 @@
+// Package gowork locates and loads go.work files.
+package gowork
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Workspace is a parsed go.work file.
+type Workspace struct {
+	Filename string
+	File     *modfile.WorkFile
+	// Dirs holds the absolute directories of the use directives.
+	Dirs []string
+}
+
+// Find returns the go.work file in effect for dir, following the rules of
+// the go command: GOWORK names the file explicitly or disables workspace mode
+// when set to "off"; otherwise dir and its parents are searched. It returns
+// "" when no workspace applies.
+func Find(dir string) (string, error) {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return "", nil
+	case "":
+	default:
+		return filepath.Abs(gowork)
+	}
+
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		name := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			return name, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and parses the go.work file at name.
+func Load(name string) (*Workspace, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseWork(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &Workspace{Filename: name, File: f}
+	base := filepath.Dir(name)
+	for _, u := range f.Use {
+		dir := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(base, dir)
+		}
+		w.Dirs = append(w.Dirs, filepath.Clean(dir))
+	}
+	return w, nil
+}
+
+// Uses reports whether the module in dir is a member of the workspace.
+func (w *Workspace) Uses(dir string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, fmt.Errorf("resolving %s: %w", dir, err)
+	}
+	for _, d := range w.Dirs {
+		if d == dir {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,167 @@
+// Package httpx provides the HTTP clients of the network operations of the
+// tool. Their requests stop when the context of the request is done, time
+// out one by one, and are retried with exponential backoff when the server
+// fails or does not answer in time.
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Settings of the run. They are set before the run starts and never changed
+// during it.
+var (
+	// Timeout bounds every attempt of a request, from sending it to reading
+	// the end of the response body; 0 means no bound.
+	Timeout = 30 * time.Second
+	// Retries is how many times a request is repeated after a server error
+	// or a timeout.
+	Retries = 2
+)
+
+// Backoff before the first retry; it doubles for every further one, up to
+// maxBackoff.
+const (
+	firstBackoff = 500 * time.Millisecond
+	maxBackoff   = 8 * time.Second
+)
+
+// NewClient returns a client sending requests through next, or
+// http.DefaultTransport if next is nil, under the settings above.
+func NewClient(next http.RoundTripper) *http.Client {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &http.Client{Transport: transport{next: next}}
+}
+
+// TimeoutError is the error of an attempt that exceeded Timeout.
+type TimeoutError struct {
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no response within %s", e.After)
+}
+
+// Timeout and Temporary make a TimeoutError a net.Error, like the timeouts
+// of the standard library.
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.try(req)
+		if attempt >= Retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		var reason string
+		if resp != nil {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		} else {
+			reason = err.Error()
+		}
+		delay := firstBackoff << attempt
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+		slog.Debug("retrying http request", "url", req.URL.Redacted(), "reason", reason, "retry", attempt+1, "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		if req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// try sends req once, under a context that ends with the context of req
+// and after Timeout. The context lasts until the response body is closed,
+// so that a body that is slow to arrive times out too.
+func (t transport) try(req *http.Request) (*http.Response, error) {
+	var (
+		ctx     context.Context
+		release context.CancelFunc
+	)
+	if Timeout > 0 {
+		ctx, release = context.WithTimeout(req.Context(), Timeout)
+	} else {
+		ctx, release = context.WithCancel(req.Context())
+	}
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && req.Context().Err() == nil
+		release()
+		if timedOut {
+			return nil, &TimeoutError{After: Timeout}
+		}
+		return nil, err
+	}
+	resp.Body = &body{ReadCloser: resp.Body, ctx: ctx, parent: req.Context(), release: release}
+	return resp, nil
+}
+
+// body is a response body releasing the context of its attempt when it is
+// closed.
+type body struct {
+	io.ReadCloser
+	ctx     context.Context
+	parent  context.Context
+	release func()
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && errors.Is(b.ctx.Err(), context.DeadlineExceeded) && b.parent.Err() == nil {
+		err = &TimeoutError{After: Timeout}
+	}
+	return n, err
+}
+
+func (b *body) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// retryable reports whether the outcome of an attempt of req is worth
+// another one: a server error, a timeout or a failed connection, unless
+// req was cancelled. Requests whose body cannot be sent again
+// are never retried.
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		var ne net.Error
+		return errors.As(err, &ne)
+	}
+	return resp.StatusCode >= 500
+}
@@ -0,0 +1,215 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// settings sets Timeout and Retries for the duration of the test.
+func settings(t *testing.T, timeout time.Duration, retries int) {
+	oldTimeout, oldRetries := Timeout, Retries
+	Timeout, Retries = timeout, retries
+	t.Cleanup(func() { Timeout, Retries = oldTimeout, oldRetries })
+}
+
+// server runs handler, counting the requests it receives.
+func server(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int32)) (*httptest.Server, *atomic.Int32) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, hits.Add(1))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+// hang never answers, until the client goes away.
+func hang(w http.ResponseWriter, r *http.Request, _ int32) {
+	<-r.Context().Done()
+}
+
+func TestTimeout(t *testing.T) {
+	settings(t, 50*time.Millisecond, 0)
+	srv, hits := server(t, hang)
+
+	resp, err := NewClient(nil).Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a hanging server succeeded")
+	}
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.After != 50*time.Millisecond {
+		t.Fatalf("error = %v, want a TimeoutError after 50ms", err)
+	}
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want a net.Error timeout matching context.DeadlineExceeded", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
+
+func TestSlowBodyTimesOut(t *testing.T) {
+	settings(t, 100*time.Millisecond, 0)
+	srv, _ := server(t, func(w http.ResponseWriter, r *http.Request, _ int32) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	resp, err := NewClient(nil).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Errorf("reading the body = %v, want a TimeoutError", err)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request, int32)
+		check   func(*http.Response, error) bool
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request, _ int32) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			check: func(resp *http.Response, err error) bool {
+				return err == nil && resp.StatusCode == http.StatusServiceUnavailable
+			},
+		},
+		{
+			name:    "timeout",
+			handler: hang,
+			check: func(resp *http.Response, err error) bool {
+				var te *TimeoutError
+				return errors.As(err, &te)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			settings(t, 50*time.Millisecond, 1)
+			srv, hits := server(t, tt.handler)
+
+			resp, err := NewClient(nil).Get(srv.URL)
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if !tt.check(resp, err) {
+				t.Errorf("Get = %v, %v", resp, err)
+			}
+			if n := hits.Load(); n != 2 {
+				t.Errorf("server got %d requests, want 2: the first one and one retry", n)
+			}
+		})
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	settings(t, time.Second, 2)
+	srv, hits := server(t, func(w http.ResponseWriter, r *http.Request, n int32) {
+		body, _ := io.ReadAll(r.Body)
+		if n == 1 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Write(body)
+	})
+
+	resp, err := NewClient(nil).Post(srv.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || string(data) != "payload" {
+		t.Errorf("body = %q, %v; want the request body sent again", data, err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestClientErrorNotRetried(t *testing.T) {
+	settings(t, time.Second, 2)
+	srv, hits := server(t, func(w http.ResponseWriter, r *http.Request, _ int32) {
+		http.NotFound(w, r)
+	})
+
+	resp, err := NewClient(nil).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if n := hits.Load(); resp.StatusCode != http.StatusNotFound || n != 1 {
+		t.Errorf("status %d after %d requests, want 404 after 1", resp.StatusCode, n)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// Neither the timeout nor the retries apply once the request is
+	// canceled.
+	settings(t, time.Minute, 2)
+	srv, hits := server(t, hang)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	resp, err := NewClient(nil).Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("canceled request succeeded")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	var te *TimeoutError
+	if errors.As(err, &te) {
+		t.Errorf("canceled request reported as %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("request returned %s after being canceled", d)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	settings(t, time.Minute, 2)
+	srv, hits := server(t, func(w http.ResponseWriter, r *http.Request, _ int32) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first retry waits for firstBackoff.
+	time.AfterFunc(firstBackoff/5, cancel)
+	if _, err := NewClient(nil).Do(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
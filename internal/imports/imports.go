@@ -0,0 +1,127 @@
+// Package imports rewrites the import paths of the Go files of a module
+// when a dependency moves to a different module path, such as the path of a
+// new major version.
+package imports
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is a Go file whose imports were rewritten.
+type File struct {
+	Name string
+	Old  []byte
+	New  []byte
+	// Imports is the number of rewritten import specs.
+	Imports int
+}
+
+// Rewrite rewrites the imports of the Go files of the module in dir that
+// belong to a module in moved, which maps old module paths to new ones. An
+// import belongs to the module with the longest path it is within among
+// required and the keys of moved, so that the imports of example.com/m/v2
+// are left alone when example.com/m moves. Only the quoted paths change; the
+// files keep their formatting. Nothing is written; the files are returned
+// in walk order.
+//
+// Like the go command, Rewrite skips directories holding another module,
+// vendor and testdata directories, and directories starting with . or _.
+func Rewrite(dir string, moved map[string]string, required []string) ([]File, error) {
+	modules := append([]string(nil), required...)
+	for p := range moved {
+		modules = append(modules, p)
+	}
+	// Longest paths first, so the first match is the owner.
+	sort.Slice(modules, func(i, j int) bool { return len(modules[i]) > len(modules[j]) })
+
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := d.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := rewriteFile(path, moved, modules)
+		if err != nil || f == nil {
+			return err
+		}
+		files = append(files, *f)
+		return nil
+	})
+	return files, err
+}
+
+// rewriteFile rewrites the imports of the Go file name, or returns nil if
+// none belongs to a moved module.
+func rewriteFile(name string, moved map[string]string, modules []string) (*File, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	af, err := parser.ParseFile(fset, name, data, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, imp := range af.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		owner := ""
+		for _, m := range modules {
+			if path == m || strings.HasPrefix(path, m+"/") {
+				owner = m
+				break
+			}
+		}
+		to, ok := moved[owner]
+		if !ok {
+			continue
+		}
+		edits = append(edits, edit{
+			start: fset.Position(imp.Path.Pos()).Offset,
+			end:   fset.Position(imp.Path.End()).Offset,
+			text:  strconv.Quote(to + strings.TrimPrefix(path, owner)),
+		})
+	}
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		buf.Write(data[last:e.start])
+		buf.WriteString(e.text)
+		last = e.end
+	}
+	buf.Write(data[last:])
+	return &File{Name: name, Old: data, New: buf.Bytes(), Imports: len(edits)}, nil
+}
@@ -0,0 +1,211 @@
+// Package interactive lets a user pick the module updates to apply, one
+// module at a time. The selection logic only talks to a Prompter, so it does
+// not depend on a terminal.
+package interactive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/versions"
+)
+
+// Candidate is a required module with newer versions to choose from.
+type Candidate struct {
+	Path    string
+	Current string
+	// Versions are the versions the module may move to, newest first;
+	// the first one is proposed.
+	Versions []string
+	Delta    versions.Delta
+	// Time is the release date of the proposed version, if known.
+	Time time.Time
+	// Repo is a link to the source of the module.
+	Repo string
+}
+
+// ErrQuit is returned by Prompter.Choose to stop asking about the remaining
+// candidates; the updates accepted so far are kept.
+var ErrQuit = errors.New("quit")
+
+// Prompter asks the user about updates.
+type Prompter interface {
+	// Choose returns the version c should move to, one of c.Versions, or
+	// "" to skip it.
+	Choose(c Candidate) (string, error)
+	// Confirm asks whether to apply the accepted updates.
+	Confirm(accepted []Update) (bool, error)
+}
+
+// Update is an accepted update.
+type Update struct {
+	Path    string
+	Current string
+	Version string
+}
+
+// Select asks p about every candidate in turn and returns the module
+// versions to move to, in the order of cands. It returns nil if nothing was
+// accepted or the user does not confirm the accepted updates.
+func Select(cands []Candidate, p Prompter) ([]module.Version, error) {
+	var accepted []Update
+	for _, c := range cands {
+		v, err := p.Choose(c)
+		if errors.Is(err, ErrQuit) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			continue
+		}
+		if !offered(c, v) {
+			return nil, fmt.Errorf("%s: %s is not one of the offered versions", c.Path, v)
+		}
+		accepted = append(accepted, Update{Path: c.Path, Current: c.Current, Version: v})
+	}
+	if len(accepted) == 0 {
+		return nil, nil
+	}
+	ok, err := p.Confirm(accepted)
+	if err != nil || !ok {
+		return nil, err
+	}
+	targets := make([]module.Version, len(accepted))
+	for i, u := range accepted {
+		targets[i] = module.Version{Path: u.Path, Version: u.Version}
+	}
+	return targets, nil
+}
+
+func offered(c Candidate, v string) bool {
+	for _, o := range c.Versions {
+		if o == v {
+			return true
+		}
+	}
+	return false
+}
+
+// RepoURL returns a link to the source of the module path: its repository
+// for the well-known code hosts, its pkg.go.dev page for the others.
+func RepoURL(path string) string {
+	elems := strings.Split(path, "/")
+	switch elems[0] {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		if len(elems) >= 3 {
+			return "https://" + strings.Join(elems[:3], "/")
+		}
+	}
+	return "https://pkg.go.dev/" + path
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or a pipe.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Terminal is a Prompter that writes its questions to a terminal and reads
+// the answers line by line.
+type Terminal struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewTerminal returns a Terminal prompting on in and out.
+func NewTerminal(in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{in: bufio.NewReader(in), out: out}
+}
+
+// Choose describes c and asks whether to accept the proposed version, skip
+// the module, pick another version or quit.
+func (t *Terminal) Choose(c Candidate) (string, error) {
+	fmt.Fprintf(t.out, "\n%s %s -> %s (%s)", c.Path, c.Current, c.Versions[0], c.Delta)
+	if !c.Time.IsZero() {
+		fmt.Fprintf(t.out, ", released %s", c.Time.Format(time.DateOnly))
+	}
+	fmt.Fprintf(t.out, "\n  %s\n", c.Repo)
+	for {
+		answer, err := t.ask("[a]ccept, [s]kip, [p]ick another version, [q]uit? ")
+		if err != nil {
+			return "", err
+		}
+		switch strings.ToLower(answer) {
+		case "a", "accept":
+			return c.Versions[0], nil
+		case "s", "skip":
+			return "", nil
+		case "q", "quit":
+			return "", ErrQuit
+		case "p", "pick":
+			if v, err := t.pick(c); err != nil || v != "" {
+				return v, err
+			}
+		}
+	}
+}
+
+// pick asks for one of the versions of c, by number or by name. An empty
+// answer goes back to the previous question.
+func (t *Terminal) pick(c Candidate) (string, error) {
+	for i, v := range c.Versions {
+		fmt.Fprintf(t.out, "  %2d) %s\n", i+1, v)
+	}
+	for {
+		answer, err := t.ask("version (number or name, empty to go back)? ")
+		if err != nil || answer == "" {
+			return "", err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(c.Versions) {
+			return c.Versions[n-1], nil
+		}
+		if offered(c, answer) {
+			return answer, nil
+		}
+		fmt.Fprintf(t.out, "%s is not one of the listed versions\n", answer)
+	}
+}
+
+// Confirm lists the accepted updates and asks whether to write them.
+func (t *Terminal) Confirm(accepted []Update) (bool, error) {
+	fmt.Fprintln(t.out)
+	tw := tabwriter.NewWriter(t.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tOLD\tNEW")
+	for _, u := range accepted {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", u.Path, u.Current, u.Version)
+	}
+	if err := tw.Flush(); err != nil {
+		return false, err
+	}
+	answer, err := t.ask(fmt.Sprintf("apply %d updates? [y/N] ", len(accepted)))
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes", nil
+}
+
+// ask prints question and returns the trimmed answer. The end
+// of the input is an error: the user cannot answer anymore.
+func (t *Terminal) ask(question string) (string, error) {
+	fmt.Fprint(t.out, question)
+	line, err := t.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", errors.New("no answer: end of input")
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,163 @@
+package interactive
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+// fakePrompter answers Choose from a script keyed by module path and
+// records what it was asked.
+type fakePrompter struct {
+	choices map[string]answer
+	confirm bool
+
+	asked     []string
+	confirmed []Update
+}
+
+type answer struct {
+	version string
+	err     error
+}
+
+func (p *fakePrompter) Choose(c Candidate) (string, error) {
+	p.asked = append(p.asked, c.Path)
+	a := p.choices[c.Path]
+	return a.version, a.err
+}
+
+func (p *fakePrompter) Confirm(accepted []Update) (bool, error) {
+	p.confirmed = accepted
+	return p.confirm, nil
+}
+
+var candidates = []Candidate{
+	{Path: "example.com/a", Current: "v1.0.0", Versions: []string{"v1.2.0", "v1.1.0"}},
+	{Path: "example.com/b", Current: "v0.3.0", Versions: []string{"v0.4.0"}},
+	{Path: "example.com/c", Current: "v2.0.0", Versions: []string{"v2.1.0"}},
+}
+
+func TestSelect(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		choices map[string]answer
+		confirm bool
+		// asked are the candidates Choose is called for, confirmed the
+		// updates Confirm is called with.
+		asked     []string
+		confirmed []Update
+		want      []module.Version
+		wantErr   string
+	}{
+		{
+			name: "accept and pick",
+			choices: map[string]answer{
+				"example.com/a": {version: "v1.1.0"},
+				"example.com/c": {version: "v2.1.0"},
+			},
+			confirm: true,
+			asked:   []string{"example.com/a", "example.com/b", "example.com/c"},
+			confirmed: []Update{
+				{Path: "example.com/a", Current: "v1.0.0", Version: "v1.1.0"},
+				{Path: "example.com/c", Current: "v2.0.0", Version: "v2.1.0"},
+			},
+			want: []module.Version{{Path: "example.com/a", Version: "v1.1.0"}, {Path: "example.com/c", Version: "v2.1.0"}},
+		},
+		{
+			name:    "not confirmed",
+			choices: map[string]answer{"example.com/b": {version: "v0.4.0"}},
+			asked:   []string{"example.com/a", "example.com/b", "example.com/c"},
+			confirmed: []Update{
+				{Path: "example.com/b", Current: "v0.3.0", Version: "v0.4.0"},
+			},
+		},
+		{
+			name:  "all skipped",
+			asked: []string{"example.com/a", "example.com/b", "example.com/c"},
+		},
+		{
+			name: "quit keeps earlier answers",
+			choices: map[string]answer{
+				"example.com/a": {version: "v1.2.0"},
+				"example.com/b": {err: ErrQuit},
+				"example.com/c": {version: "v2.1.0"},
+			},
+			confirm:   true,
+			asked:     []string{"example.com/a", "example.com/b"},
+			confirmed: []Update{{Path: "example.com/a", Current: "v1.0.0", Version: "v1.2.0"}},
+			want:      []module.Version{{Path: "example.com/a", Version: "v1.2.0"}},
+		},
+		{
+			name:    "version not offered",
+			choices: map[string]answer{"example.com/a": {version: "v9.0.0"}},
+			confirm: true,
+			asked:   []string{"example.com/a"},
+			wantErr: "example.com/a: v9.0.0 is not one of the offered versions",
+		},
+		{
+			name:    "prompter error",
+			choices: map[string]answer{"example.com/b": {err: errors.New("no answer: end of input")}},
+			confirm: true,
+			asked:   []string{"example.com/a", "example.com/b"},
+			wantErr: "no answer: end of input",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakePrompter{choices: tt.choices, confirm: tt.confirm}
+			got, err := Select(candidates, p)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Select error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(p.asked, tt.asked) {
+				t.Errorf("asked about %q, want %q", p.asked, tt.asked)
+			}
+			if !reflect.DeepEqual(p.confirmed, tt.confirmed) {
+				t.Errorf("confirmed %+v, want %+v", p.confirmed, tt.confirmed)
+			}
+		})
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	// Skip a, pick the second version of c by number after an invalid
+	// answer, accept b, then confirm.
+	in := strings.NewReader("s\nx\np\n3\n2\na\ny\n")
+	var out bytes.Buffer
+	cands := []Candidate{
+		candidates[0],
+		{Path: "example.com/c", Current: "v2.0.0", Versions: []string{"v2.2.0", "v2.1.0"}},
+		candidates[1],
+	}
+	got, err := Select(cands, NewTerminal(in, &out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []module.Version{{Path: "example.com/c", Version: "v2.1.0"}, {Path: "example.com/b", Version: "v0.4.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Select = %v, want %v", got, want)
+	}
+	for _, s := range []string{"3 is not one of the listed versions", "apply 2 updates? [y/N] "} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output lacks %q:\n%s", s, out.String())
+		}
+	}
+}
+
+func TestTerminalEndOfInput(t *testing.T) {
+	_, err := Select(candidates, NewTerminal(strings.NewReader("a\n"), &bytes.Buffer{}))
+	if err == nil || !strings.Contains(err.Error(), "end of input") {
+		t.Errorf("Select = %v, want an end of input error", err)
+	}
+}
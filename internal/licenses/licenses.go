@@ -0,0 +1,235 @@
+// Package licenses finds the license files of module versions and
+// classifies them with SPDX identifiers.
+package licenses
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/licensecheck"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Unknown is reported for modules whose license could not be classified.
+const Unknown = "UNKNOWN"
+
+// filePrefixes are the upper-case prefixes of the names of license files in
+// the root directory of a module, such as LICENSE, LICENSE.md,
+// LICENSE-APACHE, COPYING and NOTICE.txt.
+var filePrefixes = []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"}
+
+// License is the license of a module version.
+type License struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// IDs are the SPDX identifiers of the licenses found, sorted, or
+	// Unknown alone if there is no license file or none was recognized.
+	IDs []string `json:"licenses"`
+	// Files are the license files in the root of the module.
+	Files []string `json:"files"`
+	// Confidence is the percentage of the text of the best matching license
+	// file that matches a known license.
+	Confidence float64 `json:"confidence"`
+}
+
+// Unknown reports whether no license was recognized.
+func (l *License) Unknown() bool {
+	return len(l.IDs) == 1 && l.IDs[0] == Unknown
+}
+
+// Finder looks up the licenses of module versions.
+type Finder struct {
+	// Proxy serves the zip archives of modules missing from ModCache.
+	Proxy *proxy.Client
+	// ModCache is the module cache (GOMODCACHE), searched first for the
+	// extracted module and then for its downloaded zip archive. Empty
+	// disables the lookup.
+	ModCache string
+	// CacheDir, if set, stores the license of every module version looked
+	// up, which never changes for a published version.
+	CacheDir string
+}
+
+// Find returns the licenses of mods, in the same order, looking up at most
+// concurrency modules at the same time. Modules that cannot be looked up
+// contribute an error and are left out; with failFast, the lookups stop at
+// the first of them.
+func (f *Finder) Find(ctx context.Context, mods []module.Version, concurrency int, failFast bool) ([]License, error) {
+	found, errs := workpool.Map(concurrency, failFast, mods, func(mv module.Version) (*License, error) {
+		l, err := f.license(ctx, mv)
+		return l, failure.Module(mv.Path, mv.Version, err)
+	})
+	var list []License
+	for i, l := range found {
+		if errs[i] == nil {
+			list = append(list, *l)
+		}
+	}
+	return list, errors.Join(errs...)
+}
+
+// license returns the license of mv from the cache, or classifies it.
+func (f *Finder) license(ctx context.Context, mv module.Version) (*License, error) {
+	cached, err := f.cacheFile(mv)
+	if err != nil {
+		return nil, err
+	}
+	if cached != "" {
+		if data, err := os.ReadFile(cached); err == nil {
+			var l License
+			if json.Unmarshal(data, &l) == nil {
+				return &l, nil
+			}
+		}
+	}
+	files, err := f.files(ctx, mv)
+	if err != nil {
+		return nil, err
+	}
+	l := Classify(mv, files)
+	if cached != "" {
+		// Like the proxy cache, the license cache is an optimization only.
+		if data, err := json.Marshal(l); err == nil && os.MkdirAll(filepath.Dir(cached), 0o755) == nil {
+			fsutil.WriteFileAtomic(cached, data, 0o644)
+		}
+	}
+	return l, nil
+}
+
+// cacheFile returns the file caching the license of mv, or "" if there is
+// no cache.
+func (f *Finder) cacheFile(mv module.Version) (string, error) {
+	if f.CacheDir == "" {
+		return "", nil
+	}
+	ep, err := module.EscapePath(mv.Path)
+	if err != nil {
+		return "", err
+	}
+	ev, err := module.EscapeVersion(mv.Version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(f.CacheDir, filepath.FromSlash(ep)+"@"+ev+".json"), nil
+}
+
+// files returns the license files of mv keyed by name, read from the module
+// cache if it has the module and from its zip archive otherwise.
+func (f *Finder) files(ctx context.Context, mv module.Version) (map[string][]byte, error) {
+	ep, err := module.EscapePath(mv.Path)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := module.EscapeVersion(mv.Version)
+	if err != nil {
+		return nil, err
+	}
+	if f.ModCache != "" {
+		if entries, err := os.ReadDir(filepath.Join(f.ModCache, filepath.FromSlash(ep)+"@"+ev)); err == nil {
+			files := make(map[string][]byte)
+			for _, e := range entries {
+				if e.Type().IsRegular() && IsLicenseFile(e.Name()) {
+					data, err := os.ReadFile(filepath.Join(f.ModCache, filepath.FromSlash(ep)+"@"+ev, e.Name()))
+					if err != nil {
+						return nil, err
+					}
+					files[e.Name()] = data
+				}
+			}
+			return files, nil
+		}
+		if data, err := os.ReadFile(filepath.Join(f.ModCache, "cache", "download", filepath.FromSlash(ep), "@v", ev+".zip")); err == nil {
+			return zipFiles(mv, data)
+		}
+	}
+	if f.Proxy == nil {
+		return nil, fmt.Errorf("not in the module cache")
+	}
+	data, err := f.Proxy.Zip(ctx, mv.Path, mv.Version)
+	if err != nil {
+		return nil, err
+	}
+	return zipFiles(mv, data)
+}
+
+// zipFiles returns the license files in the root of the module zip data.
+// Its entries are named path@version/file.
+func zipFiles(mv module.Version, data []byte) (map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+	prefix := mv.Path + "@" + mv.Version + "/"
+	files := make(map[string][]byte)
+	for _, zf := range zr.File {
+		name, ok := strings.CutPrefix(zf.Name, prefix)
+		if !ok || strings.Contains(name, "/") || !IsLicenseFile(name) {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", zf.Name, err)
+		}
+		files[name] = content
+	}
+	return files, nil
+}
+
+// IsLicenseFile reports whether a file of the given name in the root of a
+// module holds license terms. Go files such as license.go never do.
+func IsLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	if strings.HasSuffix(upper, ".GO") {
+		return false
+	}
+	for _, p := range filePrefixes {
+		if rest, ok := strings.CutPrefix(upper, p); ok && (rest == "" || strings.ContainsAny(rest[:1], ".-_")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify returns the license of mv given its license files, keyed by name.
+func Classify(mv module.Version, files map[string][]byte) *License {
+	l := &License{Path: mv.Path, Version: mv.Version, Files: []string{}}
+	ids := make(map[string]bool)
+	for name, data := range files {
+		l.Files = append(l.Files, name)
+		cov := licensecheck.Scan(data)
+		for _, m := range cov.Match {
+			ids[m.ID] = true
+		}
+		if len(cov.Match) > 0 && cov.Percent > l.Confidence {
+			l.Confidence = cov.Percent
+		}
+	}
+	sort.Strings(l.Files)
+	for id := range ids {
+		l.IDs = append(l.IDs, id)
+	}
+	sort.Strings(l.IDs)
+	if len(l.IDs) == 0 {
+		l.IDs = []string{Unknown}
+	}
+	return l
+}
@@ -0,0 +1,243 @@
+// Package lockfile reads and writes pin.lock files, which record the exact
+// resolution of a module's build list independently of its go.mod.
+//
+// A lock file is line oriented and sorted by module path. Lines starting
+// with # are comments. Every other line describes one module of the build
+// list:
+//
+//	path version h1:module-hash h1:go.mod-hash
+//	path version => replacement-path replacement-version h1:module-hash h1:go.mod-hash
+//	path version => ./directory
+//
+// A hash the go.sum does not record is written as "-". For replaced modules
+// the hashes are those of the replacement.
+package lockfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gosum"
+)
+
+// FileName is the name of the lock file next to go.mod.
+const FileName = "pin.lock"
+
+// Entry is a locked module.
+type Entry struct {
+	Path    string
+	Version string
+	// Replace is the replacement in effect, if any; a directory replacement
+	// has an empty Version.
+	Replace *module.Version
+	// Hash and GoModHash are the go.sum hashes of the module contents and
+	// its go.mod file, or "" if go.sum does not record them.
+	Hash      string
+	GoModHash string
+}
+
+// Effective returns the module version the build uses for e.
+func (e Entry) Effective() module.Version {
+	if e.Replace != nil {
+		return *e.Replace
+	}
+	return module.Version{Path: e.Path, Version: e.Version}
+}
+
+// Lock is the content of a lock file.
+type Lock struct {
+	Entries []Entry
+}
+
+// Capture returns the lock of the current resolution of the module whose
+// go.mod is file: its build list, ignoring workspaces, with the hashes from
+// the go.sum next to it. go.mod hashes missing from go.sum are computed.
+func Capture(ctx context.Context, file string) (*Lock, error) {
+	dir := filepath.Dir(file)
+	mods, err := gocmd.ListModules(ctx, dir, gocmd.ListOptions{GoWork: "off"})
+	if err != nil {
+		return nil, err
+	}
+	sum, err := gosum.Read(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		return nil, err
+	}
+	l := &Lock{}
+	for _, m := range mods {
+		if m.Main || m.Version == "" {
+			continue
+		}
+		e := Entry{Path: m.Path, Version: m.Version}
+		if m.Replace != nil {
+			e.Replace = &module.Version{Path: m.Replace.Path, Version: m.Replace.Version}
+		}
+		if eff := e.Effective(); eff.Version != "" {
+			e.Hash = sum.Hash(eff.Path, eff.Version)
+			e.GoModHash = sum.Hash(eff.Path, eff.Version+"/go.mod")
+			if e.GoModHash == "" {
+				if e.GoModHash, err = gocmd.GoModHash(ctx, dir, eff.Path, eff.Version); err != nil {
+					return nil, err
+				}
+			}
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	l.sort()
+	return l, nil
+}
+
+func (l *Lock) sort() {
+	sort.Slice(l.Entries, func(i, j int) bool { return l.Entries[i].Path < l.Entries[j].Path })
+}
+
+// Read parses the lock file name.
+func Read(name string) (*Lock, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, data)
+}
+
+// Parse parses lock file content; name is only used in error messages.
+func Parse(name string, data []byte) (*Lock, error) {
+	l := &Lock{}
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := parseEntry(strings.Fields(line))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, i+1, err)
+		}
+		if seen[e.Path] {
+			return nil, fmt.Errorf("%s:%d: %s is locked twice", name, i+1, e.Path)
+		}
+		seen[e.Path] = true
+		l.Entries = append(l.Entries, e)
+	}
+	l.sort()
+	return l, nil
+}
+
+func parseEntry(f []string) (Entry, error) {
+	if len(f) < 2 {
+		return Entry{}, fmt.Errorf("malformed line")
+	}
+	e := Entry{Path: f[0], Version: f[1]}
+	if err := module.Check(e.Path, e.Version); err != nil {
+		return Entry{}, err
+	}
+	hashes := f[2:]
+	if len(f) > 2 && f[2] == "=>" {
+		switch len(f) {
+		case 4:
+			e.Replace = &module.Version{Path: f[3]}
+			return e, nil
+		case 7:
+			e.Replace = &module.Version{Path: f[3], Version: f[4]}
+			hashes = f[5:]
+		default:
+			return Entry{}, fmt.Errorf("malformed replacement")
+		}
+	}
+	if len(hashes) != 2 {
+		return Entry{}, fmt.Errorf("expected a module and a go.mod hash")
+	}
+	e.Hash, e.GoModHash = unhash(hashes[0]), unhash(hashes[1])
+	return e, nil
+}
+
+func unhash(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func hash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Format returns the lock file content.
+func (l *Lock) Format() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by `app freeze`. Restore with `app restore`; do not edit.\n")
+	for _, e := range l.Entries {
+		fmt.Fprintf(&buf, "%s %s", e.Path, e.Version)
+		if e.Replace != nil {
+			fmt.Fprintf(&buf, " => %s", e.Replace.Path)
+			if e.Replace.Version == "" {
+				buf.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(&buf, " %s", e.Replace.Version)
+		}
+		fmt.Fprintf(&buf, " %s %s\n", hash(e.Hash), hash(e.GoModHash))
+	}
+	return buf.Bytes()
+}
+
+// Mismatch is a difference between a lock and the current resolution.
+type Mismatch struct {
+	Path    string
+	Version string
+	Reason  string
+}
+
+// Compare lists how got differs from the lock want, sorted by module path.
+func Compare(want, got *Lock) []Mismatch {
+	have := make(map[string]Entry, len(got.Entries))
+	for _, e := range got.Entries {
+		have[e.Path] = e
+	}
+	var ms []Mismatch
+	for _, w := range want.Entries {
+		g, ok := have[w.Path]
+		delete(have, w.Path)
+		switch {
+		case !ok:
+			ms = append(ms, Mismatch{w.Path, w.Version, "locked but not in the build list"})
+		case g.Version != w.Version:
+			ms = append(ms, Mismatch{w.Path, g.Version, "locked at " + w.Version})
+		case describeReplace(g.Replace) != describeReplace(w.Replace):
+			ms = append(ms, Mismatch{w.Path, g.Version, "replaced by " + describeReplace(g.Replace) + ", locked with " + describeReplace(w.Replace)})
+		case w.Hash != "" && g.Hash != "" && g.Hash != w.Hash:
+			ms = append(ms, Mismatch{w.Path, g.Version, "go.sum has " + g.Hash + ", locked " + w.Hash})
+		case w.Hash != "" && g.Hash == "":
+			ms = append(ms, Mismatch{w.Path, g.Version, "go.sum lacks the locked hash " + w.Hash})
+		case g.GoModHash != w.GoModHash:
+			ms = append(ms, Mismatch{w.Path, g.Version, "go.mod hash " + hash(g.GoModHash) + ", locked " + hash(w.GoModHash)})
+		}
+	}
+	for _, g := range got.Entries {
+		if _, ok := have[g.Path]; ok {
+			ms = append(ms, Mismatch{g.Path, g.Version, "in the build list but not locked"})
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Path < ms[j].Path })
+	return ms
+}
+
+func describeReplace(r *module.Version) string {
+	if r == nil {
+		return "nothing"
+	}
+	if r.Version == "" {
+		return r.Path
+	}
+	return r.String()
+}
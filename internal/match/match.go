@@ -0,0 +1,77 @@
+// Package match selects modules by path patterns in the syntax of the go
+// command, where ... matches any string.
+package match
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Pattern returns a function reporting whether a module path matches
+// pattern. As with the go command, ... matches any string, including the
+// empty string and slashes, and a trailing /... also matches the path
+// without it: example.com/m/... matches example.com/m itself.
+func Pattern(pattern string) func(path string) bool {
+	if !strings.Contains(pattern, "...") {
+		return func(path string) bool { return path == pattern }
+	}
+	re := regexp.QuoteMeta(pattern)
+	re = strings.ReplaceAll(re, `\.\.\.`, `.*`)
+	if strings.HasSuffix(re, `/.*`) {
+		re = strings.TrimSuffix(re, `/.*`) + `(/.*)?`
+	}
+	r := regexp.MustCompile("^" + re + "$")
+	return r.MatchString
+}
+
+// Any returns a function reporting whether a module path matches any of
+// patterns.
+func Any(patterns []string) func(path string) bool {
+	fns := make([]func(string) bool, len(patterns))
+	for i, p := range patterns {
+		fns[i] = Pattern(p)
+	}
+	return func(path string) bool {
+		for _, fn := range fns {
+			if fn(path) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Filter selects modules by path and by whether they are direct or
+// indirect requirements.
+type Filter struct {
+	// Only, if not empty, limits the selection to modules matching one of
+	// the patterns.
+	Only []string
+	// Exclude leaves out modules matching one of the patterns, even if
+	// they match Only too.
+	Exclude []string
+	// DirectOnly and IndirectOnly limit the selection to direct or to
+	// indirect requirements.
+	DirectOnly   bool
+	IndirectOnly bool
+}
+
+// Empty reports whether f selects every module.
+func (f Filter) Empty() bool {
+	return len(f.Only) == 0 && len(f.Exclude) == 0 && !f.DirectOnly && !f.IndirectOnly
+}
+
+// Matcher returns a function reporting whether f selects the module path,
+// which indirect reports to be an indirect requirement.
+func (f Filter) Matcher() func(path string, indirect bool) bool {
+	only, exclude := Any(f.Only), Any(f.Exclude)
+	return func(path string, indirect bool) bool {
+		switch {
+		case f.DirectOnly && indirect, f.IndirectOnly && !indirect:
+			return false
+		case exclude(path):
+			return false
+		}
+		return len(f.Only) == 0 || only(path)
+	}
+}
@@ -0,0 +1,95 @@
+package match
+
+import "testing"
+
+func TestPattern(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"example.com/m", "example.com/m", true},
+		{"example.com/m", "example.com/m/v2", false},
+		{"example.com/m", "example.com/mm", false},
+		{"example.com/m/...", "example.com/m", true},
+		{"example.com/m/...", "example.com/m/sub/pkg", true},
+		{"example.com/m/...", "example.com/mm", false},
+		{"example.com/...", "example.com/m", true},
+		{"github.com/.../v2", "github.com/org/repo/v2", true},
+		{"github.com/.../v2", "github.com/org/repo/v3", false},
+		{"golang.org/x/...", "golang.org/x/mod", true},
+		{"golang.org/x/...", "golang.org/xx/mod", false},
+		{"example.com/m...", "example.com/mod", true},
+		{"example.com/m...", "example.com/m", true},
+		// Dots in the pattern are literal.
+		{"example.com/m", "exampleXcom/m", false},
+		{"example.com/...", "exampleXcom/m", false},
+		{"...", "anything/at/all", true},
+	} {
+		if got := Pattern(tt.pattern)(tt.path); got != tt.want {
+			t.Errorf("Pattern(%q)(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAny(t *testing.T) {
+	matches := Any([]string{"example.com/a", "golang.org/x/..."})
+	for path, want := range map[string]bool{
+		"example.com/a":    true,
+		"example.com/b":    false,
+		"golang.org/x/mod": true,
+	} {
+		if got := matches(path); got != want {
+			t.Errorf("Any(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if Any(nil)("example.com/a") {
+		t.Error("Any(nil) matches")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		filter   Filter
+		path     string
+		indirect bool
+		want     bool
+	}{
+		{"empty selects all", Filter{}, "example.com/a", true, true},
+		{"only matches", Filter{Only: []string{"example.com/..."}}, "example.com/a", false, true},
+		{"only does not match", Filter{Only: []string{"example.com/..."}}, "golang.org/x/mod", false, false},
+		{"exclude", Filter{Exclude: []string{"example.com/a"}}, "example.com/a", false, false},
+		{"exclude leaves others", Filter{Exclude: []string{"example.com/a"}}, "example.com/b", false, true},
+		{"exclude wins over only", Filter{Only: []string{"example.com/..."}, Exclude: []string{"example.com/a"}}, "example.com/a", false, false},
+		{"exclude wins over exact only", Filter{Only: []string{"example.com/a"}, Exclude: []string{"example.com/..."}}, "example.com/a", false, false},
+		{"only besides exclude", Filter{Only: []string{"example.com/..."}, Exclude: []string{"example.com/a"}}, "example.com/b", false, true},
+		{"direct only keeps direct", Filter{DirectOnly: true}, "example.com/a", false, true},
+		{"direct only drops indirect", Filter{DirectOnly: true}, "example.com/a", true, false},
+		{"indirect only keeps indirect", Filter{IndirectOnly: true}, "example.com/a", true, true},
+		{"indirect only drops direct", Filter{IndirectOnly: true}, "example.com/a", false, false},
+		{"direct only and only", Filter{Only: []string{"example.com/a"}, DirectOnly: true}, "example.com/a", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matcher()(tt.path, tt.indirect); got != tt.want {
+				t.Errorf("Matcher()(%q, %v) = %v, want %v", tt.path, tt.indirect, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterEmpty(t *testing.T) {
+	for _, tt := range []struct {
+		filter Filter
+		want   bool
+	}{
+		{Filter{}, true},
+		{Filter{Only: []string{"example.com/a"}}, false},
+		{Filter{Exclude: []string{"example.com/a"}}, false},
+		{Filter{DirectOnly: true}, false},
+		{Filter{IndirectOnly: true}, false},
+	} {
+		if got := tt.filter.Empty(); got != tt.want {
+			t.Errorf("%+v.Empty() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
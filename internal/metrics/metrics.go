@@ -0,0 +1,139 @@
+// Package metrics collects counters and durations during a run, such as the
+// number of proxy requests and the time spent resolving, for --stats. The
+// instrumented packages report to Default, which discards everything unless
+// a Recorder replaces it.
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Counters reported by the instrumented packages.
+const (
+	// ModulesResolved counts the module versions selected by a resolver.
+	ModulesResolved = "modulesResolved"
+	// ProxyRequests counts the HTTP requests to module proxies and
+	// repository hosts.
+	ProxyRequests = "proxyRequests"
+	// CacheHits and CacheMisses count the lookups of proxy responses in
+	// the cache.
+	CacheHits   = "cacheHits"
+	CacheMisses = "cacheMisses"
+	// BytesDownloaded counts the bytes of HTTP response bodies.
+	BytesDownloaded = "bytesDownloaded"
+	// GoCommands counts the runs of the go command.
+	GoCommands = "goCommands"
+)
+
+// Phases timed by the instrumented packages. Phases may overlap: requests
+// made concurrently add up to more than the wall time.
+const (
+	PhaseResolve = "resolve"
+	PhaseProxy   = "proxyRequests"
+	PhaseGo      = "goCommand"
+	PhaseWrite   = "writeFiles"
+)
+
+// Collector receives the measurements of a run. Implementations must be
+// safe for concurrent use.
+type Collector interface {
+	// Add adds n to the counter name.
+	Add(name string, n int64)
+	// Time adds d to the duration of the phase name.
+	Time(name string, d time.Duration)
+}
+
+type nop struct{}
+
+func (nop) Add(string, int64)          {}
+func (nop) Time(string, time.Duration) {}
+
+// Default is the collector of the run. It is set before the run starts
+// and never changed during it.
+var Default Collector = nop{}
+
+// Add adds n to the counter name of Default.
+func Add(name string, n int64) { Default.Add(name, n) }
+
+// Time adds d to the duration of the phase name of Default.
+func Time(name string, d time.Duration) { Default.Time(name, d) }
+
+// Start starts timing the phase name; the returned function ends it.
+func Start(name string) func() {
+	start := time.Now()
+	return func() { Default.Time(name, time.Since(start)) }
+}
+
+// Recorder is a Collector keeping the measurements in memory.
+type Recorder struct {
+	start time.Time
+
+	mu       sync.Mutex
+	counters map[string]int64
+	phases   map[string]*Phase
+}
+
+// NewRecorder returns a Recorder measuring the wall time from now. The
+// counters above start at 0, so that they are all reported.
+func NewRecorder() *Recorder {
+	r := &Recorder{start: time.Now(), counters: make(map[string]int64), phases: make(map[string]*Phase)}
+	for _, name := range []string{ModulesResolved, ProxyRequests, CacheHits, CacheMisses, BytesDownloaded, GoCommands} {
+		r.counters[name] = 0
+	}
+	return r
+}
+
+func (r *Recorder) Add(name string, n int64) {
+	r.mu.Lock()
+	r.counters[name] += n
+	r.mu.Unlock()
+}
+
+func (r *Recorder) Time(name string, d time.Duration) {
+	r.mu.Lock()
+	p := r.phases[name]
+	if p == nil {
+		p = &Phase{Name: name}
+		r.phases[name] = p
+	}
+	p.Count++
+	p.Millis += float64(d) / float64(time.Millisecond)
+	r.mu.Unlock()
+}
+
+// Stats is a summary of the measurements of a run.
+type Stats struct {
+	WallMillis float64          `json:"wallMillis"`
+	Counters   map[string]int64 `json:"counters"`
+	// Phases are sorted by name.
+	Phases []Phase `json:"phases"`
+}
+
+// Phase is the total duration of the timed spans of a phase.
+type Phase struct {
+	Name   string  `json:"name"`
+	Count  int64   `json:"count"`
+	Millis float64 `json:"millis"`
+}
+
+// Stats returns the measurements so far, with the wall time since
+// NewRecorder.
+func (r *Recorder) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Stats{
+		WallMillis: float64(time.Since(r.start)) / float64(time.Millisecond),
+		Counters:   make(map[string]int64, len(r.counters)),
+		Phases:     []Phase{},
+	}
+	for k, v := range r.counters {
+		s.Counters[k] = v
+	}
+	for _, p := range r.phases {
+		s.Phases = append(s.Phases, *p)
+	}
+	sort.Slice(s.Phases, func(i, j int) bool { return s.Phases[i].Name < s.Phases[j].Name })
+	return s
+}
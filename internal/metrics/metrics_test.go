@@ -0,0 +1,107 @@
+package metrics_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/proxytest"
+)
+
+// record makes a new Recorder the collector of the test.
+func record(t *testing.T) *metrics.Recorder {
+	r := metrics.NewRecorder()
+	old := metrics.Default
+	metrics.Default = r
+	t.Cleanup(func() { metrics.Default = old })
+	return r
+}
+
+func TestRecorder(t *testing.T) {
+	r := record(t)
+	metrics.Add(metrics.ProxyRequests, 2)
+	metrics.Add(metrics.ProxyRequests, 1)
+	metrics.Add("custom", 5)
+	metrics.Time(metrics.PhaseProxy, 3*time.Millisecond)
+	metrics.Time(metrics.PhaseProxy, time.Millisecond)
+	metrics.Time(metrics.PhaseGo, 2*time.Millisecond)
+
+	s := r.Stats()
+	want := map[string]int64{
+		metrics.ModulesResolved: 0,
+		metrics.ProxyRequests:   3,
+		metrics.CacheHits:       0,
+		metrics.CacheMisses:     0,
+		metrics.BytesDownloaded: 0,
+		metrics.GoCommands:      0,
+		"custom":                5,
+	}
+	if !reflect.DeepEqual(s.Counters, want) {
+		t.Errorf("Counters = %v, want %v", s.Counters, want)
+	}
+	wantPhases := []metrics.Phase{
+		{Name: metrics.PhaseGo, Count: 1, Millis: 2},
+		{Name: metrics.PhaseProxy, Count: 2, Millis: 4},
+	}
+	if !reflect.DeepEqual(s.Phases, wantPhases) {
+		t.Errorf("Phases = %+v, want %+v", s.Phases, wantPhases)
+	}
+}
+
+func TestProxyCounters(t *testing.T) {
+	s := proxytest.New(t, proxytest.Module{Path: "example.com/m", Version: "v1.0.0"})
+	cache := &proxy.Cache{Dir: t.TempDir(), TTL: time.Hour}
+	client := func() *proxy.Client {
+		c, err := proxy.New(s.URL, proxy.Options{Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	r := record(t)
+	check := func(step string, requests, hits, misses int64) {
+		t.Helper()
+		c := r.Stats().Counters
+		if c[metrics.ProxyRequests] != requests || c[metrics.CacheHits] != hits || c[metrics.CacheMisses] != misses {
+			t.Errorf("after %s: %d requests, %d hits, %d misses; want %d, %d, %d", step,
+				c[metrics.ProxyRequests], c[metrics.CacheHits], c[metrics.CacheMisses], requests, hits, misses)
+		}
+		if got := int64(len(s.Requests())); got != requests {
+			t.Errorf("after %s: the proxy got %d requests, the counter says %d", step, got, requests)
+		}
+	}
+
+	first := client()
+	if _, err := first.Info(context.Background(), "example.com/m", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	check("first lookup", 1, 0, 1)
+	if r.Stats().Counters[metrics.BytesDownloaded] == 0 {
+		t.Error("no bytes downloaded counted")
+	}
+
+	// A new client finds the response in the cache.
+	if _, err := client().Info(context.Background(), "example.com/m", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	check("cached lookup", 1, 1, 1)
+
+	if _, err := first.Versions(context.Background(), "example.com/m"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client().Versions(context.Background(), "example.com/m"); err != nil {
+		t.Fatal(err)
+	}
+	check("version lists", 2, 2, 2)
+
+	// Failures count as requests and misses, and are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := first.Info(context.Background(), "example.com/m", "v9.0.0"); err == nil {
+			t.Fatal("Info of a missing version succeeded")
+		}
+	}
+	check("missing version", 4, 2, 4)
+}
@@ -0,0 +1,104 @@
+// Package moddiff compares the requirements of two versions of a module,
+// such as the go.mod and go.sum files of two git revisions.
+package moddiff
+
+import (
+	"sort"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/versions"
+)
+
+// Change is a requirement that differs between the two versions.
+type Change struct {
+	Path string `json:"path"`
+	// Old and New are the versions before and after; Old is empty for an
+	// added requirement and New for a removed one. For replacements they
+	// describe the replacement, such as "example.com/fork v1.2.0" or
+	// "../local", and are empty if there was none.
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+	// Delta is the size of an upgrade or downgrade.
+	Delta    versions.Delta `json:"delta,omitempty"`
+	Indirect bool           `json:"indirect"`
+}
+
+// Report lists the changes by kind, each sorted by module path.
+type Report struct {
+	Added      []Change `json:"added"`
+	Removed    []Change `json:"removed"`
+	Upgraded   []Change `json:"upgraded"`
+	Downgraded []Change `json:"downgraded"`
+	// Replaced lists requirements whose replace directive changed.
+	Replaced []Change `json:"replaced"`
+	// Rehashed lists go.sum entries of the same module version whose hash
+	// changed, which the go command would reject as a checksum mismatch.
+	// Their Old and New are the hashes, and the version is in the path,
+	// like "example.com/m@v1.2.0/go.mod".
+	Rehashed []Change `json:"rehashed"`
+}
+
+// Empty reports whether r has no changes at all.
+func (r *Report) Empty() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Upgraded)+len(r.Downgraded)+len(r.Replaced)+len(r.Rehashed) == 0
+}
+
+// Compare returns the changes from the requirements of oldMod to those of
+// newMod, and from the hashes of oldSum to those of newSum.
+func Compare(oldMod, newMod *gomod.Module, oldSum, newSum *gosum.Sum) *Report {
+	r := &Report{Added: []Change{}, Removed: []Change{}, Upgraded: []Change{}, Downgraded: []Change{}, Replaced: []Change{}, Rehashed: []Change{}}
+	before := make(map[string]gomod.Require)
+	for _, req := range oldMod.Requires() {
+		before[req.Path] = req
+	}
+	for _, req := range newMod.Requires() {
+		old, ok := before[req.Path]
+		if !ok {
+			r.Added = append(r.Added, Change{Path: req.Path, New: req.Version, Indirect: req.Indirect})
+			continue
+		}
+		delete(before, req.Path)
+		switch c := semver.Compare(old.Version, req.Version); {
+		case c < 0:
+			r.Upgraded = append(r.Upgraded, Change{Path: req.Path, Old: old.Version, New: req.Version, Delta: versions.DeltaOf(old.Version, req.Version), Indirect: req.Indirect})
+		case c > 0:
+			r.Downgraded = append(r.Downgraded, Change{Path: req.Path, Old: old.Version, New: req.Version, Delta: versions.DeltaOf(req.Version, old.Version), Indirect: req.Indirect})
+		}
+		if o, n := replacement(old.Replace), replacement(req.Replace); o != n {
+			r.Replaced = append(r.Replaced, Change{Path: req.Path, Old: o, New: n, Indirect: req.Indirect})
+		}
+	}
+	for _, req := range before {
+		r.Removed = append(r.Removed, Change{Path: req.Path, Old: req.Version, Indirect: req.Indirect})
+	}
+
+	hashes := make(map[module.Version]string)
+	for _, l := range oldSum.Lines {
+		hashes[module.Version{Path: l.Path, Version: l.Version}] = l.Hash
+	}
+	for _, l := range newSum.Lines {
+		if h, ok := hashes[module.Version{Path: l.Path, Version: l.Version}]; ok && h != l.Hash {
+			r.Rehashed = append(r.Rehashed, Change{Path: l.Path + "@" + l.Version, Old: h, New: l.Hash})
+		}
+	}
+
+	for _, list := range [][]Change{r.Added, r.Removed, r.Upgraded, r.Downgraded, r.Replaced, r.Rehashed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	}
+	return r
+}
+
+// replacement describes the replacement rep, or returns "" if it is nil.
+func replacement(rep *module.Version) string {
+	switch {
+	case rep == nil:
+		return ""
+	case rep.Version == "":
+		return rep.Path
+	}
+	return rep.Path + " " + rep.Version
+}
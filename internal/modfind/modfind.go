@@ -0,0 +1,59 @@
+// Package modfind locates the Go modules of a working tree.
+package modfind
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Find walks root and returns the go.mod files of all modules below it,
+// sorted by path. Like the go command it skips vendor and testdata
+// directories as well as directories whose name starts with "." or "_".
+// Directories matching one of skip, either by name or by their path relative
+// to root, are skipped too.
+//
+// Every go.mod found is reported, including ones nested inside another
+// module's directory: such a directory is a module of its own.
+func Find(root string, skip []string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skipDir(root, path, d.Name(), skip) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == "go.mod" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func skipDir(root, path, name string, skip []string) bool {
+	if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return true
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, s := range skip {
+		s = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(s)), "/")
+		if s == name || s == rel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package modgraph
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// ShortestChains returns every shortest requirement chain from the main
+// module to a version of the module path. Each chain starts with the main
+// module and ends with the target.
+func (g *Graph) ShortestChains(path string) [][]module.Version {
+	// Breadth-first search remembering all parents on a shortest path.
+	depth := map[module.Version]int{g.Main: 0}
+	parents := make(map[module.Version][]module.Version)
+	queue := []module.Version{g.Main}
+	var targets []module.Version
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n.Path == path && n != g.Main {
+			targets = append(targets, n)
+			continue
+		}
+		for _, to := range g.Edges[n] {
+			d, seen := depth[to]
+			switch {
+			case !seen:
+				depth[to] = depth[n] + 1
+				parents[to] = []module.Version{n}
+				queue = append(queue, to)
+			case d == depth[n]+1:
+				parents[to] = append(parents[to], n)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	best := depth[targets[0]]
+	for _, t := range targets {
+		if depth[t] < best {
+			best = depth[t]
+		}
+	}
+
+	var chains [][]module.Version
+	var walk func(n module.Version, suffix []module.Version)
+	walk = func(n module.Version, suffix []module.Version) {
+		suffix = append([]module.Version{n}, suffix...)
+		if n == g.Main {
+			chains = append(chains, suffix)
+			return
+		}
+		for _, p := range parents[n] {
+			walk(p, suffix)
+		}
+	}
+	for _, t := range targets {
+		if depth[t] == best {
+			walk(t, nil)
+		}
+	}
+	sortChains(chains)
+	return chains
+}
+
+// AllChains returns every requirement chain from the main module to a
+// version of the module path that visits no module twice. The number of
+// chains can grow exponentially with the size of the graph.
+func (g *Graph) AllChains(path string) [][]module.Version {
+	var chains [][]module.Version
+	onPath := make(map[module.Version]bool)
+	var walk func(n module.Version, prefix []module.Version)
+	walk = func(n module.Version, prefix []module.Version) {
+		prefix = append(prefix, n)
+		if n.Path == path && n != g.Main {
+			chains = append(chains, append([]module.Version(nil), prefix...))
+			return
+		}
+		onPath[n] = true
+		for _, to := range g.Edges[n] {
+			if !onPath[to] {
+				walk(to, prefix)
+			}
+		}
+		onPath[n] = false
+	}
+	walk(g.Main, nil)
+	sortChains(chains)
+	return chains
+}
+
+// sortChains orders chains by length and then by their node names.
+func sortChains(chains [][]module.Version) {
+	key := func(c []module.Version) string {
+		names := make([]string, len(c))
+		for i, n := range c {
+			names[i] = Node(n)
+		}
+		return strings.Join(names, " ")
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		if len(chains[i]) != len(chains[j]) {
+			return len(chains[i]) < len(chains[j])
+		}
+		return key(chains[i]) < key(chains[j])
+	})
+}
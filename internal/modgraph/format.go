@@ -0,0 +1,71 @@
+package modgraph
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Adjacency returns the graph as a map from every node name to the names of
+// the nodes it requires.
+func (g *Graph) Adjacency() map[string][]string {
+	adj := make(map[string][]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		to := []string{}
+		for _, t := range g.Edges[n] {
+			to = append(to, Node(t))
+		}
+		adj[Node(n)] = to
+	}
+	return adj
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := &errWriter{w: w}
+	bw.printf("digraph modules {\n")
+	bw.printf("\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		if n == g.Main {
+			bw.printf("\t%s [style=bold];\n", strconv.Quote(Node(n)))
+		} else if len(g.Edges[n]) == 0 {
+			bw.printf("\t%s;\n", strconv.Quote(Node(n)))
+		}
+		for _, to := range g.Edges[n] {
+			bw.printf("\t%s -> %s;\n", strconv.Quote(Node(n)), strconv.Quote(Node(to)))
+		}
+	}
+	bw.printf("}\n")
+	return bw.err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Node names contain
+// characters Mermaid identifiers cannot, so nodes are numbered and labeled.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	bw := &errWriter{w: w}
+	ids := make(map[string]string, len(g.Nodes))
+	bw.printf("graph LR\n")
+	for i, n := range g.Nodes {
+		ids[Node(n)] = fmt.Sprintf("n%d", i)
+		bw.printf("  n%d[%q]\n", i, Node(n))
+	}
+	for _, n := range g.Nodes {
+		for _, to := range g.Edges[n] {
+			bw.printf("  %s --> %s\n", ids[Node(n)], ids[Node(to)])
+		}
+	}
+	return bw.err
+}
+
+// errWriter remembers the first write error so that formatting code does not
+// need to check every call.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
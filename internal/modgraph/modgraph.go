@@ -0,0 +1,222 @@
+// Package modgraph holds the module requirement graph of a main module.
+package modgraph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gocmd"
+)
+
+// Graph is a module requirement graph. Nodes are module versions; the main
+// module has an empty version.
+type Graph struct {
+	Main  module.Version
+	Nodes []module.Version
+	// Edges maps every node to the module versions it requires.
+	Edges map[module.Version][]module.Version
+}
+
+// Node returns the name of a node: path@version, or the path of the main
+// module.
+func Node(mv module.Version) string {
+	if mv.Version == "" {
+		return mv.Path
+	}
+	return mv.Path + "@" + mv.Version
+}
+
+// Load returns the requirement graph of the module in dir as reported by
+// `go mod graph`.
+func Load(ctx context.Context, dir string) (*Graph, error) {
+	out, err := gocmd.ModGraph(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(out)
+}
+
+// Parse parses the output of `go mod graph`.
+func Parse(data []byte) (*Graph, error) {
+	g := &Graph{Edges: make(map[module.Version][]module.Version)}
+	seen := make(map[module.Version]bool)
+	add := func(mv module.Version) {
+		if !seen[mv] {
+			seen[mv] = true
+			g.Nodes = append(g.Nodes, mv)
+		}
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, fmt.Errorf("go mod graph: line %d: malformed edge %q", i+1, line)
+		}
+		from, to := parseNode(f[0]), parseNode(f[1])
+		// Since Go 1.21 the graph also holds the go and toolchain versions
+		// each module needs; they are not modules.
+		if to.Path == "go" || to.Path == "toolchain" {
+			continue
+		}
+		if from.Version == "" && g.Main.Path == "" {
+			g.Main = from
+		}
+		add(from)
+		add(to)
+		g.Edges[from] = append(g.Edges[from], to)
+	}
+	if g.Main.Path == "" {
+		return nil, fmt.Errorf("go mod graph reported no main module")
+	}
+	g.sort()
+	return g, nil
+}
+
+func parseNode(s string) module.Version {
+	path, version, _ := strings.Cut(s, "@")
+	return module.Version{Path: path, Version: version}
+}
+
+// sort orders nodes and edges by path and version, with the main module
+// first, so that every output of a graph is deterministic.
+func (g *Graph) sort() {
+	less := func(a, b module.Version) bool {
+		if a == g.Main || b == g.Main {
+			return a == g.Main && b != g.Main
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return semver.Compare(a.Version, b.Version) < 0
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return less(g.Nodes[i], g.Nodes[j]) })
+	for _, to := range g.Edges {
+		sort.Slice(to, func(i, j int) bool { return less(to[i], to[j]) })
+	}
+}
+
+// Selected returns the version minimal version selection chooses for every
+// module path in the graph: the highest version present. The main module
+// always wins over other versions of itself.
+func (g *Graph) Selected() map[string]string {
+	sel := map[string]string{g.Main.Path: ""}
+	for _, n := range g.Nodes {
+		if n.Path == g.Main.Path {
+			continue
+		}
+		if v, ok := sel[n.Path]; !ok || semver.Compare(n.Version, v) > 0 {
+			sel[n.Path] = n.Version
+		}
+	}
+	return sel
+}
+
+// Pinned returns the graph of the build list: every module appears once at
+// its selected version, requiring the selected versions of its requirements.
+// Module versions that lost to a newer version contribute no edges.
+func (g *Graph) Pinned() *Graph {
+	sel := g.Selected()
+	p := &Graph{Main: g.Main, Edges: make(map[module.Version][]module.Version)}
+	for _, n := range g.Nodes {
+		if sel[n.Path] != n.Version {
+			continue
+		}
+		p.Nodes = append(p.Nodes, n)
+		seen := make(map[string]bool)
+		for _, to := range g.Edges[n] {
+			if !seen[to.Path] {
+				seen[to.Path] = true
+				p.Edges[n] = append(p.Edges[n], module.Version{Path: to.Path, Version: sel[to.Path]})
+			}
+		}
+	}
+	p.sort()
+	return p
+}
+
+// subgraph returns the graph restricted to the nodes in keep.
+func (g *Graph) subgraph(keep map[module.Version]bool) *Graph {
+	s := &Graph{Main: g.Main, Edges: make(map[module.Version][]module.Version)}
+	for _, n := range g.Nodes {
+		if !keep[n] {
+			continue
+		}
+		s.Nodes = append(s.Nodes, n)
+		for _, to := range g.Edges[n] {
+			if keep[to] {
+				s.Edges[n] = append(s.Edges[n], to)
+			}
+		}
+	}
+	return s
+}
+
+// depths returns the distance of every node reachable from the main module.
+func (g *Graph) depths() map[module.Version]int {
+	depth := map[module.Version]int{g.Main: 0}
+	queue := []module.Version{g.Main}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, to := range g.Edges[n] {
+			if _, ok := depth[to]; !ok {
+				depth[to] = depth[n] + 1
+				queue = append(queue, to)
+			}
+		}
+	}
+	return depth
+}
+
+// Limit returns the graph of the nodes at most depth requirements away from
+// the main module.
+func (g *Graph) Limit(depth int) *Graph {
+	keep := make(map[module.Version]bool)
+	for n, d := range g.depths() {
+		if d <= depth {
+			keep[n] = true
+		}
+	}
+	return g.subgraph(keep)
+}
+
+// Focus returns the graph of the nodes on some path from the main module to
+// a version of the module path.
+func (g *Graph) Focus(path string) *Graph {
+	reverse := make(map[module.Version][]module.Version)
+	var queue []module.Version
+	reaches := make(map[module.Version]bool)
+	for _, n := range g.Nodes {
+		for _, to := range g.Edges[n] {
+			reverse[to] = append(reverse[to], n)
+		}
+		if n.Path == path {
+			reaches[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, from := range reverse[n] {
+			if !reaches[from] {
+				reaches[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+	keep := make(map[module.Version]bool)
+	for n := range g.depths() {
+		if reaches[n] {
+			keep[n] = true
+		}
+	}
+	return g.subgraph(keep)
+}
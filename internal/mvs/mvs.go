@@ -0,0 +1,217 @@
+// Package mvs implements minimal version selection over the requirement
+// graph of a module, reading the go.mod files of its dependencies through
+// the module proxy.
+package mvs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/toolchain"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// prunedGo is the go version from which go.mod files list every module
+// their packages need, which lets the go command prune the module graph.
+const prunedGo = "1.17"
+
+// Reqs serves the requirements of module versions, the require directives of
+// their go.mod files, as seen from a main module: its replace directives
+// apply. Every go.mod is parsed once, so a Reqs can be shared by several
+// computations for the same main module.
+type Reqs struct {
+	proxy *proxy.Client
+	main  *gomod.Module
+
+	mu    sync.Mutex
+	cache map[module.Version]*goMod
+}
+
+// goMod holds the parts of a go.mod file MVS needs.
+type goMod struct {
+	requires []module.Version
+	// pruned is set for go.mod files at go 1.17 or later.
+	pruned bool
+}
+
+// NewReqs returns the requirements of the dependencies of main, fetching
+// their go.mod files from c.
+func NewReqs(c *proxy.Client, main *gomod.Module) *Reqs {
+	return &Reqs{proxy: c, main: main, cache: make(map[module.Version]*goMod)}
+}
+
+// Required returns the requirements of mv, and whether its go.mod is at go
+// 1.17 or later. A replaced module has the requirements of its replacement,
+// which may be a directory relative to the main module.
+func (r *Reqs) Required(ctx context.Context, mv module.Version) ([]module.Version, bool, error) {
+	gm, err := r.load(ctx, mv)
+	if err != nil {
+		return nil, false, err
+	}
+	return gm.requires, gm.pruned, nil
+}
+
+func (r *Reqs) load(ctx context.Context, mv module.Version) (*goMod, error) {
+	r.mu.Lock()
+	gm, ok := r.cache[mv]
+	r.mu.Unlock()
+	if ok {
+		return gm, nil
+	}
+
+	var data []byte
+	var err error
+	name := mv.String() + "/go.mod"
+	switch rep := r.main.Replacement(mv); {
+	case rep == nil:
+		data, err = r.proxy.GoMod(ctx, mv.Path, mv.Version)
+	case rep.New.Version == "":
+		name = filepath.Join(filepath.Dir(r.main.Filename), rep.New.Path, "go.mod")
+		data, err = os.ReadFile(name)
+	default:
+		name = rep.New.String() + "/go.mod"
+		data, err = r.proxy.GoMod(ctx, rep.New.Path, rep.New.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	gm = &goMod{pruned: f.Go != nil && toolchain.Compare(f.Go.Version, prunedGo) >= 0}
+	for _, req := range f.Require {
+		gm.requires = append(gm.requires, req.Mod)
+	}
+
+	r.mu.Lock()
+	r.cache[mv] = gm
+	r.mu.Unlock()
+	return gm, nil
+}
+
+// Graph is the requirement graph of a main module.
+type Graph struct {
+	// Selected maps the path of every module in the graph to the version
+	// minimal version selection picks.
+	Selected map[string]string
+	// Loaded are the module versions whose go.mod files are read to build
+	// the graph, in the order they were read.
+	Loaded []module.Version
+	// Nodes holds every module version in the graph: the loaded ones and
+	// those only listed by the go.mod of a module the graph is pruned at.
+	Nodes map[module.Version]bool
+}
+
+// BuildList returns the version minimal version selection picks for every
+// module in the requirement graph of the main module, keyed by path, loading
+// at most concurrency go.mod files at the same time. If failFast is set, it
+// stops at the first go.mod that cannot be loaded.
+func (r *Reqs) BuildList(ctx context.Context, concurrency int, failFast bool) (map[string]string, error) {
+	g, err := r.Graph(ctx, concurrency, failFast)
+	if err != nil {
+		return nil, err
+	}
+	return g.Selected, nil
+}
+
+// Graph loads the requirement graph of the main module, reading at most
+// concurrency go.mod files at the same time and, if failFast is set,
+// stopping at the first one that cannot be loaded.
+//
+// Like the go command, it prunes the graph when the main module is at go
+// 1.17 or later: only the immediate requirements of a dependency at go 1.17
+// or later are part of the graph, while the dependencies of older modules
+// are followed transitively.
+func (r *Reqs) Graph(ctx context.Context, concurrency int, failFast bool) (*Graph, error) {
+	mainPath := r.main.ModulePath()
+	mainPruned := toolchain.Compare(r.main.GoVersion(), prunedGo) >= 0
+	g := &Graph{Selected: make(map[string]string), Nodes: make(map[module.Version]bool)}
+	add := func(mv module.Version) {
+		if mv.Path == mainPath {
+			return
+		}
+		g.Nodes[mv] = true
+		if semver.Compare(mv.Version, g.Selected[mv.Path]) > 0 {
+			g.Selected[mv.Path] = mv.Version
+		}
+	}
+
+	// An item is a module version whose requirements are part of the
+	// graph. The requirements of roots, the requirements of the main
+	// module, only lead further if the graph is not pruned there.
+	type item struct {
+		mv   module.Version
+		root bool
+	}
+	var level []item
+	loaded := make(map[module.Version]bool)
+	for _, req := range r.main.Requires() {
+		mv := module.Version{Path: req.Path, Version: req.Version}
+		add(mv)
+		level = append(level, item{mv: mv, root: true})
+		loaded[mv] = true
+	}
+	var errs []error
+	for len(level) > 0 {
+		found, lerrs := workpool.Map(concurrency, failFast, level, func(it item) (*goMod, error) {
+			gm, err := r.load(ctx, it.mv)
+			return gm, failure.Module(it.mv.Path, it.mv.Version, err)
+		})
+		var next []item
+		for i, it := range level {
+			if lerrs[i] != nil {
+				errs = append(errs, lerrs[i])
+				continue
+			}
+			g.Loaded = append(g.Loaded, it.mv)
+			follow := !it.root || !mainPruned || !found[i].pruned
+			for _, dep := range found[i].requires {
+				add(dep)
+				if follow && dep.Path != mainPath && !loaded[dep] {
+					loaded[dep] = true
+					next = append(next, item{mv: dep})
+				}
+			}
+		}
+		level = next
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Understated is a requirement of the main module on a lower version than
+// the one minimal version selection picks, which is the one the build uses.
+type Understated struct {
+	gomod.Require
+	Selected string
+}
+
+// Understated returns the requirements of the main module that understate
+// the version selected from the requirement graph, in file order, loading
+// the graph like BuildList.
+func (r *Reqs) Understated(ctx context.Context, concurrency int, failFast bool) ([]Understated, error) {
+	selected, err := r.BuildList(ctx, concurrency, failFast)
+	if err != nil {
+		return nil, err
+	}
+	var found []Understated
+	for _, req := range r.main.Requires() {
+		if v := selected[req.Path]; semver.Compare(v, req.Version) > 0 {
+			found = append(found, Understated{Require: req, Selected: v})
+		}
+	}
+	return found, nil
+}
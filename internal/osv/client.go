@@ -0,0 +1,208 @@
+package osv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// DefaultURL is the base URL of the public OSV API.
+const DefaultURL = "https://api.osv.dev"
+
+// maxBatch is the number of queries the API accepts in one batch.
+const maxBatch = 1000
+
+// Source finds the vulnerabilities affecting module versions.
+type Source interface {
+	// Query returns the vulnerabilities of every module version, in the
+	// order of mods.
+	Query(mods []module.Version) ([][]*Vuln, error)
+}
+
+// Client queries the OSV API.
+type Client struct {
+	ctx         context.Context
+	base        string
+	http        *http.Client
+	concurrency int
+	failFast    bool
+}
+
+// NewClient returns a client for the OSV API at base, fetching records on
+// up to concurrency goroutines, stopping at the first failure if failFast is
+// set. Its requests stop when ctx is done.
+func NewClient(ctx context.Context, base string, concurrency int, failFast bool) *Client {
+	return &Client{
+		ctx:         ctx,
+		base:        strings.TrimSuffix(base, "/"),
+		http:        httpx.NewClient(nil),
+		concurrency: concurrency,
+		failFast:    failFast,
+	}
+}
+
+type query struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version   string `json:"version"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+type queryResult struct {
+	Vulns []struct {
+		ID string `json:"id"`
+	} `json:"vulns"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+// Query looks up the vulnerability IDs of all module versions through the
+// batch endpoint and then fetches the full records, which the batch endpoint
+// does not return.
+func (c *Client) Query(mods []module.Version) ([][]*Vuln, error) {
+	ids := make([][]string, len(mods))
+	for start := 0; start < len(mods); start += maxBatch {
+		end := start + maxBatch
+		if end > len(mods) {
+			end = len(mods)
+		}
+		if err := c.queryBatch(mods[start:end], ids[start:end]); err != nil {
+			return nil, err
+		}
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, list := range ids {
+		for _, id := range list {
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+	}
+	records, errs := workpool.Map(c.concurrency, c.failFast, unique, c.vuln)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Vuln, len(unique))
+	for i, id := range unique {
+		byID[id] = records[i]
+	}
+
+	vulns := make([][]*Vuln, len(mods))
+	for i, list := range ids {
+		for _, id := range list {
+			vulns[i] = append(vulns[i], byID[id])
+		}
+	}
+	return vulns, nil
+}
+
+// queryBatch stores the vulnerability IDs of each module version in ids.
+// Results the API splits into pages are completed through single queries.
+func (c *Client) queryBatch(mods []module.Version, ids [][]string) error {
+	var req struct {
+		Queries []query `json:"queries"`
+	}
+	for _, mv := range mods {
+		req.Queries = append(req.Queries, newQuery(mv))
+	}
+	var resp struct {
+		Results []queryResult `json:"results"`
+	}
+	if err := c.post("/v1/querybatch", req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Results) != len(mods) {
+		return fmt.Errorf("OSV returned %d results for %d queries", len(resp.Results), len(mods))
+	}
+	for i, r := range resp.Results {
+		for {
+			for _, v := range r.Vulns {
+				ids[i] = append(ids[i], v.ID)
+			}
+			if r.NextPageToken == "" {
+				break
+			}
+			q := newQuery(mods[i])
+			q.PageToken = r.NextPageToken
+			r = queryResult{}
+			if err := c.post("/v1/query", q, &r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newQuery(mv module.Version) query {
+	var q query
+	q.Package.Name = mv.Path
+	q.Package.Ecosystem = Ecosystem
+	// OSV records of Go modules list versions without the "v" prefix.
+	q.Version = strings.TrimPrefix(mv.Version, "v")
+	return q
+}
+
+// vuln fetches the full record of a vulnerability.
+func (c *Client) vuln(id string) (*Vuln, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.base+"/v1/vulns/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var v Vuln
+	if err := decode(resp, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (c *Client) post(path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.base+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+func decode(resp *http.Response, out any) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", resp.Request.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Request.URL, resp.Status)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decoding %s: %w", resp.Request.URL, err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package osv
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// DB is a downloaded copy of the OSV database, such as the Go/all.zip
+// export at https://osv-vulnerabilities.storage.googleapis.com.
+type DB struct {
+	// byModule indexes the records by the Go modules they affect.
+	byModule map[string][]*Vuln
+}
+
+// LoadDB reads the database at path: a zip archive or a directory of OSV
+// JSON records, or a single JSON file holding one record or an array of
+// records.
+func LoadDB(path string) (*DB, error) {
+	db := &DB{byModule: make(map[string][]*Vuln)}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		err = filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(name, ".json") {
+				return err
+			}
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			return db.add(name, data)
+		})
+	case strings.HasSuffix(path, ".zip"):
+		err = db.addZip(path)
+	default:
+		var data []byte
+		if data, err = os.ReadFile(path); err == nil {
+			err = db.add(path, data)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *DB) addZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".json") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := db.add(path+":"+f.Name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add indexes the record or array of records in data; name is only used in
+// error messages.
+func (db *DB) add(name string, data []byte) error {
+	var vulns []*Vuln
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &vulns); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	} else {
+		var v Vuln
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		vulns = append(vulns, &v)
+	}
+	for _, v := range vulns {
+		seen := make(map[string]bool)
+		for _, a := range v.Affected {
+			if a.Package.Ecosystem == Ecosystem && !seen[a.Package.Name] {
+				seen[a.Package.Name] = true
+				db.byModule[a.Package.Name] = append(db.byModule[a.Package.Name], v)
+			}
+		}
+	}
+	return nil
+}
+
+// Query returns the records affecting each module version.
+func (db *DB) Query(mods []module.Version) ([][]*Vuln, error) {
+	vulns := make([][]*Vuln, len(mods))
+	for i, mv := range mods {
+		for _, v := range db.byModule[mv.Path] {
+			if ok, _ := v.Affects(mv); ok {
+				vulns[i] = append(vulns[i], v)
+			}
+		}
+	}
+	return vulns, nil
+}
@@ -0,0 +1,145 @@
+// Package osv looks up known vulnerabilities of module versions in the OSV
+// database (https://osv.dev), either through its API or in a downloaded
+// copy of the database.
+package osv
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Ecosystem is the OSV ecosystem of Go modules.
+const Ecosystem = "Go"
+
+// Vuln is an OSV record, reduced to the fields used by this tool.
+type Vuln struct {
+	ID       string     `json:"id"`
+	Summary  string     `json:"summary"`
+	Details  string     `json:"details"`
+	Aliases  []string   `json:"aliases"`
+	Severity []Score    `json:"severity"`
+	Affected []Affected `json:"affected"`
+
+	DatabaseSpecific struct {
+		// Severity is set by some databases, such as GitHub's, to LOW,
+		// MODERATE, HIGH or CRITICAL.
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// Score is a severity score such as a CVSS vector.
+type Score struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected lists the affected versions of one package.
+type Affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []Range  `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+// Range is a sequence of events that introduce and fix a vulnerability.
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is a single range event; exactly one field is set.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// Affects reports whether the vulnerability affects the module version, and
+// returns the version that fixes it, if known.
+func (v *Vuln) Affects(mv module.Version) (affected bool, fixed string) {
+	for _, a := range v.Affected {
+		if a.Package.Ecosystem != Ecosystem || a.Package.Name != mv.Path {
+			continue
+		}
+		for _, ver := range a.Versions {
+			if canonical(ver) == mv.Version {
+				affected = true
+			}
+		}
+		for _, r := range a.Ranges {
+			if r.Type != "SEMVER" {
+				continue
+			}
+			if ok, f := r.contains(mv.Version); ok {
+				affected = true
+				if f != "" && (fixed == "" || semver.Compare(f, fixed) < 0) {
+					fixed = f
+				}
+			}
+		}
+	}
+	return affected, fixed
+}
+
+// contains reports whether version lies in the range and returns the fixed
+// version ending the interval that contains it. Events of a SEMVER range are
+// evaluated in version order, as the OSV schema specifies.
+func (r Range) contains(version string) (bool, string) {
+	in := false
+	for _, e := range sortedEvents(r.Events) {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced != "0" && semver.Compare(version, canonical(e.Introduced)) < 0 {
+				return in, ""
+			}
+			in = true
+		case e.Fixed != "":
+			if semver.Compare(version, canonical(e.Fixed)) < 0 {
+				if in {
+					return true, canonical(e.Fixed)
+				}
+				return false, ""
+			}
+			in = false
+		case e.LastAffected != "":
+			if semver.Compare(version, canonical(e.LastAffected)) <= 0 {
+				return in, ""
+			}
+			in = false
+		}
+	}
+	return in, ""
+}
+
+// sortedEvents returns the events ordered by their versions, with "0" first.
+func sortedEvents(events []Event) []Event {
+	sorted := append([]Event(nil), events...)
+	version := func(e Event) string {
+		switch {
+		case e.Introduced == "0":
+			return ""
+		case e.Introduced != "":
+			return canonical(e.Introduced)
+		case e.Fixed != "":
+			return canonical(e.Fixed)
+		}
+		return canonical(e.LastAffected)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return semver.Compare(version(sorted[i]), version(sorted[j])) < 0
+	})
+	return sorted
+}
+
+// canonical adds the "v" prefix that the OSV Go ecosystem omits.
+func canonical(v string) string {
+	if v == "" || strings.HasPrefix(v, "v") {
+		return v
+	}
+	return "v" + v
+}
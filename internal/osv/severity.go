@@ -0,0 +1,151 @@
+package osv
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Severity classifies a vulnerability like the GitHub advisory database.
+type Severity string
+
+// Severities from least to most severe. SeverityUnknown is used for records
+// that carry neither a severity nor a CVSS v3 vector, which includes most
+// records of the Go vulnerability database.
+const (
+	SeverityUnknown  Severity = "unknown"
+	SeverityLow      Severity = "low"
+	SeverityModerate Severity = "moderate"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Rank orders severities; SeverityUnknown ranks lowest.
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityLow:
+		return 1
+	case SeverityModerate:
+		return 2
+	case SeverityHigh:
+		return 3
+	case SeverityCritical:
+		return 4
+	}
+	return 0
+}
+
+// ParseSeverity parses one of low, moderate, high or critical.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(s)); sev {
+	case SeverityLow, SeverityModerate, SeverityHigh, SeverityCritical:
+		return sev, nil
+	}
+	return "", fmt.Errorf("invalid severity %q, expected low, moderate, high or critical", s)
+}
+
+// Rating returns the severity of the vulnerability: the one assigned by
+// the database if there is one, otherwise the rating of its CVSS v3 base
+// score.
+func (v *Vuln) Rating() Severity {
+	if sev, err := ParseSeverity(v.DatabaseSpecific.Severity); err == nil {
+		return sev
+	}
+	for _, s := range v.Severity {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		score, err := cvss3BaseScore(s.Score)
+		if err != nil {
+			continue
+		}
+		switch {
+		case score >= 9:
+			return SeverityCritical
+		case score >= 7:
+			return SeverityHigh
+		case score >= 4:
+			return SeverityModerate
+		case score > 0:
+			return SeverityLow
+		}
+	}
+	return SeverityUnknown
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector as
+// defined in section 7.1 of the CVSS v3.1 specification.
+func cvss3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) < 2 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %q", vector)
+	}
+	metrics := make(map[string]string)
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, ":")
+		if !ok {
+			return 0, fmt.Errorf("malformed CVSS metric %q", p)
+		}
+		metrics[k] = v
+	}
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+	w := make(map[string]float64)
+	for k, values := range weights {
+		v, ok := values[metrics[k]]
+		if !ok {
+			return 0, fmt.Errorf("CVSS vector %q lacks a valid %s metric", vector, k)
+		}
+		w[k] = v
+	}
+	changed := metrics["S"] == "C"
+	if metrics["S"] != "U" && !changed {
+		return 0, fmt.Errorf("CVSS vector %q lacks a valid S metric", vector)
+	}
+	switch metrics["PR"] {
+	case "N":
+		w["PR"] = 0.85
+	case "L":
+		w["PR"] = 0.62
+		if changed {
+			w["PR"] = 0.68
+		}
+	case "H":
+		w["PR"] = 0.27
+		if changed {
+			w["PR"] = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("CVSS vector %q lacks a valid PR metric", vector)
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w["AV"] * w["AC"] * w["PR"] * w["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp returns the smallest number with one decimal place that is equal
+// to or higher than x, as specified in Appendix A of CVSS v3.1.
+func roundUp(x float64) float64 {
+	i := math.Round(x * 100000)
+	if math.Mod(i, 10000) == 0 {
+		return i / 100000
+	}
+	return (math.Floor(i/10000) + 1) / 10
+}
@@ -0,0 +1,99 @@
+// Package outdated finds newer versions of required modules.
+package outdated
+
+import (
+	"context"
+	"errors"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/versions"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Update is a newer version of a required module within the same module
+// path.
+type Update struct {
+	Path     string         `json:"path"`
+	Indirect bool           `json:"indirect"`
+	Current  string         `json:"current"`
+	Latest   string         `json:"latest"`
+	Delta    versions.Delta `json:"delta"`
+	// Line is the line of the require directive in go.mod.
+	Line int `json:"line"`
+}
+
+// MajorUpgrade is the highest newer major version published under a
+// different module path, as required by semantic import versioning.
+type MajorUpgrade struct {
+	Path     string `json:"path"`
+	Indirect bool   `json:"indirect"`
+	Current  string `json:"current"`
+	NewPath  string `json:"newPath"`
+	Latest   string `json:"latest"`
+	Line     int    `json:"line"`
+}
+
+// Report lists the available updates, in the order of the requirements.
+type Report struct {
+	Updates []Update       `json:"updates"`
+	Majors  []MajorUpgrade `json:"majors"`
+}
+
+type result struct {
+	update *Update
+	major  *MajorUpgrade
+}
+
+// Find looks up newer versions of reqs, querying the proxy for up to
+// concurrency modules at a time. Requirements replaced by a directory are
+// skipped. Lookups that fail are joined into the returned error, or stop the
+// others if failFast is set; the report still contains the updates found
+// for the other modules.
+func Find(ctx context.Context, c *proxy.Client, reqs []gomod.Require, concurrency int, failFast bool) (*Report, error) {
+	var todo []gomod.Require
+	for _, r := range reqs {
+		if r.Replace != nil && r.Replace.Version == "" {
+			continue
+		}
+		todo = append(todo, r)
+	}
+
+	results, errs := workpool.Map(concurrency, failFast, todo, func(r gomod.Require) (result, error) {
+		res, err := find(ctx, c, r)
+		return res, failure.Module(r.Path, r.Version, err)
+	})
+	rep := &Report{Updates: []Update{}, Majors: []MajorUpgrade{}}
+	for _, res := range results {
+		if res.update != nil {
+			rep.Updates = append(rep.Updates, *res.update)
+		}
+		if res.major != nil {
+			rep.Majors = append(rep.Majors, *res.major)
+		}
+	}
+	return rep, errors.Join(errs...)
+}
+
+func find(ctx context.Context, c *proxy.Client, r gomod.Require) (result, error) {
+	var res result
+	latest, err := resolve.Latest(ctx, c, r.Path)
+	if err != nil {
+		return res, err
+	}
+	if d := versions.DeltaOf(r.Version, latest); d != versions.None {
+		res.update = &Update{Path: r.Path, Indirect: r.Indirect, Current: r.Version, Latest: latest, Delta: d, Line: r.Line}
+	}
+
+	next, v, err := resolve.LatestMajor(ctx, c, r.Path, r.Version)
+	if err != nil {
+		return res, err
+	}
+	if next == "" {
+		return res, nil
+	}
+	res.major = &MajorUpgrade{Path: r.Path, Indirect: r.Indirect, Current: r.Version, NewPath: next, Latest: v, Line: r.Line}
+	return res, nil
+}
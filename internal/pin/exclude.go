@@ -0,0 +1,124 @@
+package pin
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gomod"
+)
+
+// PlanExclude adds the exclude directives add to the go.mod at file and
+// removes the directives remove, where an empty version removes every
+// exclude of the module. The remaining exclusions are written as a single
+// exclude block at the end of the file, sorted by module path and version
+// and without duplicates, even if they were spread over several statements. Result.Added and Result.Removed list the added and
+// removed exclusions, with the excluded version in New and Old.
+func PlanExclude(file string, add, remove []module.Version) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(filepath.Dir(file), "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+
+	f, err := modfile.Parse(file, orig.Data, nil)
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[module.Version]bool)
+	for _, x := range f.Exclude {
+		excluded[x.Mod] = true
+	}
+	for _, m := range remove {
+		var found bool
+		for x := range excluded {
+			if x.Path != m.Path || m.Version != "" && x.Version != m.Version || !excluded[x] {
+				continue
+			}
+			found = true
+			excluded[x] = false
+			res.Removed = append(res.Removed, Change{Path: x.Path, Old: x.Version})
+		}
+		if !found {
+			if m.Version == "" {
+				return nil, fmt.Errorf("%s: no exclude directive for %s", file, m.Path)
+			}
+			return nil, fmt.Errorf("%s: no exclude directive for %s %s", file, m.Path, m.Version)
+		}
+	}
+	sort.Slice(res.Removed, func(i, j int) bool {
+		a, b := res.Removed[i], res.Removed[j]
+		return a.Path < b.Path || a.Path == b.Path && semver.Compare(a.Old, b.Old) < 0
+	})
+	for _, m := range add {
+		if err := module.Check(m.Path, m.Version); err != nil {
+			return nil, err
+		}
+		if semver.Canonical(m.Version) != m.Version {
+			return nil, fmt.Errorf("%s %s: version must be canonical, such as %s", m.Path, m.Version, semver.Canonical(m.Version))
+		}
+		if excluded[m] {
+			continue
+		}
+		excluded[m] = true
+		res.Added = append(res.Added, Change{Path: m.Path, New: m.Version})
+		for _, r := range f.Require {
+			if r.Mod == m {
+				slog.Warn("excluding the required version; the go command will select the next higher one", "module", m.Path, "version", m.Version)
+			}
+		}
+	}
+
+	// The exclusions are written back as a single block, whether they
+	// were spread over several exclude statements or not, sorted and
+	// without duplicates.
+	var keep []module.Version
+	for x, ok := range excluded {
+		if ok {
+			keep = append(keep, x)
+		}
+	}
+	module.Sort(keep)
+	// DropExclude drops every directive of a version at once.
+	for x := range excluded {
+		if err := f.DropExclude(x.Path, x.Version); err != nil {
+			return nil, err
+		}
+	}
+	f.Cleanup()
+	// AddExclude would start a new statement for every module, so the
+	// block is built here.
+	block := &modfile.LineBlock{Token: []string{"exclude"}}
+	for _, x := range keep {
+		line := &modfile.Line{Token: []string{modfile.AutoQuote(x.Path), x.Version}, InBlock: true}
+		block.Line = append(block.Line, line)
+		f.Exclude = append(f.Exclude, &modfile.Exclude{Mod: x, Syntax: line})
+	}
+	switch len(block.Line) {
+	case 0:
+	case 1:
+		line := block.Line[0]
+		line.Token, line.InBlock = append([]string{"exclude"}, line.Token...), false
+		f.Syntax.Stmt = append(f.Syntax.Stmt, line)
+	default:
+		f.Syntax.Stmt = append(f.Syntax.Stmt, block)
+	}
+
+	f.SortBlocks()
+	f.Cleanup()
+	if res.New, err = f.Format(); err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", file, err)
+	}
+	return res, nil
+}
@@ -0,0 +1,180 @@
+package pin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/imports"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// majorTarget is a requirement moving to the module path of a newer major
+// version.
+type majorTarget struct {
+	gomod.Require
+	NewPath string
+	Latest  string
+}
+
+// PlanMajor moves the requirements on paths to the module path of their
+// highest major version, such as example.com/m/v3 for example.com/m, at its
+// latest release. The old requirements and the tool directives of their
+// packages are replaced, and `go get` on a copy of go.mod adds the
+// requirements of the new versions. If opts.RewriteImports is set, the
+// imports of the Go files of the module follow. Result.Removed lists the
+// old requirements and Result.Added the new ones.
+func PlanMajor(ctx context.Context, file string, paths []string, opts UpdateOptions) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+
+	reqs, err := updateTargets(orig, paths)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reqs {
+		if opts.ForbidMajor != nil && opts.ForbidMajor(r.Path) {
+			return nil, fmt.Errorf("%s: major updates are forbidden by forbidMajor in the config", r.Path)
+		}
+	}
+	targets, errs := workpool.Map(opts.Concurrency, opts.FailFast, reqs, func(r gomod.Require) (majorTarget, error) {
+		newPath, latest, err := resolve.LatestMajor(ctx, opts.Proxy, r.Path, r.Version)
+		if err == nil && newPath == "" {
+			err = errors.New("no newer major version published")
+		}
+		if err == nil && orig.Excluded(newPath, latest) {
+			err = fmt.Errorf("the latest major version %s@%s is excluded by go.mod", newPath, latest)
+		}
+		return majorTarget{Require: r, NewPath: newPath, Latest: latest}, failure.Module(r.Path, r.Version, err)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(file, orig.Data, nil)
+	if err != nil {
+		return nil, err
+	}
+	moved := make(map[string]string)
+	var queries []string
+	for _, t := range targets {
+		moved[t.Path] = t.NewPath
+		queries = append(queries, t.NewPath+"@"+t.Latest)
+		if err := f.DropRequire(t.Path); err != nil {
+			return nil, err
+		}
+	}
+	// Tools are moved along if their package belongs to a moved module and
+	// not to a required module with a longer path.
+	var tools []string
+	for _, tool := range f.Tool {
+		tools = append(tools, tool.Path)
+	}
+	for _, tool := range tools {
+		owner := ""
+		for _, r := range orig.Requires() {
+			if within(tool, r.Path) && len(r.Path) > len(owner) {
+				owner = r.Path
+			}
+		}
+		to, ok := moved[owner]
+		if !ok {
+			continue
+		}
+		if err := f.DropTool(tool); err != nil {
+			return nil, err
+		}
+		if err := f.AddTool(to + strings.TrimPrefix(tool, owner)); err != nil {
+			return nil, err
+		}
+	}
+	f.Cleanup()
+	data, err := f.Format()
+	if err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", file, err)
+	}
+
+	tmp, err := os.MkdirTemp("", "pin-major-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	modFile := filepath.Join(tmp, "go.mod")
+	sumFile := filepath.Join(tmp, "go.sum")
+	if err := os.WriteFile(modFile, data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(sumFile, res.OldSum, 0o644); err != nil {
+		return nil, err
+	}
+	if err := gocmd.Get(ctx, dir, modFile, queries); err != nil {
+		return nil, err
+	}
+	if res.New, err = os.ReadFile(modFile); err != nil {
+		return nil, err
+	}
+	if res.NewSum, err = os.ReadFile(sumFile); err != nil {
+		return nil, err
+	}
+	updated, err := gomod.Parse(file, res.New)
+	if err != nil {
+		return nil, err
+	}
+	// go get marks the new requirements indirect as long as the imports
+	// still name the old paths; they keep the kind of the requirements
+	// they replace.
+	indirect := make(map[string]bool)
+	for _, t := range targets {
+		indirect[t.NewPath] = t.Indirect
+	}
+	var list []*modfile.Require
+	for _, r := range updated.File.Require {
+		if ind, ok := indirect[r.Mod.Path]; ok {
+			r = &modfile.Require{Mod: r.Mod, Indirect: ind}
+		}
+		list = append(list, r)
+	}
+	updated.File.SetRequireSeparateIndirect(list)
+	updated.File.Cleanup()
+	if res.New, err = updated.File.Format(); err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", file, err)
+	}
+	res.Added, res.Changed = changes(orig.File, updated.File)
+	for _, t := range targets {
+		res.Removed = append(res.Removed, Change{Path: t.Path, Old: t.Version, Indirect: t.Indirect})
+	}
+
+	if opts.RewriteImports {
+		var required []string
+		for _, r := range updated.Requires() {
+			required = append(required, r.Path)
+		}
+		if res.Sources, err = imports.Rewrite(dir, moved, required); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// within reports whether the package pkg is in the module at mod.
+func within(pkg, mod string) bool {
+	return pkg == mod || strings.HasPrefix(pkg, mod+"/")
+}
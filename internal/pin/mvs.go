@@ -0,0 +1,76 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/mvs"
+	"pin-go-dependencies/internal/proxy"
+)
+
+// PlanFixMVS raises the requirements of the go.mod at file that are below
+// the version minimal version selection picks from the requirement graph to
+// that version, reading the go.mod files of the dependencies from c. Other
+// requirements, and those matched by exclude if it is set, are left as they
+// are. The go.mod hashes of the new versions are added to go.sum.
+func PlanFixMVS(ctx context.Context, file string, c *proxy.Client, concurrency int, failFast bool, exclude func(string) bool) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+
+	found, err := mvs.NewReqs(c, orig).Understated(ctx, concurrency, failFast)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return res, nil
+	}
+	f := orig.File
+	replacements := make(map[string]module.Version)
+	for _, u := range found {
+		if exclude != nil && exclude(u.Path) {
+			continue
+		}
+		if orig.Excluded(u.Path, u.Selected) {
+			return nil, fmt.Errorf("%s: minimal version selection picks %s@%s, which is excluded; run go mod tidy to let the go command pick the next version", file, u.Path, u.Selected)
+		}
+		if err := f.AddRequire(u.Path, u.Selected); err != nil {
+			return nil, err
+		}
+		if rep := orig.Replacement(module.Version{Path: u.Path, Version: u.Selected}); rep != nil {
+			replacements[u.Path] = rep.New
+		}
+		res.Changed = append(res.Changed, Change{Path: u.Path, Old: u.Version, New: u.Selected, Indirect: u.Indirect})
+	}
+	f.Cleanup()
+	if res.New, err = f.Format(); err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", file, err)
+	}
+
+	sum, err := gosum.Parse(res.SumFile, res.OldSum)
+	if err != nil {
+		return nil, err
+	}
+	lines := len(sum.Lines)
+	if err := addGoModHashes(ctx, sum, dir, f, replacements, concurrency, failFast); err != nil {
+		return nil, err
+	}
+	if len(sum.Lines) != lines {
+		res.NewSum = sum.Format()
+	}
+	return res, nil
+}
@@ -0,0 +1,433 @@
+// Package pin computes and applies go.mod rewrites that give every module in
+// the build list an explicit require entry at its resolved version.
+package pin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/diff"
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/fsutil"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/imports"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// maxRounds bounds how often the build list is recomputed. Every explicit
+// requirement makes the go command load that module's go.mod, which can pull
+// further modules into the build list, so pinning is repeated until stable.
+const maxRounds = 10
+
+// Change describes a single require entry added or updated by a pin run.
+type Change struct {
+	Path     string `json:"path"`
+	Old      string `json:"old,omitempty"`
+	New      string `json:"new,omitempty"`
+	Indirect bool   `json:"indirect"`
+}
+
+// Result is the outcome of planning a pin run. Nothing is written to disk
+// until Apply is called.
+type Result struct {
+	File    string
+	Old     []byte
+	New     []byte
+	Added   []Change
+	Changed []Change
+	// Removed lists the requirements dropped by PlanPrune and PlanMajor,
+	// or the exclusions dropped by PlanExclude; their New is empty.
+	Removed []Change
+	// Toolchain is set if the toolchain directive changed; its Path is
+	// "toolchain" and Old is empty if there was none.
+	Toolchain *Change
+
+	// SumFile is the go.sum next to File. OldSum and NewSum hold its
+	// contents before and after adding the go.mod hashes of new pins.
+	SumFile string
+	OldSum  []byte
+	NewSum  []byte
+
+	// Sources are the Go files of the module whose imports PlanMajor
+	// rewrote.
+	Sources []imports.File
+}
+
+// Modified reports whether applying the result would modify go.mod or go.sum.
+func (r *Result) Modified() bool {
+	return !bytes.Equal(r.Old, r.New) || !bytes.Equal(r.OldSum, r.NewSum) || len(r.Sources) > 0
+}
+
+// Diff returns a unified diff of the pending go.mod, go.sum and source file
+// changes.
+func (r *Result) Diff() []byte {
+	var buf bytes.Buffer
+	buf.Write(diff.Unified("a/"+filepath.ToSlash(r.File), "b/"+filepath.ToSlash(r.File), r.Old, r.New))
+	buf.Write(diff.Unified("a/"+filepath.ToSlash(r.SumFile), "b/"+filepath.ToSlash(r.SumFile), r.OldSum, r.NewSum))
+	for _, s := range r.Sources {
+		buf.Write(diff.Unified("a/"+filepath.ToSlash(s.Name), "b/"+filepath.ToSlash(s.Name), s.Old, s.New))
+	}
+	return buf.Bytes()
+}
+
+// Files returns the files applying the result may write.
+func (r *Result) Files() []string {
+	files := []string{r.File, r.SumFile}
+	for _, s := range r.Sources {
+		files = append(files, s.Name)
+	}
+	return files
+}
+
+// Options configures Plan.
+type Options struct {
+	Workspace WorkspaceMode
+
+	// AsOf, if set, pins every module to the newest release published
+	// before the cutoff instead of the version the go command selects. The
+	// releases are looked up through Proxy, which is required then.
+	AsOf *resolve.AsOfOptions
+	// Proxy, if set, is also used to validate that module replacements
+	// exist. It is required unless AllowRetracted is set.
+	Proxy *proxy.Client
+	// AllowRetracted allows pinning versions retracted by their authors.
+	AllowRetracted bool
+	// Exclude, if set, reports modules to leave alone: their requirements
+	// are kept as they are and they are never added.
+	Exclude func(path string) bool
+	// Toolchain, if set, is the toolchain name, such as go1.22.3, the
+	// toolchain directive is set to.
+	Toolchain string
+	// Concurrency bounds the go commands and proxy requests run at a
+	// time; 0 selects workpool.DefaultSize.
+	Concurrency int
+	// FailFast stops at the first module that fails instead of reporting
+	// all failures.
+	FailFast bool
+}
+
+// excluded reports whether opts.Exclude matches path.
+func (opts Options) excluded(path string) bool {
+	return opts.Exclude != nil && opts.Exclude(path)
+}
+
+// Plan reads the go.mod at file, resolves the build list of its module and
+// returns the rewritten go.mod and go.sum contents.
+func Plan(ctx context.Context, file string, opts Options) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	data := orig.Data
+	dir := filepath.Dir(file)
+	ws, err := loadWorkspace(ctx, dir, opts.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{File: file, Old: data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sum, err := gosum.Parse(res.SumFile, res.OldSum)
+	if err != nil {
+		return nil, err
+	}
+	sumLines := len(sum.Lines)
+
+	if opts.AsOf != nil && opts.AsOf.Excluded == nil {
+		// The go command never selects excluded versions, and neither
+		// does the dated resolution.
+		asOf := *opts.AsOf
+		asOf.Excluded = orig.Excluded
+		opts.AsOf = &asOf
+	}
+	dated := make(map[string]string)
+	cur, err := requireTools(ctx, orig, opts)
+	if err != nil {
+		return nil, err
+	}
+	var final *modfile.File
+	var replacements map[string]module.Version
+	var listed int
+	for round := 0; ; round++ {
+		if round == maxRounds {
+			return nil, fmt.Errorf("%s: build list did not settle after %d rounds", file, maxRounds)
+		}
+		var mods []gocmd.Module
+		if round == 0 && bytes.Equal(cur, data) {
+			mods, err = gocmd.ListModules(ctx, dir, gocmd.ListOptions{GoWork: "off"})
+		} else {
+			mods, err = listAlternate(ctx, dir, cur, sum)
+		}
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("listed build list", "file", file, "round", round, "modules", len(mods))
+		f, err := modfile.Parse(file, cur, nil)
+		if err != nil {
+			return nil, err
+		}
+		if opts.AsOf != nil {
+			if err := resolveAsOf(ctx, dated, mods, ws, opts); err != nil {
+				return nil, err
+			}
+		}
+		replacements, listed = pinRequires(f, mods, ws, dated, opts.excluded)
+		if err := addGoModHashes(ctx, sum, dir, f, replacements, opts.Concurrency, opts.FailFast); err != nil {
+			return nil, err
+		}
+		next, err := f.Format()
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %w", file, err)
+		}
+		if bytes.Equal(next, cur) && round > 0 {
+			final = f
+			// The dated versions were counted by the resolver.
+			metrics.Add(metrics.ModulesResolved, int64(listed))
+			break
+		}
+		cur = next
+	}
+	if opts.Proxy != nil {
+		if err := validateReplacements(ctx, opts.Proxy, replacements, opts.Concurrency, opts.FailFast); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.AllowRetracted {
+		if opts.Proxy == nil {
+			return nil, fmt.Errorf("a module proxy is needed to check for retracted versions")
+		}
+		if err := checkRetracted(ctx, opts.Proxy, final, ws, replacements, opts.excluded, opts.Concurrency, opts.FailFast); err != nil {
+			return nil, err
+		}
+	}
+	res.New = cur
+	res.Added, res.Changed = changes(orig.File, final)
+	res.NewSum = res.OldSum
+	if len(sum.Lines) != sumLines {
+		res.NewSum = sum.Format()
+	}
+	if opts.Toolchain != "" {
+		if err := setToolchain(res, opts.Toolchain); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// listAlternate lists the build list for the go.mod content data without
+// touching the files in the module: data and sum are written to a temporary
+// directory and evaluated through -modfile. The go command may record the
+// checksums of modules new to the graph there; they are merged into sum.
+func listAlternate(ctx context.Context, dir string, data []byte, sum *gosum.Sum) ([]gocmd.Module, error) {
+	tmp, err := os.MkdirTemp("", "pin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	modFile := filepath.Join(tmp, "go.mod")
+	if err := os.WriteFile(modFile, data, 0o644); err != nil {
+		return nil, err
+	}
+	sumFile := filepath.Join(tmp, "go.sum")
+	if err := os.WriteFile(sumFile, sum.Format(), 0o644); err != nil {
+		return nil, err
+	}
+	mods, err := gocmd.ListModules(ctx, dir, gocmd.ListOptions{ModFile: modFile, UpdateSum: true, GoWork: "off"})
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := gosum.Read(sumFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range recorded.Lines {
+		sum.Add(l)
+	}
+	return mods, nil
+}
+
+// resolveAsOf adds the dated versions of all modules in mods that have not
+// been looked up yet to dated.
+func resolveAsOf(ctx context.Context, dated map[string]string, mods []gocmd.Module, ws *workspace, opts Options) error {
+	current := make(map[string]string)
+	for _, m := range mods {
+		if m.Main || m.Version == "" || m.Replace != nil || ws.local(m.Path) || opts.excluded(m.Path) {
+			continue
+		}
+		if _, ok := dated[m.Path]; !ok {
+			current[m.Path] = m.Version
+		}
+	}
+	found, err := resolve.AsOf(ctx, opts.Proxy, current, *opts.AsOf)
+	if err != nil {
+		return err
+	}
+	for path, v := range found {
+		dated[path] = v
+	}
+	return nil
+}
+
+// pinRequires rewrites the requirements of f to the versions in mods and
+// returns the replacements in effect, keyed by the replaced module path (a
+// replacement by a directory has an empty version), and the number of
+// modules pinned to a version not in dated. Versions in dated take
+// precedence, followed by the versions of the workspace build list.
+//
+// Workspace members, replaced modules and modules matched by exclude are left
+// alone: the version on the require line of a replaced module only selects
+// which replace directive applies, and a directory replacement has no
+// version to pin at all.
+func pinRequires(f *modfile.File, mods []gocmd.Module, ws *workspace, dated map[string]string, exclude func(string) bool) (replacements map[string]module.Version, listed int) {
+	current := make(map[string]*modfile.Require, len(f.Require))
+	for _, r := range f.Require {
+		current[r.Mod.Path] = r
+	}
+
+	var reqs []*modfile.Require
+	replacements = make(map[string]module.Version)
+	for _, m := range mods {
+		if m.Main || m.Version == "" || ws.local(m.Path) {
+			continue
+		}
+		if m.Replace != nil {
+			replacements[m.Path] = module.Version{Path: m.Replace.Path, Version: m.Replace.Version}
+			continue
+		}
+		if exclude(m.Path) {
+			slog.Debug("skipped module", "module", m.Path, "version", m.Version, "reason", "excluded by config")
+			continue
+		}
+		version, ok := dated[m.Path]
+		if !ok {
+			version = ws.version(m.Path, m.Version)
+			listed++
+		}
+		// Modules that were not required before only reach the build list
+		// through other modules, so they are indirect.
+		indirect := true
+		if r, ok := current[m.Path]; ok {
+			indirect = r.Indirect
+			delete(current, m.Path)
+		}
+		reqs = append(reqs, &modfile.Require{Mod: module.Version{Path: m.Path, Version: version}, Indirect: indirect})
+	}
+	// Requirements the go command did not report (which should not happen
+	// for a consistent go.mod) and requirements on workspace members,
+	// replaced and excluded modules are kept as they are.
+	for _, r := range current {
+		reqs = append(reqs, &modfile.Require{Mod: r.Mod, Indirect: r.Indirect})
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].Mod.Path < reqs[j].Mod.Path })
+
+	f.SetRequireSeparateIndirect(reqs)
+	f.Cleanup()
+	return replacements, listed
+}
+
+// changes lists the requirements of after that are new or differ from before.
+func changes(before, after *modfile.File) (added, changed []Change) {
+	old := make(map[string]string, len(before.Require))
+	for _, r := range before.Require {
+		old[r.Mod.Path] = r.Mod.Version
+	}
+	for _, r := range after.Require {
+		v, ok := old[r.Mod.Path]
+		switch {
+		case !ok:
+			added = append(added, Change{Path: r.Mod.Path, New: r.Mod.Version, Indirect: r.Indirect})
+		case v != r.Mod.Version:
+			changed = append(changed, Change{Path: r.Mod.Path, Old: v, New: r.Mod.Version, Indirect: r.Indirect})
+		}
+	}
+	return added, changed
+}
+
+// addGoModHashes adds the go.mod hash of every requirement of f missing from
+// sum, running up to concurrency go commands at a time. For replaced modules
+// the go command records the hash of the replacement instead, and nothing
+// for directory replacements.
+func addGoModHashes(ctx context.Context, sum *gosum.Sum, dir string, f *modfile.File, replacements map[string]module.Version, concurrency int, failFast bool) error {
+	var todo []module.Version
+	for _, r := range f.Require {
+		mv := r.Mod
+		if rep, ok := replacements[mv.Path]; ok {
+			if rep.Version == "" {
+				continue
+			}
+			mv = rep
+		}
+		if !sum.Has(mv.Path, mv.Version+"/go.mod") {
+			todo = append(todo, mv)
+		}
+	}
+	hashes, errs := workpool.Map(concurrency, failFast, todo, func(mv module.Version) (string, error) {
+		h, err := gocmd.GoModHash(ctx, dir, mv.Path, mv.Version)
+		return h, failure.Module(mv.Path, mv.Version, err)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	for i, mv := range todo {
+		sum.Add(gosum.Line{Path: mv.Path, Version: mv.Version + "/go.mod", Hash: hashes[i]})
+	}
+	return nil
+}
+
+// validateReplacements checks that the proxy serves every module version used
+// as a replacement, with up to concurrency requests at a time. Directory
+// replacements are never looked up.
+func validateReplacements(ctx context.Context, c *proxy.Client, replacements map[string]module.Version, concurrency int, failFast bool) error {
+	var olds []string
+	for old, rep := range replacements {
+		if rep.Version != "" {
+			olds = append(olds, old)
+		}
+	}
+	sort.Strings(olds)
+	_, errs := workpool.Map(concurrency, failFast, olds, func(old string) (struct{}, error) {
+		rep := replacements[old]
+		if _, err := c.Info(ctx, rep.Path, rep.Version); err != nil {
+			return struct{}{}, failure.Module(old, "", fmt.Errorf("replacement %s: %w", rep, err))
+		}
+		return struct{}{}, nil
+	})
+	return errors.Join(errs...)
+}
+
+// Apply writes the planned go.sum, source file and go.mod contents to disk.
+// go.sum is written first so that a failure never leaves a go.mod behind
+// whose requirements lack checksums.
+func (r *Result) Apply() error {
+	if !bytes.Equal(r.OldSum, r.NewSum) {
+		if err := fsutil.ReplaceFile(r.SumFile, r.NewSum); err != nil {
+			return err
+		}
+	}
+	for _, s := range r.Sources {
+		if err := fsutil.ReplaceFile(s.Name, s.New); err != nil {
+			return err
+		}
+	}
+	if bytes.Equal(r.Old, r.New) {
+		return nil
+	}
+	return fsutil.ReplaceFile(r.File, r.New)
+}
@@ -0,0 +1,220 @@
+package pin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/proxy"
+)
+
+var replaceTests = []struct {
+	name  string
+	gomod string
+	// mods is the build list reported by go list -m all.
+	mods []gocmd.Module
+	// want is the go.mod after pinning, and replacements the effective
+	// targets of the replaced modules.
+	want         string
+	replacements map[string]module.Version
+	// fetched are the proxy requests validating the replacements.
+	fetched []string
+}{
+	{
+		name: "directory",
+		gomod: `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/local v0.0.0-00010101000000-000000000000
+)
+
+replace example.com/local => ../local
+`,
+		mods: []gocmd.Module{
+			{Path: "example.com/main", Main: true},
+			{Path: "example.com/a", Version: "v1.0.0"},
+			{Path: "example.com/b", Version: "v1.1.0", Indirect: true},
+			{Path: "example.com/local", Version: "v0.0.0-00010101000000-000000000000", Replace: &gocmd.Module{Path: "../local"}},
+		},
+		want: `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/local v0.0.0-00010101000000-000000000000
+)
+
+require example.com/b v1.1.0 // indirect
+
+replace example.com/local => ../local
+`,
+		replacements: map[string]module.Version{
+			"example.com/local": {Path: "../local"},
+		},
+	},
+	{
+		name: "module",
+		gomod: `module example.com/main
+
+go 1.21
+
+require example.com/a v1.0.0
+
+replace example.com/a => example.com/fork v1.2.0
+`,
+		mods: []gocmd.Module{
+			{Path: "example.com/main", Main: true},
+			{Path: "example.com/a", Version: "v1.0.0", Replace: &gocmd.Module{Path: "example.com/fork", Version: "v1.2.0"}},
+			{Path: "example.com/b", Version: "v0.3.0", Indirect: true},
+		},
+		want: `module example.com/main
+
+go 1.21
+
+require example.com/a v1.0.0
+
+require example.com/b v0.3.0 // indirect
+
+replace example.com/a => example.com/fork v1.2.0
+`,
+		replacements: map[string]module.Version{
+			"example.com/a": {Path: "example.com/fork", Version: "v1.2.0"},
+		},
+		fetched: []string{"/example.com/fork/@v/v1.2.0.info"},
+	},
+	{
+		name: "block",
+		gomod: `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/local v0.0.0
+)
+
+replace (
+	example.com/a => example.com/fork v1.2.0
+	example.com/b v1.0.0 => example.com/b-fork v1.0.1
+	example.com/local => ./local
+)
+`,
+		mods: []gocmd.Module{
+			{Path: "example.com/main", Main: true},
+			{Path: "example.com/a", Version: "v1.0.0", Replace: &gocmd.Module{Path: "example.com/fork", Version: "v1.2.0"}},
+			{Path: "example.com/b", Version: "v1.0.0", Replace: &gocmd.Module{Path: "example.com/b-fork", Version: "v1.0.1"}},
+			{Path: "example.com/c", Version: "v1.4.0"},
+			{Path: "example.com/local", Version: "v0.0.0", Replace: &gocmd.Module{Path: "./local"}},
+		},
+		want: `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.4.0
+	example.com/local v0.0.0
+)
+
+replace (
+	example.com/a => example.com/fork v1.2.0
+	example.com/b v1.0.0 => example.com/b-fork v1.0.1
+	example.com/local => ./local
+)
+`,
+		replacements: map[string]module.Version{
+			"example.com/a":     {Path: "example.com/fork", Version: "v1.2.0"},
+			"example.com/b":     {Path: "example.com/b-fork", Version: "v1.0.1"},
+			"example.com/local": {Path: "./local"},
+		},
+		fetched: []string{
+			"/example.com/b-fork/@v/v1.0.1.info",
+			"/example.com/fork/@v/v1.2.0.info",
+		},
+	},
+}
+
+func TestPinRequiresReplacements(t *testing.T) {
+	for _, tt := range replaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := modfile.Parse("go.mod", []byte(tt.gomod), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replacements, _ := pinRequires(f, tt.mods, nil, nil, func(string) bool { return false })
+			got, err := f.Format()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("go.mod =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(replacements, tt.replacements) {
+				t.Errorf("replacements = %v, want %v", replacements, tt.replacements)
+			}
+		})
+	}
+}
+
+func TestValidateReplacements(t *testing.T) {
+	for _, tt := range replaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu      sync.Mutex
+				fetched []string
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				fetched = append(fetched, r.URL.Path)
+				mu.Unlock()
+				version := strings.TrimSuffix(r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], ".info")
+				w.Write([]byte(`{"Version":"` + version + `","Time":"2024-01-01T00:00:00Z"}`))
+			}))
+			defer srv.Close()
+			c, err := proxy.New(srv.URL, proxy.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validateReplacements(context.Background(), c, tt.replacements, 2, false); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(fetched)
+			if !reflect.DeepEqual(fetched, tt.fetched) {
+				t.Errorf("fetched %q, want %q", fetched, tt.fetched)
+			}
+		})
+	}
+}
+
+func TestValidateReplacementsMissing(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	c, err := proxy.New(srv.URL, proxy.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validateReplacements(context.Background(), c, map[string]module.Version{
+		"example.com/a": {Path: "example.com/fork", Version: "v1.2.0"},
+	}, 1, false)
+	if err == nil || !strings.Contains(err.Error(), "replacement example.com/fork@v1.2.0") {
+		t.Fatalf("validateReplacements = %v, want a replacement error", err)
+	}
+}
@@ -0,0 +1,145 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/tools"
+)
+
+// PlanPrune removes the requirements of the go.mod at file on modules that
+// provide no package imported by the packages or tests of the main module.
+// Modules providing a tool and modules matched by exclude are kept, as well
+// as every module still in the build list once the others are gone: pin
+// would add those right back. The go.sum entries of the module versions
+// that leave the build list are removed too. Result.Removed lists the
+// removed requirements.
+func PlanPrune(ctx context.Context, file string, exclude func(string) bool) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+	sum, err := gosum.Parse(res.SumFile, res.OldSum)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := tools.Find(orig)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for _, t := range ts {
+		if t.Module != "" {
+			keep[t.Module] = true
+		}
+	}
+	required := make(map[string]bool)
+	var candidates []string
+	for _, r := range orig.Requires() {
+		required[r.Path] = true
+		if keep[r.Path] || exclude != nil && exclude(r.Path) {
+			keep[r.Path] = true
+			continue
+		}
+		candidates = append(candidates, r.Path)
+	}
+	unneeded, err := gocmd.Unneeded(ctx, dir, candidates)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range candidates {
+		if !unneeded[p] {
+			keep[p] = true
+		}
+	}
+	before, err := gocmd.ListModules(ctx, dir, gocmd.ListOptions{GoWork: "off"})
+	if err != nil {
+		return nil, err
+	}
+
+	// Requirements that remain in the build list through the go.mod files of
+	// the kept modules are kept too, which can in turn keep more modules.
+	var after []gocmd.Module
+	for round := 0; ; round++ {
+		if round == maxRounds {
+			return nil, fmt.Errorf("%s: build list did not settle after %d rounds", file, maxRounds)
+		}
+		f, err := modfile.Parse(file, orig.Data, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range f.Require {
+			if !keep[r.Mod.Path] {
+				f.DropRequire(r.Mod.Path)
+			}
+		}
+		f.Cleanup()
+		if res.New, err = f.Format(); err != nil {
+			return nil, fmt.Errorf("formatting %s: %w", file, err)
+		}
+		scratch := &gosum.Sum{Lines: append([]gosum.Line(nil), sum.Lines...)}
+		if after, err = listAlternate(ctx, dir, res.New, scratch); err != nil {
+			return nil, err
+		}
+		grown := false
+		for _, m := range after {
+			if required[m.Path] && !keep[m.Path] && !m.Main {
+				keep[m.Path] = true
+				grown = true
+			}
+		}
+		if !grown {
+			break
+		}
+	}
+
+	for _, r := range orig.Requires() {
+		if !keep[r.Path] {
+			res.Removed = append(res.Removed, Change{Path: r.Path, Old: r.Version, Indirect: r.Indirect})
+		}
+	}
+	if len(res.Removed) == 0 {
+		res.New = orig.Data
+		return res, nil
+	}
+
+	// Versions of modules that were in the build list and are not anymore
+	// have no use for their hashes.
+	left := make(map[module.Version]bool)
+	remaining := make(map[string]bool)
+	for _, m := range after {
+		remaining[m.Path] = true
+	}
+	for _, m := range before {
+		if !m.Main && !remaining[m.Path] {
+			left[module.Version{Path: m.Path, Version: m.Version}] = true
+		}
+	}
+	pruned := &gosum.Sum{}
+	for _, l := range sum.Lines {
+		if !left[module.Version{Path: l.Path, Version: strings.TrimSuffix(l.Version, "/go.mod")}] {
+			pruned.Lines = append(pruned.Lines, l)
+		}
+	}
+	if len(pruned.Lines) != len(sum.Lines) {
+		res.NewSum = pruned.Format()
+	}
+	return res, nil
+}
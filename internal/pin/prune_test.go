@@ -0,0 +1,118 @@
+package pin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash-separated paths, under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// offlineGo runs the go command of the test without network access or
+// inherited flags.
+func offlineGo(t *testing.T) {
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOPROXY", "off")
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOWORK", "off")
+}
+
+// pruneModule is a main module requiring a module for each way of being
+// needed, or not, all replaced by directories so that the go command needs
+// no network.
+var pruneModule = map[string]string{
+	"go.mod": `module example.com/main
+
+go 1.24
+
+require (
+	example.com/excluded v0.0.0
+	example.com/testonly v0.0.0
+	example.com/tool v0.0.0
+	example.com/unused v0.0.0
+	example.com/used v0.0.0
+)
+
+tool example.com/tool
+
+replace (
+	example.com/excluded => ./deps/excluded
+	example.com/testonly => ./deps/testonly
+	example.com/tool => ./deps/tool
+	example.com/unused => ./deps/unused
+	example.com/used => ./deps/used
+)
+`,
+	"main.go":                   "package main\n\nimport _ \"example.com/used\"\n\nfunc main() {}\n",
+	"main_test.go":              "package main\n\nimport _ \"example.com/testonly\"\n",
+	"deps/used/go.mod":          "module example.com/used\n\ngo 1.21\n",
+	"deps/used/used.go":         "package used\n",
+	"deps/testonly/go.mod":      "module example.com/testonly\n\ngo 1.21\n",
+	"deps/testonly/testonly.go": "package testonly\n",
+	"deps/tool/go.mod":          "module example.com/tool\n\ngo 1.21\n",
+	"deps/tool/main.go":         "package main\n\nfunc main() {}\n",
+	"deps/unused/go.mod":        "module example.com/unused\n\ngo 1.21\n",
+	"deps/unused/unused.go":     "package unused\n",
+	"deps/excluded/go.mod":      "module example.com/excluded\n\ngo 1.21\n",
+	"deps/excluded/excluded.go": "package excluded\n",
+}
+
+func TestPlanPrune(t *testing.T) {
+	offlineGo(t)
+	dir := t.TempDir()
+	writeTree(t, dir, pruneModule)
+
+	res, err := PlanPrune(context.Background(), filepath.Join(dir, "go.mod"), func(path string) bool {
+		return path == "example.com/excluded"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{{Path: "example.com/unused", Old: "v0.0.0"}}
+	if !reflect.DeepEqual(res.Removed, want) {
+		t.Errorf("Removed = %+v, want %+v", res.Removed, want)
+	}
+	gomod := string(res.New)
+	for _, path := range []string{"example.com/excluded", "example.com/testonly", "example.com/tool", "example.com/used"} {
+		if !strings.Contains(gomod, "\t"+path+" v0.0.0\n") {
+			t.Errorf("requirement on %s was pruned:\n%s", path, gomod)
+		}
+	}
+	if strings.Contains(gomod, "example.com/unused v0.0.0\n") {
+		t.Errorf("requirement on example.com/unused was kept:\n%s", gomod)
+	}
+}
+
+func TestPlanPruneLoadFailure(t *testing.T) {
+	offlineGo(t)
+	dir := t.TempDir()
+	writeTree(t, dir, pruneModule)
+	// An import no module provides fails to load, which go mod why alone
+	// would not report.
+	writeTree(t, dir, map[string]string{
+		"broken.go": "package main\n\nimport _ \"example.com/missing\"\n",
+	})
+
+	res, err := PlanPrune(context.Background(), filepath.Join(dir, "go.mod"), nil)
+	if err == nil {
+		t.Fatalf("PlanPrune removed %+v, want an error", res.Removed)
+	}
+	if !strings.Contains(err.Error(), "loading the packages of the main module") {
+		t.Errorf("PlanPrune error = %v, want a load error", err)
+	}
+}
@@ -0,0 +1,144 @@
+package pin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/lockfile"
+)
+
+// PlanRestore rewrites the go.mod at file and the go.sum next to it to the
+// resolution recorded in l: every locked module is required at its locked
+// version, other requirements are dropped, and go.sum holds the locked
+// hashes. The replace directives of go.mod must already match the lock.
+//
+// The result is checked by listing the build list of the rewritten go.mod,
+// so a locked version that no longer resolves, a hash the module does not
+// match, or a lock that is not a consistent build list is an error.
+func PlanRestore(ctx context.Context, file string, l *lockfile.Lock) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	res := &Result{File: file, Old: orig.Data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sum, err := gosum.Parse(res.SumFile, res.OldSum)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := modfile.Parse(file, orig.Data, nil)
+	if err != nil {
+		return nil, err
+	}
+	indirect := make(map[string]bool, len(f.Require))
+	for _, r := range f.Require {
+		indirect[r.Mod.Path] = r.Indirect
+	}
+	var reqs []*modfile.Require
+	var problems []string
+	for _, e := range l.Entries {
+		if want, have := e.Replace, replacementOf(orig, e); !sameReplacement(want, have) {
+			problems = append(problems, fmt.Sprintf("%s@%s: go.mod replaces it by %s, the lock by %s", e.Path, e.Version, describe(have), describe(want)))
+			continue
+		}
+		ind, ok := indirect[e.Path]
+		reqs = append(reqs, &modfile.Require{Mod: module.Version{Path: e.Path, Version: e.Version}, Indirect: ind || !ok})
+		eff := e.Effective()
+		if e.Hash != "" {
+			sum.Set(gosum.Line{Path: eff.Path, Version: eff.Version, Hash: e.Hash})
+		}
+		if e.GoModHash != "" {
+			sum.Set(gosum.Line{Path: eff.Path, Version: eff.Version + "/go.mod", Hash: e.GoModHash})
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s: replace directives do not match the lock:\n\t%s", file, strings.Join(problems, "\n\t"))
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].Mod.Path < reqs[j].Mod.Path })
+	f.SetRequireSeparateIndirect(reqs)
+	f.Cleanup()
+	if res.New, err = f.Format(); err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", file, err)
+	}
+
+	mods, err := listAlternate(ctx, dir, res.New, sum)
+	if err != nil {
+		return nil, fmt.Errorf("restoring the lock: %w", err)
+	}
+	locked := make(map[string]string, len(l.Entries))
+	for _, e := range l.Entries {
+		locked[e.Path] = e.Version
+	}
+	for _, m := range mods {
+		if m.Main {
+			continue
+		}
+		switch v, ok := locked[m.Path]; {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s@%s is not locked", m.Path, m.Version))
+		case v != m.Version:
+			problems = append(problems, fmt.Sprintf("%s is locked at %s but resolves to %s", m.Path, v, m.Version))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("the lock is not a consistent build list:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+
+	res.NewSum = sum.Format()
+	if bytes.Equal(res.NewSum, gosumFormat(res.SumFile, res.OldSum)) {
+		res.NewSum = res.OldSum
+	}
+	res.Added, res.Changed = changes(orig.File, f)
+	return res, nil
+}
+
+// replacementOf returns the replacement go.mod applies to the locked module.
+func replacementOf(m *gomod.Module, e lockfile.Entry) *module.Version {
+	r := m.Replacement(module.Version{Path: e.Path, Version: e.Version})
+	if r == nil {
+		return nil
+	}
+	return &module.Version{Path: r.New.Path, Version: r.New.Version}
+}
+
+func sameReplacement(a, b *module.Version) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func describe(r *module.Version) string {
+	if r == nil {
+		return "nothing"
+	}
+	if r.Version == "" {
+		return r.Path
+	}
+	return r.String()
+}
+
+// gosumFormat returns data in the canonical go.sum formatting, so that a
+// go.sum with the same lines in a different order is not rewritten.
+func gosumFormat(name string, data []byte) []byte {
+	s, err := gosum.Parse(name, data)
+	if err != nil {
+		return nil
+	}
+	return s.Format()
+}
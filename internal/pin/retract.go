@@ -0,0 +1,71 @@
+package pin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// ErrRetracted is matched by the error Plan returns when it refuses to pin
+// retracted versions.
+var ErrRetracted = errors.New("retracted version")
+
+// RetractedError lists the retracted versions Plan refuses to pin.
+type RetractedError struct {
+	File string
+	// Retractions describe the retracted requirements, one per line.
+	Retractions []string
+}
+
+func (e *RetractedError) Error() string {
+	return fmt.Sprintf("%s: refusing to pin retracted versions (use --allow-retracted to override):\n\t%s", e.File, strings.Join(e.Retractions, "\n\t"))
+}
+
+func (e *RetractedError) Is(target error) bool { return target == ErrRetracted }
+
+// checkRetracted fails if any requirement of f that is neither replaced,
+// excluded nor a workspace member is pinned to a retracted version, with up
+// to concurrency lookups at a time.
+func checkRetracted(ctx context.Context, c *proxy.Client, f *modfile.File, ws *workspace, replacements map[string]module.Version, exclude func(string) bool, concurrency int, failFast bool) error {
+	var reqs []module.Version
+	for _, r := range f.Require {
+		if _, ok := replacements[r.Mod.Path]; ok || ws.local(r.Mod.Path) || exclude(r.Mod.Path) {
+			continue
+		}
+		reqs = append(reqs, r.Mod)
+	}
+	found, errs := workpool.Map(concurrency, failFast, reqs, func(mv module.Version) (*resolve.Retraction, error) {
+		rt, err := resolve.CheckRetracted(ctx, c, mv.Path, mv.Version)
+		return rt, failure.Module(mv.Path, mv.Version, err)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	var lines []string
+	for i, r := range found {
+		if r != nil {
+			lines = append(lines, describeRetraction(reqs[i], r))
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return &RetractedError{File: f.Syntax.Name, Retractions: lines}
+}
+
+func describeRetraction(mv module.Version, r *resolve.Retraction) string {
+	s := fmt.Sprintf("%s is retracted by %s", mv, r)
+	if r.Rationale != "" {
+		s += ": " + r.Rationale
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package pin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/toolchain"
+)
+
+// PlanToolchain sets the toolchain directive of the go.mod at file to name,
+// such as go1.22.3, leaving everything else as it is.
+func PlanToolchain(file, name string) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(filepath.Dir(file), "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+	if err := setToolchain(res, name); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// setToolchain sets the toolchain directive of res.New to name and records
+// the change in res.Toolchain. modfile places a new directive right after
+// the go directive, where the go command writes it too. The toolchain must
+// not be older than the go directive, which the go command rejects.
+func setToolchain(res *Result, name string) error {
+	v := toolchain.Version(name)
+	if v == "" {
+		return fmt.Errorf("invalid toolchain %q: expected a name such as go1.22.3", name)
+	}
+	f, err := modfile.Parse(res.File, res.New, nil)
+	if err != nil {
+		return err
+	}
+	if f.Go != nil && toolchain.Compare(v, f.Go.Version) < 0 {
+		return fmt.Errorf("%s: toolchain %s is older than the go directive %s", res.File, name, f.Go.Version)
+	}
+	old := ""
+	if f.Toolchain != nil {
+		old = f.Toolchain.Name
+	}
+	if old == name {
+		return nil
+	}
+	if err := f.AddToolchainStmt(name); err != nil {
+		return err
+	}
+	if res.New, err = f.Format(); err != nil {
+		return fmt.Errorf("formatting %s: %w", res.File, err)
+	}
+	res.Toolchain = &Change{Path: "toolchain", Old: old, New: name}
+	return nil
+}
@@ -0,0 +1,56 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/tools"
+)
+
+// requireTools returns the go.mod content of m with a requirement added for
+// every tool no required module provides, at the latest version of the
+// module containing it. Like the go command does for tools, the new
+// requirements are indirect; pinning then settles them like any other.
+func requireTools(ctx context.Context, m *gomod.Module, opts Options) ([]byte, error) {
+	ts, err := tools.Find(m)
+	if err != nil {
+		return nil, err
+	}
+	var f *modfile.File
+	added := make(map[string]bool)
+	for _, t := range ts {
+		if t.Provided() || opts.excluded(t.Path) {
+			continue
+		}
+		if opts.Proxy == nil {
+			return nil, fmt.Errorf("a module proxy is needed to resolve the module of the tool %s", t.Path)
+		}
+		mod, version, err := resolve.Package(ctx, opts.Proxy, t.Path)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s of %s: %w", t.Path, t.Source, err)
+		}
+		if added[mod] || opts.excluded(mod) {
+			continue
+		}
+		added[mod] = true
+		if f == nil {
+			if f, err = modfile.Parse(m.Filename, m.Data, nil); err != nil {
+				return nil, err
+			}
+		}
+		f.AddNewRequire(mod, version, true)
+	}
+	if f == nil {
+		return m.Data, nil
+	}
+	f.Cleanup()
+	data, err := f.Format()
+	if err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", m.Filename, err)
+	}
+	return data, nil
+}
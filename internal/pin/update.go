@@ -0,0 +1,266 @@
+package pin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/versions"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// UpdateOptions configures PlanUpdate.
+type UpdateOptions struct {
+	// Within is the largest step a module may take: Patch keeps the minor
+	// version, Minor keeps the major version and Major allows any newer
+	// version of the same module path.
+	Within versions.Delta
+	// ForbidMajor, if set, reports modules that are never updated past
+	// their current major version, whatever Within allows.
+	ForbidMajor func(path string) bool
+	Proxy       *proxy.Client
+	Concurrency int
+	// FailFast stops at the first module that fails.
+	FailFast bool
+	// Toolchain, if set, is the toolchain name the toolchain directive is
+	// set to after the updates.
+	Toolchain string
+	// RewriteImports makes PlanMajor rewrite the imports of the moved
+	// modules in the Go files of the module.
+	RewriteImports bool
+}
+
+// Candidate is a requirement with newer releases within the step allowed by
+// UpdateOptions.Within.
+type Candidate struct {
+	gomod.Require
+	// Newer are the newer releases, newest first.
+	Newer []string
+}
+
+// PlanUpdate moves the requirements on paths (all requirements if paths is
+// empty) to the newest release allowed by opts.Within. The upgrades are
+// applied with `go get` on a copy of go.mod, so that requirements raised by
+// minimal version selection are updated along with them. Result.Changed
+// lists every requirement whose version changed.
+func PlanUpdate(ctx context.Context, file string, paths []string, opts UpdateOptions) (*Result, error) {
+	cands, err := FindUpdates(ctx, file, paths, opts)
+	if err != nil {
+		return nil, err
+	}
+	var targets []module.Version
+	for _, c := range cands {
+		metrics.Add(metrics.ModulesResolved, 1)
+		slog.Info("selected version", "module", c.Path, "version", c.Newer[0], "reason", "newest "+string(versions.DeltaOf(c.Version, c.Newer[0]))+" update")
+		targets = append(targets, module.Version{Path: c.Path, Version: c.Newer[0]})
+	}
+	return PlanVersions(ctx, file, targets, opts)
+}
+
+// FindUpdates returns the requirements on paths (all requirements if paths
+// is empty) that have newer releases allowed by opts.Within, in file order.
+func FindUpdates(ctx context.Context, file string, paths []string, opts UpdateOptions) ([]Candidate, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := updateTargets(orig, paths)
+	if err != nil {
+		return nil, err
+	}
+	newer, errs := workpool.Map(opts.Concurrency, opts.FailFast, targets, func(r gomod.Require) ([]string, error) {
+		list, err := opts.Proxy.Versions(ctx, r.Path)
+		if err == nil {
+			list, err = resolve.WithoutExcluded(r.Path, list, orig.Excluded)
+		}
+		if err != nil {
+			return nil, failure.Module(r.Path, r.Version, err)
+		}
+		within := opts.Within
+		if within == versions.Major && opts.ForbidMajor != nil && opts.ForbidMajor(r.Path) {
+			slog.Debug("limited update", "module", r.Path, "within", versions.Minor, "reason", "major updates forbidden by config")
+			within = versions.Minor
+		}
+		return newerWithin(r.Path, r.Version, list, within), nil
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var cands []Candidate
+	for i, r := range targets {
+		if len(newer[i]) > 0 {
+			cands = append(cands, Candidate{Require: r, Newer: newer[i]})
+		}
+	}
+	return cands, nil
+}
+
+// PlanVersions moves the requirements of the go.mod at file to the module
+// versions in targets with `go get` on a copy of go.mod, like PlanUpdate,
+// and then applies opts.Toolchain.
+func PlanVersions(ctx context.Context, file string, targets []module.Version, opts UpdateOptions) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+	res := &Result{File: file, Old: orig.Data, New: orig.Data, SumFile: filepath.Join(dir, "go.sum")}
+	res.OldSum, err = os.ReadFile(res.SumFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	res.NewSum = res.OldSum
+
+	var queries []string
+	for _, mv := range targets {
+		queries = append(queries, mv.Path+"@"+mv.Version)
+	}
+	if len(queries) == 0 {
+		if err := updateToolchain(res, opts); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "pin-update-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	modFile := filepath.Join(tmp, "go.mod")
+	sumFile := filepath.Join(tmp, "go.sum")
+	if err := os.WriteFile(modFile, orig.Data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(sumFile, res.OldSum, 0o644); err != nil {
+		return nil, err
+	}
+	if err := gocmd.Get(ctx, dir, modFile, queries); err != nil {
+		return nil, err
+	}
+	if res.New, err = os.ReadFile(modFile); err != nil {
+		return nil, err
+	}
+	if res.NewSum, err = os.ReadFile(sumFile); err != nil {
+		return nil, err
+	}
+	updated, err := gomod.Parse(file, res.New)
+	if err != nil {
+		return nil, err
+	}
+	res.Added, res.Changed = changes(orig.File, updated.File)
+	if err := updateToolchain(res, opts); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// updateToolchain applies opts.Toolchain to res.
+func updateToolchain(res *Result, opts UpdateOptions) error {
+	if opts.Toolchain == "" {
+		return nil
+	}
+	return setToolchain(res, opts.Toolchain)
+}
+
+// updateTargets returns the requirements on paths, or all requirements when
+// paths is empty. Replaced requirements are never updated: the build uses the
+// replacement, and changing the required version could make a replace
+// directive for a specific version stop applying.
+func updateTargets(m *gomod.Module, paths []string) ([]gomod.Require, error) {
+	byPath := make(map[string]gomod.Require)
+	var all []gomod.Require
+	for _, r := range m.Requires() {
+		if r.Replace != nil {
+			continue
+		}
+		byPath[r.Path] = r
+		all = append(all, r)
+	}
+	if len(paths) == 0 {
+		return all, nil
+	}
+	var targets []gomod.Require
+	var missing []string
+	for _, p := range paths {
+		r, ok := byPath[p]
+		if !ok {
+			missing = append(missing, p)
+			continue
+		}
+		targets = append(targets, r)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%s: not required or replaced: %s", m.Filename, strings.Join(missing, ", "))
+	}
+	return targets, nil
+}
+
+// newerWithin returns the releases in list that are newer than current and
+// at most a step of kind within away, newest first.
+func newerWithin(path, current string, list []string, within versions.Delta) []string {
+	incompatible := strings.HasSuffix(current, "+incompatible")
+	var newer []string
+	for _, v := range versions.Releases(list, false) {
+		if strings.HasSuffix(v, "+incompatible") != incompatible {
+			continue
+		}
+		if semver.Compare(v, current) <= 0 {
+			break
+		}
+		if d := versions.DeltaOf(current, v); d.Rank() <= within.Rank() {
+			newer = append(newer, v)
+			continue
+		}
+		slog.Debug("rejected version", "module", path, "version", v, "reason", "exceeds --within "+string(within))
+	}
+	if len(newer) == 0 {
+		metrics.Add(metrics.ModulesResolved, 1)
+		slog.Info("selected version", "module", path, "version", current, "reason", "no newer release within "+string(within))
+	}
+	return newer
+}
+
+// PlanCommits pins the modules of commits to the versions of the commits
+// their Version fields name, by full or abbreviated hash: the
+// pseudo-versions `go get path@hash` would pick, resolved through
+// opts.Proxy. The requirements are then moved with `go get` like
+// PlanVersions does.
+func PlanCommits(ctx context.Context, file string, commits []module.Version, opts UpdateOptions) (*Result, error) {
+	orig, err := gomod.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	found, errs := workpool.Map(opts.Concurrency, opts.FailFast, commits, func(mv module.Version) (string, error) {
+		v, err := resolve.Commit(ctx, opts.Proxy, mv.Path, mv.Version)
+		return v, failure.Module(mv.Path, mv.Version, err)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	targets := make([]module.Version, len(commits))
+	for i, mv := range commits {
+		if orig.Excluded(mv.Path, found[i]) {
+			return nil, fmt.Errorf("%s@%s: resolved to %s, which is excluded by %s", mv.Path, mv.Version, found[i], file)
+		}
+		metrics.Add(metrics.ModulesResolved, 1)
+		slog.Info("selected version", "module", mv.Path, "version", found[i], "reason", "commit "+mv.Version)
+		targets[i] = module.Version{Path: mv.Path, Version: found[i]}
+	}
+	return PlanVersions(ctx, file, targets, opts)
+}
@@ -0,0 +1,96 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gowork"
+)
+
+// WorkspaceMode selects how go.work files are taken into account.
+type WorkspaceMode int
+
+const (
+	// WorkspaceAuto pins to the workspace build list when the module is a
+	// member of the go.work in effect, and per module otherwise.
+	WorkspaceAuto WorkspaceMode = iota
+	// WorkspaceOn requires a go.work that uses the module.
+	WorkspaceOn
+	// WorkspaceOff ignores go.work files.
+	WorkspaceOff
+)
+
+// workspace holds the unified build list of a go.work file. A nil
+// *workspace stands for a module pinned on its own.
+type workspace struct {
+	file     string
+	versions map[string]string
+	members  map[string]bool
+}
+
+// loadWorkspace resolves the workspace build list for the module in dir
+// according to mode, or returns nil when the module is pinned on its own.
+func loadWorkspace(ctx context.Context, dir string, mode WorkspaceMode) (*workspace, error) {
+	if mode == WorkspaceOff {
+		return nil, nil
+	}
+	name, err := gowork.Find(dir)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if mode == WorkspaceOn {
+			return nil, fmt.Errorf("%s: no go.work file found", dir)
+		}
+		return nil, nil
+	}
+	w, err := gowork.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	member, err := w.Uses(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		if mode == WorkspaceOn {
+			return nil, fmt.Errorf("%s: module is not used by %s", dir, name)
+		}
+		return nil, nil
+	}
+
+	mods, err := gocmd.ListModules(ctx, filepath.Dir(name), gocmd.ListOptions{GoWork: name})
+	if err != nil {
+		return nil, err
+	}
+	ws := &workspace{file: name, versions: make(map[string]string), members: make(map[string]bool)}
+	for _, m := range mods {
+		if m.Main {
+			ws.members[m.Path] = true
+		} else if m.Version != "" {
+			ws.versions[m.Path] = m.Version
+		}
+	}
+	return ws, nil
+}
+
+// local reports whether path is a workspace member. Members are resolved from
+// disk through use directives, so they must never be pinned to a registry
+// version.
+func (ws *workspace) local(path string) bool {
+	return ws != nil && ws.members[path]
+}
+
+// version returns the workspace version of path, falling back to the version
+// selected for the module on its own.
+func (ws *workspace) version(path, fallback string) string {
+	if ws == nil {
+		return fallback
+	}
+	if v, ok := ws.versions[path]; ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,122 @@
+// Package progress shows how far a run over many items has come, on one
+// status line of a terminal that the rest of the output goes around.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// width is the longest status line drawn, so that it does not wrap on a
+// standard terminal.
+const width = 79
+
+// Display serializes the output of concurrent jobs and, if it is enabled,
+// keeps a status line with the number of items done and the item most
+// recently started at the bottom of its writer. Every write through a
+// Display is done at once, so that the output of one job never cuts into
+// the line of another.
+type Display struct {
+	mu      sync.Mutex
+	w       io.Writer
+	enabled bool
+	total   int
+	done    int
+	running []string
+	shown   bool
+}
+
+// New returns a Display for total items drawing its status line on w if
+// enabled is set, which should only be the case if w is a terminal.
+func New(w io.Writer, total int, enabled bool) *Display {
+	return &Display{w: w, total: total, enabled: enabled}
+}
+
+// Start records that the item name has started.
+func (d *Display) Start(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.running = append(d.running, name)
+	d.draw()
+}
+
+// Done records that the item name has finished.
+func (d *Display) Done(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, n := range d.running {
+		if n == name {
+			d.running = append(d.running[:i], d.running[i+1:]...)
+			break
+		}
+	}
+	d.done++
+	d.draw()
+}
+
+// Print writes p to w in one piece, with the status line out of the way.
+// w may be the writer of the Display or any other writer displayed on the
+// same terminal, such as standard output.
+func (d *Display) Print(w io.Writer, p []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	_, err := w.Write(p)
+	d.draw()
+	return err
+}
+
+// Writer returns a writer printing to w through Print, such as for the
+// logs of the jobs.
+func (d *Display) Writer(w io.Writer) io.Writer {
+	return writer{d: d, w: w}
+}
+
+type writer struct {
+	d *Display
+	w io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if err := w.d.Print(w.w, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close removes the status line for good.
+func (d *Display) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	d.enabled = false
+}
+
+func (d *Display) clear() {
+	if d.shown {
+		fmt.Fprint(d.w, "\r\033[K")
+		d.shown = false
+	}
+}
+
+func (d *Display) draw() {
+	if !d.enabled {
+		return
+	}
+	d.clear()
+	line := fmt.Sprintf("[%d/%d]", d.done, d.total)
+	if n := len(d.running); n > 0 {
+		line += " " + d.running[n-1]
+		if n > 1 {
+			line += fmt.Sprintf(" (+%d more)", n-1)
+		}
+	}
+	if utf8.RuneCountInString(line) > width {
+		r := []rune(line)
+		line = string(r[:width-3]) + "..."
+	}
+	fmt.Fprint(d.w, line)
+	d.shown = true
+}
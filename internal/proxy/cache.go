@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/fsutil"
+)
+
+// Cache stores proxy responses on disk.
+type Cache struct {
+	Dir string
+	// TTL is how long mutable responses, such as version lists, stay valid.
+	// Responses for a specific tagged version never change and are kept
+	// until the cache is cleaned.
+	TTL time.Duration
+}
+
+// DefaultCacheDir returns the cache directory below the user cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pin-go-dependencies"), nil
+}
+
+// Clean removes the cache directory and returns the number of bytes freed.
+func (c *Cache) Clean() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return size, os.RemoveAll(c.Dir)
+}
+
+// file returns the cache file of an escaped module path and endpoint served
+// by the proxy at base.
+func (c *Cache) file(base, escPath, endpoint string) string {
+	host := base
+	if u, err := url.Parse(base); err == nil && u.Host != "" {
+		host = u.Host + u.Path
+	}
+	host = strings.NewReplacer("/", "_", ":", "_").Replace(host)
+	return filepath.Join(c.Dir, host, filepath.FromSlash(escPath), filepath.FromSlash(endpoint))
+}
+
+// load returns the cached response in name, if present and still valid for
+// endpoint.
+func (c *Cache) load(name, endpoint string) ([]byte, bool) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, false
+	}
+	if !immutable(endpoint) && time.Since(info.ModTime()) > c.TTL {
+		return nil, false
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// store saves a response. Failures are ignored: the cache is an optimization
+// only. The file is written atomically so readers never see partial data.
+func (c *Cache) store(name string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return
+	}
+	fsutil.WriteFileAtomic(name, data, 0o644)
+}
+
+// immutable reports whether the response for endpoint can never change:
+// the .info, .mod and .zip files of a specific semantic version.
+func immutable(endpoint string) bool {
+	rest, ok := strings.CutPrefix(endpoint, "@v/")
+	if !ok {
+		return false
+	}
+	for _, ext := range []string{".info", ".mod", ".zip"} {
+		if v, ok := strings.CutSuffix(rest, ext); ok {
+			return semver.IsValid(v)
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// entry is an element of a GOPROXY list.
+type entry struct {
+	src source
+	// fallBackOnError is set if the entry is followed by "|": the next
+	// entry is then tried after any error, not only after "not found".
+	fallBackOnError bool
+}
+
+// notFoundError is an error that matches ErrNotFound.
+type notFoundError string
+
+func (e notFoundError) Error() string { return string(e) }
+
+func (e notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// off is the "off" entry of a GOPROXY list, which fails every request.
+type off struct{}
+
+// fetch fails with an error that, like in the go command, counts as "not
+// found" when choosing the error to report.
+func (off) fetch(_ context.Context, path, endpoint string) ([]byte, error) {
+	return nil, notFoundError(path + ": module lookup disabled by GOPROXY=off")
+}
+
+func (off) cacheKey() string { return "off" }
+
+func (off) String() string { return "off" }
+
+// fileSource is a module proxy in a local directory, given by a file://
+// URL, with the layout of the proxy protocol.
+type fileSource struct {
+	dir string
+	// cache is set for the download cache of the module cache.
+	cache bool
+}
+
+func (s *fileSource) fetch(_ context.Context, path, endpoint string) ([]byte, error) {
+	ep, err := module.EscapePath(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Join(s.dir, filepath.FromSlash(ep), filepath.FromSlash(endpoint))
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		if s.cache {
+			return nil, notFoundError(fmt.Sprintf("%s: not in the module cache (checked %s); download it with `go mod download %s` before working offline", path, name, path))
+		}
+		return nil, notFoundError(fmt.Sprintf("%s: %s does not exist", path, name))
+	}
+	return data, err
+}
+
+// cacheKey returns "": local files are not cached.
+func (s *fileSource) cacheKey() string { return "" }
+
+func (s *fileSource) String() string {
+	if s.cache {
+		return "module cache " + s.dir
+	}
+	return "proxy file://" + filepath.ToSlash(s.dir)
+}
+
+// parseList parses a GOPROXY list the way the go command does: entries are
+// separated by "," or "|", "direct" resolves modules from their
+// repositories with d, and "off" fails. Both end the list. Entries without
+// a scheme are https URLs. An empty list is the go command's default.
+func parseList(goproxy string, hc *http.Client, netrc *Netrc, d *direct) ([]entry, error) {
+	if goproxy == "" {
+		goproxy = "https://proxy.golang.org,direct"
+	}
+	var list []entry
+	for rest := goproxy; rest != ""; {
+		var e entry
+		name := rest
+		rest = ""
+		if i := strings.IndexAny(name, ",|"); i >= 0 {
+			e.fallBackOnError = name[i] == '|'
+			name, rest = name[:i], name[i+1:]
+		}
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "off":
+			list = append(list, entry{src: off{}})
+			return list, nil
+		case "direct":
+			list = append(list, entry{src: d})
+			return list, nil
+		}
+		if strings.ContainsAny(name, ".:/") && !strings.Contains(name, ":/") && !filepath.IsAbs(name) && !strings.HasPrefix(name, "/") {
+			name = "https://" + name
+		}
+		if !strings.Contains(name, "://") {
+			return nil, fmt.Errorf("invalid GOPROXY entry %q: expected a URL, direct or off", name)
+		}
+		if dir, ok := strings.CutPrefix(name, "file://"); ok {
+			e.src = &fileSource{dir: filepath.FromSlash(dir)}
+		} else {
+			e.src = &httpSource{base: strings.TrimSuffix(name, "/"), http: hc, netrc: netrc}
+		}
+		list = append(list, e)
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("GOPROXY=%q contains no entries", goproxy)
+	}
+	return list, nil
+}
+
+// getChain fetches the endpoint of the module path from the proxy list. An
+// entry followed by "," falls back to the next one only if it does not
+// know the module or version, an entry followed by "|" after any error.
+// If every entry fails, the most helpful error is returned: that of
+// "direct", else the last proxy error other than "not found", else the
+// last "not found".
+func (c *Client) getChain(ctx context.Context, path, endpoint string) ([]byte, error) {
+	const (
+		notFoundRank = iota
+		proxyRank
+		directRank
+	)
+	var best error
+	bestRank := notFoundRank
+	for _, e := range c.chain {
+		data, err := c.getFrom(ctx, e.src, path, endpoint)
+		if err == nil {
+			return data, nil
+		}
+		isNotFound := errors.Is(err, ErrNotFound)
+		_, isDirect := e.src.(*direct)
+		switch {
+		case isDirect:
+			best, bestRank = err, directRank
+		case bestRank <= proxyRank && !isNotFound:
+			best, bestRank = err, proxyRank
+		case bestRank == notFoundRank:
+			best = err
+		}
+		if !e.fallBackOnError && !isNotFound {
+			break
+		}
+	}
+	return nil, best
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pin-go-dependencies/internal/httpx"
+)
+
+const goMod = "module example.com/m\n"
+
+// proxyServer is a module proxy answering every request with a fixed
+// status, or never answering if status is 0, and counting the requests.
+type proxyServer struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newProxyServer(t *testing.T, status int) *proxyServer {
+	s := &proxyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		switch status {
+		case 0:
+			<-r.Context().Done()
+		case http.StatusOK:
+			w.Write([]byte(goMod))
+		default:
+			http.Error(w, http.StatusText(status), status)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// quickRequests makes requests time out after d and never retries them,
+// for the duration of the test.
+func quickRequests(t *testing.T, d time.Duration) {
+	timeout, retries := httpx.Timeout, httpx.Retries
+	httpx.Timeout, httpx.Retries = d, 0
+	t.Cleanup(func() { httpx.Timeout, httpx.Retries = timeout, retries })
+}
+
+func TestChainFallback(t *testing.T) {
+	quickRequests(t, 100*time.Millisecond)
+	for _, tt := range []struct {
+		name string
+		// first and second are the statuses of the two proxies, sep the
+		// separator between them.
+		first, second int
+		sep           string
+		// wantSecond is set if the second proxy is asked; wantErr checks
+		// the error, nil if the lookup succeeds.
+		wantSecond bool
+		wantErr    func(error) bool
+	}{
+		{name: "404 falls through", first: 404, second: 200, sep: ",", wantSecond: true},
+		{name: "410 falls through", first: 410, second: 200, sep: ",", wantSecond: true},
+		{name: "500 stops", first: 500, second: 200, sep: ",", wantErr: status(500)},
+		{name: "403 stops", first: 403, second: 200, sep: ",", wantErr: status(403)},
+		{name: "timeout stops", first: 0, second: 200, sep: ",", wantErr: timedOut},
+		{name: "pipe falls through on 500", first: 500, second: 200, sep: "|", wantSecond: true},
+		{name: "pipe falls through on timeout", first: 0, second: 200, sep: "|", wantSecond: true},
+		{name: "pipe falls through on 404", first: 404, second: 200, sep: "|", wantSecond: true},
+		{name: "both not found", first: 404, second: 410, sep: ",", wantSecond: true, wantErr: isNotFound},
+		{name: "server error beats not found", first: 500, second: 404, sep: "|", wantSecond: true, wantErr: status(500)},
+		{name: "last not found wins", first: 410, second: 404, sep: ",", wantSecond: true, wantErr: status(404)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second := newProxyServer(t, tt.first), newProxyServer(t, tt.second)
+			c, err := New(first.URL+tt.sep+second.URL, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := c.GoMod(context.Background(), "example.com/m", "v1.0.0")
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("GoMod: %v", err)
+			case tt.wantErr == nil && string(data) != goMod:
+				t.Errorf("GoMod = %q, want %q", data, goMod)
+			case tt.wantErr != nil && !tt.wantErr(err):
+				t.Errorf("GoMod error = %v", err)
+			}
+			if n := first.hits.Load(); n != 1 {
+				t.Errorf("first proxy got %d requests, want 1", n)
+			}
+			want := int32(0)
+			if tt.wantSecond {
+				want = 1
+			}
+			if n := second.hits.Load(); n != want {
+				t.Errorf("second proxy got %d requests, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestChainOff(t *testing.T) {
+	first := newProxyServer(t, 404)
+	c, err := New(first.URL+",off", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GoMod(context.Background(), "example.com/m", "v1.0.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GoMod error = %v, want not found", err)
+	}
+}
+
+func TestParseListSeparators(t *testing.T) {
+	list, err := parseList("https://a.example, https://b.example|https://c.example,direct,https://ignored.example", nil, nil, &direct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range list {
+		s := e.src.String()
+		if e.fallBackOnError {
+			s += " |"
+		}
+		got = append(got, s)
+	}
+	want := []string{"proxy https://a.example", "proxy https://b.example |", "proxy https://c.example", "direct"}
+	if len(got) != len(want) {
+		t.Fatalf("parseList = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func status(code int) func(error) bool {
+	return func(err error) bool {
+		var he *HTTPError
+		return errors.As(err, &he) && he.StatusCode == code
+	}
+}
+
+func isNotFound(err error) bool { return errors.Is(err, ErrNotFound) }
+
+func timedOut(err error) bool {
+	var te *httpx.TimeoutError
+	return errors.As(err, &te)
+}
@@ -0,0 +1,263 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// direct resolves modules from their version control repositories, like
+// GOPROXY=direct does. Version lists are the tags reported by
+// `git ls-remote`; the metadata and go.mod file of a version are fetched by
+// the go command. Both authenticate through git's credential helpers and
+// the netrc file, and never prompt.
+type direct struct {
+	http  *http.Client
+	netrc *Netrc
+
+	mu    sync.Mutex
+	repos map[string]*repo
+}
+
+// repo is the repository holding a module.
+type repo struct {
+	// root is the module path prefix of the repository root.
+	root string
+	url  string
+}
+
+func (d *direct) cacheKey() string { return "direct" }
+
+func (d *direct) String() string { return "direct" }
+
+func (d *direct) fetch(ctx context.Context, path, endpoint string) ([]byte, error) {
+	if endpoint == "@v/list" {
+		return d.list(ctx, path)
+	}
+	if endpoint == "@latest" {
+		return d.query(ctx, path, "latest", ".info")
+	}
+	if rest, ok := strings.CutPrefix(endpoint, "@v/"); ok {
+		for _, ext := range []string{".info", ".mod"} {
+			if ev, ok := strings.CutSuffix(rest, ext); ok {
+				v, err := module.UnescapeVersion(ev)
+				if err != nil {
+					return nil, err
+				}
+				return d.query(ctx, path, v, ext)
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: direct resolution does not serve %s", path, endpoint)
+}
+
+// list returns the versions of path tagged in its repository, one per line.
+// Tags of a module in a subdirectory carry the directory as prefix, and only
+// the tags of the major version of path are listed.
+func (d *direct) list(ctx context.Context, path string) ([]byte, error) {
+	r, err := d.repo(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	// The listing is a request to the repository like the HTTP ones, so
+	// it is bound to the timeout of a request too.
+	listCtx := ctx
+	if httpx.Timeout > 0 {
+		var cancel context.CancelFunc
+		listCtx, cancel = context.WithTimeout(ctx, httpx.Timeout)
+		defer cancel()
+	}
+	release, err := workpool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(listCtx, "git", "ls-remote", "--tags", "--refs", r.url)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	start := time.Now()
+	out, err := cmd.Output()
+	release()
+	slog.Debug("git ls-remote", "url", r.url, "duration", time.Since(start), "error", err)
+	if err != nil {
+		if listCtx.Err() != nil && ctx.Err() == nil {
+			return nil, fmt.Errorf("git ls-remote %s: %w", r.url, &httpx.TimeoutError{After: httpx.Timeout})
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("git ls-remote %s: %w", r.url, err)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git ls-remote %s: %s", r.url, msg)
+	}
+
+	prefix, pathMajor, _ := module.SplitPathVersion(path)
+	tagPrefix := strings.TrimPrefix(strings.TrimPrefix(prefix, r.root), "/")
+	if tagPrefix != "" {
+		tagPrefix += "/"
+	}
+	var buf bytes.Buffer
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		v, ok := strings.CutPrefix(f[1], "refs/tags/"+tagPrefix)
+		if !ok || semver.Canonical(v) != v || module.CheckPathMajor(v, pathMajor) != nil {
+			continue
+		}
+		buf.WriteString(v + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// query resolves path@q with the go command and returns the .info or .mod
+// file of the resulting version.
+func (d *direct) query(ctx context.Context, path, q, ext string) ([]byte, error) {
+	m, err := gocmd.Query(ctx, path, q, []string{"GOPROXY=direct", "GIT_TERMINAL_PROMPT=0"})
+	if err != nil {
+		if notFound(err) {
+			return nil, notFoundError(err.Error())
+		}
+		return nil, err
+	}
+	if ext == ".mod" {
+		if m.GoMod == "" {
+			return nil, fmt.Errorf("go list did not report a go.mod for %s@%s", path, q)
+		}
+		return os.ReadFile(m.GoMod)
+	}
+	info := Info{Version: m.Version}
+	if m.Time != nil {
+		info.Time = *m.Time
+	}
+	return json.Marshal(info)
+}
+
+// notFound reports whether the go command failed because the module or
+// version does not exist.
+func notFound(err error) bool {
+	msg := err.Error()
+	for _, s := range []string{"no matching versions", "unknown revision", "not found", "does not contain"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// repo returns the repository of the module path. Hosts with a fixed
+// layout are mapped directly, a path element ending in .git marks the root
+// explicitly, and other paths are looked up through the go-import meta tag
+// served for ?go-get=1, like the go command does.
+func (d *direct) repo(ctx context.Context, path string) (*repo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.repos == nil {
+		d.repos = make(map[string]*repo)
+	}
+	if r, ok := d.repos[path]; ok {
+		return r, nil
+	}
+	r, err := d.findRepo(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	d.repos[path] = r
+	return r, nil
+}
+
+func (d *direct) findRepo(ctx context.Context, path string) (*repo, error) {
+	elems := strings.Split(path, "/")
+	for i, e := range elems {
+		if i > 0 && strings.HasSuffix(e, ".git") {
+			root := strings.Join(elems[:i+1], "/")
+			return &repo{root: root, url: "https://" + root}, nil
+		}
+	}
+	switch elems[0] {
+	case "github.com", "bitbucket.org":
+		if len(elems) < 3 {
+			return nil, fmt.Errorf("%s: invalid %s module path", path, elems[0])
+		}
+		root := strings.Join(elems[:3], "/")
+		return &repo{root: root, url: "https://" + root}, nil
+	}
+	return d.discover(ctx, path)
+}
+
+var (
+	metaTag   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaName  = regexp.MustCompile(`(?is)\sname\s*=\s*["']go-import["']`)
+	metaValue = regexp.MustCompile(`(?is)\scontent\s*=\s*["']([^"']*)["']`)
+)
+
+// discover looks up the repository of path through its go-import meta tag.
+func (d *direct) discover(ctx context.Context, path string) (*repo, error) {
+	u := "https://" + path + "?go-get=1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.netrc.authorize(req)
+	release, err := workpool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{URL: u, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	for _, tag := range metaTag.FindAll(data, -1) {
+		if !metaName.Match(tag) {
+			continue
+		}
+		m := metaValue.FindSubmatch(tag)
+		if m == nil {
+			continue
+		}
+		f := strings.Fields(string(m[1]))
+		if len(f) != 3 || (path != f[0] && !strings.HasPrefix(path, f[0]+"/")) {
+			continue
+		}
+		switch f[1] {
+		case "git":
+			return &repo{root: f[0], url: f[2]}, nil
+		case "mod":
+			return nil, fmt.Errorf("%s is served by the module proxy %s, not a repository", f[0], f[2])
+		default:
+			return nil, fmt.Errorf("%s: %s repositories are not supported, only git", f[0], f[1])
+		}
+	}
+	return nil, errors.New(u + ": no go-import meta tag for " + path)
+}
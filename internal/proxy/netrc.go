@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// netrcLine is the login of a machine in a netrc file. An empty machine is
+// the default entry.
+type netrcLine struct {
+	machine  string
+	login    string
+	password string
+}
+
+// Netrc holds the credentials of a netrc file, which the go command also
+// uses to authenticate to module proxies and go-get lookups.
+type Netrc struct {
+	lines []netrcLine
+}
+
+// LoadNetrc reads the netrc file named by $NETRC, or else ~/.netrc
+// (~/_netrc on Windows). A missing file yields no credentials.
+func LoadNetrc() (*Netrc, error) {
+	name := os.Getenv("NETRC")
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &Netrc{}, nil
+		}
+		base := ".netrc"
+		if runtime.GOOS == "windows" {
+			base = "_netrc"
+		}
+		name = filepath.Join(home, base)
+	}
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return &Netrc{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseNetrc(string(data)), nil
+}
+
+// parseNetrc parses netrc content. macdef definitions are skipped, and the
+// first entry of a machine wins.
+func parseNetrc(data string) *Netrc {
+	n := &Netrc{}
+	var l *netrcLine
+	inMacro := false
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			if line == "" {
+				inMacro = false
+			}
+			continue
+		}
+		f := strings.Fields(line)
+		for i := 0; i < len(f); i++ {
+			switch f[i] {
+			case "machine", "default":
+				if l != nil {
+					n.lines = append(n.lines, *l)
+				}
+				l = &netrcLine{}
+				if f[i] == "machine" && i+1 < len(f) {
+					i++
+					l.machine = f[i]
+				}
+			case "login", "password":
+				if l == nil || i+1 >= len(f) {
+					continue
+				}
+				i++
+				if f[i-1] == "login" {
+					l.login = f[i]
+				} else {
+					l.password = f[i]
+				}
+			case "macdef":
+				inMacro = true
+				i = len(f)
+			}
+		}
+	}
+	if l != nil {
+		n.lines = append(n.lines, *l)
+	}
+	return n
+}
+
+// authorize adds the credentials for the host of req, if any, unless the
+// request already carries some.
+func (n *Netrc) authorize(req *http.Request) {
+	if n == nil || req.URL.User != nil {
+		return
+	}
+	host := req.URL.Hostname()
+	var def *netrcLine
+	for i, l := range n.lines {
+		if l.machine == host {
+			req.SetBasicAuth(l.login, l.password)
+			return
+		}
+		if l.machine == "" && def == nil {
+			def = &n.lines[i]
+		}
+	}
+	if def != nil {
+		req.SetBasicAuth(def.login, def.password)
+	}
+}
@@ -0,0 +1,440 @@
+// Package proxy is a client for the module proxy protocol described in
+// `go help goproxy`.
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/httpx"
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// ErrNotFound is returned when the proxy does not know a module or version.
+var ErrNotFound = errors.New("not found")
+
+// HTTPError is returned for unsuccessful proxy responses. It matches
+// ErrNotFound for 404 and 410 responses, which the proxy protocol uses for
+// unknown modules and versions.
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return e.URL + ": " + e.Status
+}
+
+func (e *HTTPError) Is(target error) bool {
+	return target == ErrNotFound && (e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone)
+}
+
+// Unavailable reports whether err means that the proxy does not serve the
+// module at all. Besides 404 and 410, proxies answer probes for paths they
+// refuse to fetch with other client errors such as 403.
+func Unavailable(err error) bool {
+	var he *HTTPError
+	return errors.Is(err, ErrNotFound) || errors.As(err, &he) && he.StatusCode >= 400 && he.StatusCode < 500
+}
+
+// Info is the metadata served for a module version.
+type Info struct {
+	Version string
+	Time    time.Time
+}
+
+// Client talks to the module proxies of a GOPROXY list, falling back from
+// one entry to the next like the go command does. Private modules, matched
+// by the GONOPROXY patterns, are never requested from a public proxy: they
+// are resolved through the other proxies of the list, and else directly
+// from their version control repositories.
+//
+// The requests of a lookup stop when its ctx is done, and so do the go
+// commands and git processes that resolve modules directly. Concurrent
+// lookups of the same endpoint share one fetch.
+type Client struct {
+	chain   []entry
+	private []source
+	// privatePatterns holds the GONOPROXY patterns.
+	privatePatterns string
+	cache           *Cache
+	flights         flights
+}
+
+// flights holds the fetches of get in flight, so that callers asking for one
+// endpoint at the same time share one fetch. A fetch is forgotten once it is
+// done: later calls go to the cache, whose TTL decides whether the response
+// is still good, or to the network, and errors are never reused.
+type flights struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is a fetch through get in flight, done once done is closed.
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Options configures a Client.
+type Options struct {
+	// Cache, if set, stores responses on disk.
+	Cache *Cache
+	// Private holds the patterns of private modules, with the syntax of
+	// GONOPROXY.
+	Private string
+}
+
+// publicProxies are the hosts of proxies that only serve public modules.
+var publicProxies = map[string]bool{
+	"proxy.golang.org":    true,
+	"proxy.golang.com.cn": true,
+	"goproxy.cn":          true,
+	"goproxy.io":          true,
+}
+
+// Public reports whether the URL belongs to a proxy that only serves public
+// modules. Such proxies answer 403 for modules they refuse to serve, not to
+// ask for credentials.
+func Public(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && publicProxies[u.Hostname()]
+}
+
+// New returns a client for the proxy list goproxy, which has the syntax of
+// the GOPROXY environment variable. Credentials for the proxies come from
+// the netrc file.
+func New(goproxy string, opts Options) (*Client, error) {
+	if strings.TrimSpace(goproxy) == "off" {
+		return nil, fmt.Errorf("module proxy disabled by GOPROXY=%q", goproxy)
+	}
+	netrc, err := LoadNetrc()
+	if err != nil {
+		return nil, err
+	}
+	hc := httpx.NewClient(loggingTransport{http.DefaultTransport})
+	d := &direct{http: hc, netrc: netrc}
+	c := &Client{privatePatterns: opts.Private, cache: opts.Cache}
+	if c.chain, err = parseList(goproxy, hc, netrc, d); err != nil {
+		return nil, err
+	}
+	for _, e := range c.chain {
+		if h, ok := e.src.(*httpSource); ok {
+			if u, err := url.Parse(h.base); err == nil && !publicProxies[u.Hostname()] {
+				c.private = append(c.private, h)
+			}
+		}
+	}
+	c.private = append(c.private, d)
+	return c, nil
+}
+
+// loggingTransport logs every request at debug level, with the status and
+// duration of its response.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	metrics.Add(metrics.ProxyRequests, 1)
+	metrics.Time(metrics.PhaseProxy, time.Since(start))
+	if err != nil {
+		slog.Debug("http request", "method", req.Method, "url", req.URL.Redacted(), "duration", time.Since(start), "error", err)
+		return nil, err
+	}
+	slog.Debug("http request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "duration", time.Since(start))
+	resp.Body = countingBody{resp.Body}
+	return resp, nil
+}
+
+// countingBody counts the bytes read from a response body as downloaded.
+type countingBody struct {
+	io.ReadCloser
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	metrics.Add(metrics.BytesDownloaded, int64(n))
+	return n, err
+}
+
+// NewOffline returns a client that never accesses the network: it serves
+// the .info, .mod and version list files found in dir, the download cache
+// of the module cache ($GOMODCACHE/cache/download). Only versions that were
+// downloaded before are known.
+func NewOffline(dir string) *Client {
+	return &Client{chain: []entry{{src: &fileSource{dir: dir, cache: true}}}}
+}
+
+// FromEnv returns a client for the proxy configured in the go environment,
+// or for goproxy instead of GOPROXY if it is not empty. Unless opts names
+// them, private modules are those matched by GONOPROXY, which defaults to
+// GOPRIVATE.
+func FromEnv(goproxy string, opts Options) (*Client, error) {
+	env, err := gocmd.Env("GOPROXY", "GONOPROXY", "GOPRIVATE")
+	if err != nil {
+		return nil, err
+	}
+	if goproxy == "" {
+		goproxy = env["GOPROXY"]
+	}
+	if opts.Private == "" {
+		opts.Private = env["GONOPROXY"]
+		if opts.Private == "" {
+			opts.Private = env["GOPRIVATE"]
+		}
+	}
+	return New(goproxy, opts)
+}
+
+// Versions returns the tagged versions of the module path, in the order the
+// proxy lists them.
+func (c *Client) Versions(ctx context.Context, path string) ([]string, error) {
+	data, err := c.get(ctx, path, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if v := strings.TrimSpace(line); v != "" {
+			versions = append(versions, v)
+		}
+	}
+	return versions, nil
+}
+
+// Info returns the metadata of path at version.
+func (c *Client) Info(ctx context.Context, path, version string) (*Info, error) {
+	ev, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.get(ctx, path, "@v/"+ev+".info")
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("%s@%s: decoding info: %w", path, version, err)
+	}
+	return &info, nil
+}
+
+// GoMod returns the go.mod file of path at version.
+func (c *Client) GoMod(ctx context.Context, path, version string) ([]byte, error) {
+	ev, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return c.get(ctx, path, "@v/"+ev+".mod")
+}
+
+// Zip returns the zip archive of path at version. Archives are never
+// cached, as they can be large.
+func (c *Client) Zip(ctx context.Context, path, version string) ([]byte, error) {
+	ev, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return c.get(ctx, path, "@v/"+ev+".zip")
+}
+
+// Latest returns the metadata of the version the proxy considers the latest
+// of path, which is also served for modules without any tagged version.
+func (c *Client) Latest(ctx context.Context, path string) (*Info, error) {
+	data, err := c.get(ctx, path, "@latest")
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("%s@latest: decoding info: %w", path, err)
+	}
+	return &info, nil
+}
+
+// get fetches the endpoint of the module path, e.g. "@v/list", from the
+// cache or the proxy list. Concurrent calls for the same endpoint share one
+// fetch; a caller whose fetch was shared with one that got cancelled
+// fetches again. Zip archives are fetched by every caller, as they are too
+// large to hand around.
+func (c *Client) get(ctx context.Context, path, endpoint string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(endpoint, ".zip") {
+		return c.fetch(ctx, path, endpoint)
+	}
+	key := path + "/" + endpoint
+	fl := &c.flights
+	fl.mu.Lock()
+	if cl, ok := fl.calls[key]; ok {
+		fl.mu.Unlock()
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if cl.err != nil && ctx.Err() == nil && (errors.Is(cl.err, context.Canceled) || errors.Is(cl.err, context.DeadlineExceeded)) {
+			return c.get(ctx, path, endpoint)
+		}
+		return cl.data, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	if fl.calls == nil {
+		fl.calls = make(map[string]*call)
+	}
+	fl.calls[key] = cl
+	fl.mu.Unlock()
+
+	cl.data, cl.err = c.fetch(ctx, path, endpoint)
+	fl.mu.Lock()
+	delete(fl.calls, key)
+	fl.mu.Unlock()
+	close(cl.done)
+	return cl.data, cl.err
+}
+
+// fetch fetches the endpoint of the module path from the cache or the proxy
+// list. Private modules are tried against every private source in turn.
+func (c *Client) fetch(ctx context.Context, path, endpoint string) ([]byte, error) {
+	if !module.MatchPrefixPatterns(c.privatePatterns, path) {
+		return c.getChain(ctx, path, endpoint)
+	}
+	perr := &PrivateError{Path: path}
+	for _, src := range c.private {
+		data, err := c.getFrom(ctx, src, path, endpoint)
+		if err == nil {
+			return data, nil
+		}
+		perr.Attempts = append(perr.Attempts, Attempt{Source: src.String(), Err: err})
+	}
+	return nil, perr
+}
+
+// getFrom fetches the endpoint of the module path from the cache or src.
+func (c *Client) getFrom(ctx context.Context, src source, path, endpoint string) ([]byte, error) {
+	if c.cache == nil || src.cacheKey() == "" || strings.HasSuffix(endpoint, ".zip") {
+		return src.fetch(ctx, path, endpoint)
+	}
+	ep, err := module.EscapePath(path)
+	if err != nil {
+		return nil, err
+	}
+	name := c.cache.file(src.cacheKey(), ep, endpoint)
+	if data, ok := c.cache.load(name, endpoint); ok {
+		slog.Debug("proxy cache hit", "source", src.String(), "module", path, "endpoint", endpoint)
+		metrics.Add(metrics.CacheHits, 1)
+		return data, nil
+	}
+	metrics.Add(metrics.CacheMisses, 1)
+	data, err := src.fetch(ctx, path, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.store(name, data)
+	return data, nil
+}
+
+// PrivateError is returned when a private module cannot be resolved by
+// any source. It matches ErrNotFound if one of the sources does not know
+// the module or version.
+type PrivateError struct {
+	Path     string
+	Attempts []Attempt
+}
+
+// Attempt is the failure of one source to resolve a private module.
+type Attempt struct {
+	// Source describes the source, such as "proxy https://goproxy.corp"
+	// or "direct".
+	Source string
+	Err    error
+}
+
+func (e *PrivateError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: cannot resolve private module, tried:", e.Path)
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "\n\t%s: %v", a.Source, a.Err)
+	}
+	return b.String()
+}
+
+func (e *PrivateError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a.Err
+	}
+	return errs
+}
+
+// source serves the endpoints of the proxy protocol.
+type source interface {
+	// fetch returns the endpoint of the module path, stopping when ctx is
+	// done.
+	fetch(ctx context.Context, path, endpoint string) ([]byte, error)
+	// cacheKey identifies the source in the cache; "" disables caching.
+	cacheKey() string
+	String() string
+}
+
+// httpSource is a module proxy reached over HTTP.
+type httpSource struct {
+	base  string
+	http  *http.Client
+	netrc *Netrc
+}
+
+func (s *httpSource) cacheKey() string { return s.base }
+
+func (s *httpSource) String() string { return "proxy " + s.base }
+
+// fetch requests the endpoint of the module path from the proxy.
+func (s *httpSource) fetch(ctx context.Context, path, endpoint string) ([]byte, error) {
+	ep, err := module.EscapePath(path)
+	if err != nil {
+		return nil, err
+	}
+	u := s.base + "/" + ep + "/" + endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.netrc.authorize(req)
+	release, err := workpool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{URL: u, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return data, nil
+}
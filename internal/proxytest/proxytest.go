@@ -0,0 +1,266 @@
+// Package proxytest runs module proxies for tests. A Server serves a fixed
+// set of module versions over the module proxy protocol, to the proxy
+// client of the tool as well as to the go command, and records the
+// requests it receives.
+package proxytest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/gosum"
+)
+
+// Module is a module version served by a Server.
+type Module struct {
+	Path    string
+	Version string
+	// Time is the commit time of the version, the zero time by default.
+	Time time.Time
+	// GoMod is the go.mod file of the version, "module Path" if empty.
+	GoMod string
+	// Files are the other files of the module, by slash-separated name.
+	Files map[string]string
+	// Commit, if set, is the hash of the commit of the version: its .info
+	// is then also served for the hash and its prefixes of at least seven
+	// digits, as proxies resolve revisions.
+	Commit string
+}
+
+func (m Module) goMod() string {
+	if m.GoMod == "" {
+		return "module " + m.Path + "\n"
+	}
+	return m.GoMod
+}
+
+func (m Module) zip() ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{"go.mod": m.goMod()}
+	for name, data := range m.Files {
+		files[name] = data
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w, err := zw.Create(m.Path + "@" + m.Version + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Server is a module proxy serving a fixed set of module versions.
+type Server struct {
+	*httptest.Server
+
+	mods []Module
+
+	mu       sync.Mutex
+	requests []string
+	hang     bool
+	delay    time.Duration
+	// inFlight is the number of requests being served, peak its maximum.
+	inFlight int
+	peak     int
+}
+
+// New starts a proxy serving mods, which is closed at the end of the test.
+func New(t testing.TB, mods ...Module) *Server {
+	s := &Server{mods: mods}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the paths requested so far, such as
+// "/example.com/m/@v/list", in the order they arrived.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Hang makes the server hold every later request without answering until
+// its client goes away.
+func (s *Server) Hang() {
+	s.mu.Lock()
+	s.hang = true
+	s.mu.Unlock()
+}
+
+// Delay makes the server wait d before answering every later request, so
+// that concurrent requests overlap.
+func (s *Server) Delay(d time.Duration) {
+	s.mu.Lock()
+	s.delay = d
+	s.mu.Unlock()
+}
+
+// Peak returns the largest number of requests the server handled at the
+// same time since it started or since ResetPeak.
+func (s *Server) Peak() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peak
+}
+
+// ResetPeak starts counting the peak of Peak anew.
+func (s *Server) ResetPeak() {
+	s.mu.Lock()
+	s.peak = s.inFlight
+	s.mu.Unlock()
+}
+
+// UseWithGo makes the go commands run by the test download from s, into a
+// module cache of their own, without consulting a checksum database.
+func (s *Server) UseWithGo(t *testing.T) {
+	t.Setenv("GOPROXY", s.URL)
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOFLAGS", "-modcacherw")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GOTOOLCHAIN", "local")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+}
+
+// GoSum returns the go.sum lines of every module version of s, with the
+// hashes of their zips and go.mod files.
+func (s *Server) GoSum(t testing.TB) []byte {
+	sum := &gosum.Sum{}
+	for _, m := range s.mods {
+		data, err := m.zip()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := gosum.HashZip(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum.Add(gosum.Line{Path: m.Path, Version: m.Version, Hash: h})
+		if h, err = gosum.HashGoMod([]byte(m.goMod())); err != nil {
+			t.Fatal(err)
+		}
+		sum.Add(gosum.Line{Path: m.Path, Version: m.Version + "/go.mod", Hash: h})
+	}
+	return sum.Format()
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.Path)
+	hang, delay := s.hang, s.delay
+	s.inFlight++
+	if s.inFlight > s.peak {
+		s.peak = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	if hang {
+		<-r.Context().Done()
+		return
+	}
+	time.Sleep(delay)
+
+	escPath, endpoint, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/@")
+	path, err := module.UnescapePath(escPath)
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	var versions []Module
+	for _, m := range s.mods {
+		if m.Path == path {
+			versions = append(versions, m)
+		}
+	}
+
+	switch endpoint {
+	case "v/list":
+		for _, m := range versions {
+			if !module.IsPseudoVersion(m.Version) {
+				fmt.Fprintln(w, m.Version)
+			}
+		}
+		return
+	case "latest":
+		var latest *Module
+		for i, m := range versions {
+			if latest == nil || semver.Compare(m.Version, latest.Version) > 0 {
+				latest = &versions[i]
+			}
+		}
+		if latest != nil {
+			writeInfo(w, *latest)
+			return
+		}
+	}
+
+	file, ok := strings.CutPrefix(endpoint, "v/")
+	ext := file[strings.LastIndexByte(file, '.')+1:]
+	rev, err := module.UnescapeVersion(strings.TrimSuffix(file, "."+ext))
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, m := range versions {
+		switch {
+		case m.Version == rev:
+		case ext == "info" && m.Commit != "" && len(rev) >= 7 && strings.HasPrefix(m.Commit, rev):
+		default:
+			continue
+		}
+		switch ext {
+		case "info":
+			writeInfo(w, m)
+		case "mod":
+			w.Write([]byte(m.goMod()))
+		case "zip":
+			data, err := m.zip()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+	http.Error(w, "not found: "+path+"@"+rev, http.StatusNotFound)
+}
+
+func writeInfo(w http.ResponseWriter, m Module) {
+	json.NewEncoder(w).Encode(struct {
+		Version string
+		Time    time.Time
+	}{m.Version, m.Time})
+}
@@ -0,0 +1,242 @@
+// Package render formats the results of a command through a user-supplied
+// text/template, as an alternative to the built-in text and JSON output. The
+// templates receive the values the JSON output encodes and have a few
+// helpers for module versions.
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/versions"
+)
+
+// Funcs are the helpers available to templates, in addition to the
+// built-in functions of text/template.
+var Funcs = template.FuncMap{
+	"semverDelta": semverDelta,
+	"shortSHA":    shortSHA,
+	"padRight":    padRight,
+}
+
+// funcDocs documents Funcs for Describe.
+var funcDocs = []string{
+	`semverDelta FROM TO  kind of update from FROM to TO: patch, minor, major, or "" if TO is not newer`,
+	`shortSHA VERSION     first 7 hex digits of the commit of a pseudo-version or commit hash, VERSION otherwise`,
+	`padRight N S         S padded with spaces to N characters, for aligned columns`,
+}
+
+func semverDelta(from, to string) string {
+	return string(versions.DeltaOf(from, to))
+}
+
+var hexRE = regexp.MustCompile(`^[0-9a-f]{7,}$`)
+
+func shortSHA(s string) string {
+	if module.IsPseudoVersion(s) {
+		if rev, err := module.PseudoVersionRev(s); err == nil {
+			s = rev
+		}
+	}
+	if hexRE.MatchString(s) {
+		return s[:7]
+	}
+	return s
+}
+
+func padRight(n int, s string) string {
+	if k := utf8.RuneCountInString(s); k < n {
+		return s + strings.Repeat(" ", n-k)
+	}
+	return s
+}
+
+// Template is a parsed template.
+type Template struct {
+	t *template.Template
+}
+
+// Parse parses text as a template named name, such as the file it was read
+// from.
+func Parse(name, text string) (*Template, error) {
+	t, err := template.New(name).Funcs(Funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{t: t}, nil
+}
+
+// Execute renders data through t to w. Nothing is written if rendering
+// fails. An unknown field is reported with the fields of the value it was
+// looked up on, rather than with the terse message of text/template.
+func (t *Template) Execute(w io.Writer, data any) error {
+	var buf bytes.Buffer
+	if err := t.t.Execute(&buf, data); err != nil {
+		return explain(err, reflect.TypeOf(data))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var fieldErrRE = regexp.MustCompile(`^(.*): can't evaluate field (\w+) in type (\S+)$`)
+
+// explain rewrites an unknown field error of text/template, whose message
+// names the field and the type it was looked up on, into one listing the
+// fields of that type. root is the type of the data of the template, from
+// which the types are found.
+func explain(err error, root reflect.Type) error {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return err
+	}
+	m := fieldErrRE.FindStringSubmatch(execErr.Err.Error())
+	if m == nil {
+		return err
+	}
+	at, field, typeName := m[1], m[2], m[3]
+	types := make(map[string]reflect.Type)
+	collect(root, types)
+	typ, ok := types[typeName]
+	if !ok {
+		return err
+	}
+	name := displayName(typ)
+	if s := structOf(typ); s != nil {
+		return fmt.Errorf("%s: %s has no field %s; its fields are %s", at, name, field, strings.Join(fields(s), ", "))
+	}
+	if e := structOf(elem(typ)); e != nil {
+		return fmt.Errorf("%s: %s is a list and has no field %s; use range to reach its elements, whose fields are %s", at, name, field, strings.Join(fields(e), ", "))
+	}
+	return fmt.Errorf("%s: %s has no fields, so .%s cannot be evaluated", at, name, field)
+}
+
+// collect adds t and the types reachable through its fields and elements to
+// types, by their name in the messages of text/template.
+func collect(t reflect.Type, types map[string]reflect.Type) {
+	if t == nil {
+		return
+	}
+	if _, ok := types[t.String()]; ok {
+		return
+	}
+	types[t.String()] = t
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		collect(t.Elem(), types)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() {
+				collect(f.Type, types)
+			}
+		}
+	}
+}
+
+// structOf returns the struct type t is or points to, or nil.
+func structOf(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// elem returns the element type of a slice or array type, or nil.
+func elem(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return t.Elem()
+	}
+	return nil
+}
+
+// fields returns the names of the exported fields of the struct type t.
+func fields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+var qualifierRE = regexp.MustCompile(`\w+\.`)
+
+// displayName returns the name of t without package qualifiers, such as
+// []listEntry for []main.listEntry.
+func displayName(t reflect.Type) string {
+	return qualifierRE.ReplaceAllString(t.String(), "")
+}
+
+// Describe writes the data model of templates receiving values of the type
+// of v to w: an example object showing every field with its type, generated
+// from the type itself, followed by the helpers and the example template.
+func Describe(w io.Writer, v any, example string) error {
+	var buf bytes.Buffer
+	buf.WriteString("The template receives a value of this shape; fields are reached as in {{.Field}}:\n\n")
+	describe(&buf, reflect.TypeOf(v), "", make(map[reflect.Type]bool))
+	buf.WriteString("\n\nHelpers, in addition to those of text/template:\n\n")
+	for _, d := range funcDocs {
+		fmt.Fprintf(&buf, "  %s\n", d)
+	}
+	fmt.Fprintf(&buf, "\nExample:\n\n  %s\n", example)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// describe writes the shape of t at the given indentation. seen holds the
+// struct types being described, so that recursive types end.
+func describe(buf *bytes.Buffer, t reflect.Type, indent string, seen map[reflect.Type]bool) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		if structOf(t) == nil {
+			fmt.Fprintf(buf, "%s (or nil)", displayName(t.Elem()))
+			return
+		}
+		describe(buf, t.Elem(), indent, seen)
+	case reflect.Slice, reflect.Array:
+		buf.WriteString("[")
+		describe(buf, t.Elem(), indent, seen)
+		buf.WriteString(", ...]")
+	case reflect.Map:
+		fmt.Fprintf(buf, "map[%s]", displayName(t.Key()))
+		describe(buf, t.Elem(), indent, seen)
+	case reflect.Struct:
+		if seen[t] {
+			buf.WriteString(displayName(t))
+			return
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		buf.WriteString("{\n")
+		width := 0
+		for _, name := range fields(t) {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fmt.Fprintf(buf, "%s  %s ", indent, padRight(width, f.Name))
+			describe(buf, f.Type, indent+"  ", seen)
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "%s}", indent)
+	default:
+		buf.WriteString(t.Kind().String())
+	}
+}
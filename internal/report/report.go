@@ -0,0 +1,114 @@
+// Package report prints the results of the subcommands, either as text for
+// humans or, with --output json, as a single JSON document per run with a
+// common envelope. It also defines the exit codes of the tool.
+package report
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Exit codes of the tool. They are part of its interface.
+const (
+	// ExitOK means the command succeeded.
+	ExitOK = 0
+	// ExitViolations means the command ran and found a problem: a policy
+	// violation, an available update or vulnerability beyond --fail-on,
+	// changes pending in a dry run, or any failure not covered below.
+	ExitViolations = 1
+	// ExitUsage means invalid flags or arguments.
+	ExitUsage = 2
+	// ExitNetwork means a module proxy, repository or database could not
+	// be reached, or did not resolve a module or version, or the run
+	// exceeded --timeout.
+	ExitNetwork = 3
+	// ExitInterrupted means the run was cancelled by SIGINT or SIGTERM,
+	// following the shell convention of 128 plus the signal number of
+	// SIGINT.
+	ExitInterrupted = 130
+)
+
+// Envelope is the document printed in JSON mode.
+type Envelope struct {
+	// Command is the subcommand that ran, such as "check" or "cache clean".
+	Command string `json:"command"`
+	// Success is set if the exit code is ExitOK.
+	Success bool `json:"success"`
+	// Results is the output of the command, or null if it failed before
+	// producing any.
+	Results any     `json:"results"`
+	Errors  []Error `json:"errors"`
+	// Stats holds the statistics of the run with --stats.
+	Stats any `json:"stats,omitempty"`
+}
+
+// Error is an error of the run in the envelope. A run that continues past
+// the modules it fails for has one per module.
+type Error struct {
+	Message string `json:"message"`
+	// Module and Version are set if the error is attributed to a module;
+	// Version is empty if it was not about a specific version.
+	Module  string `json:"module,omitempty"`
+	Version string `json:"version,omitempty"`
+	// Class is the kind of failure, such as "not-found" or "auth".
+	Class string `json:"class"`
+}
+
+// Reporter receives the output of a command. In text mode, results are
+// written to Out as they arrive; in JSON mode they are kept for the
+// envelope, so that Out only ever receives one JSON document.
+type Reporter struct {
+	Out  io.Writer
+	Err  io.Writer
+	json bool
+
+	results any
+	stats   any
+}
+
+// New returns a Reporter writing results to out and progress messages to
+// errw, in JSON mode if json is set.
+func New(out, errw io.Writer, json bool) *Reporter {
+	return &Reporter{Out: out, Err: errw, json: json}
+}
+
+// JSON reports whether r is in JSON mode.
+func (r *Reporter) JSON() bool { return r.json }
+
+// Result reports the result v of the command. In text mode, text writes it
+// to Out; in JSON mode, v becomes the results of the envelope, replacing
+// any earlier one.
+func (r *Reporter) Result(v any, text func(w io.Writer) error) error {
+	if r.json {
+		r.results = v
+		return nil
+	}
+	return text(r.Out)
+}
+
+// Text returns the writer for text output that has no JSON equivalent,
+// such as streamed progress of a long run: Out in text mode, and a writer
+// discarding everything in JSON mode.
+func (r *Reporter) Text() io.Writer {
+	if r.json {
+		return io.Discard
+	}
+	return r.Out
+}
+
+// SetStats sets the statistics of the run, which the envelope includes.
+func (r *Reporter) SetStats(v any) { r.stats = v }
+
+// Finish writes the envelope of command in JSON mode, given the exit code
+// of the run and its errors. It does nothing in text mode.
+func (r *Reporter) Finish(command string, code int, errs []Error) error {
+	if !r.json {
+		return nil
+	}
+	if errs == nil {
+		errs = []Error{}
+	}
+	enc := json.NewEncoder(r.Out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Envelope{Command: command, Success: code == ExitOK, Results: r.results, Errors: errs, Stats: r.stats})
+}
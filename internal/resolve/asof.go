@@ -0,0 +1,132 @@
+// Package resolve selects module versions using module proxy metadata.
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/versions"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// AsOfOptions configures AsOf.
+type AsOfOptions struct {
+	// Before is the exclusive upper bound for the publication time.
+	Before time.Time
+	// IncludePrerelease allows pre-release versions to be selected.
+	IncludePrerelease bool
+	// AllowRetracted allows retracted versions to be selected.
+	AllowRetracted bool
+	// Excluded, if set, reports the versions excluded by the exclude
+	// directives of the main module, which are never selected.
+	Excluded func(path, version string) bool
+	// Concurrency bounds the number of modules looked up at the same time.
+	// Zero selects workpool.DefaultSize.
+	Concurrency int
+	// FailFast stops the lookups at the first module that fails.
+	FailFast bool
+}
+
+// AsOf returns, for each module in current (mapping module paths to their
+// currently selected versions), the newest tagged version published before
+// opts.Before. Modules without any tagged release keep their current
+// pseudo-version if it predates the cutoff. Every other module without a
+// suitable version contributes an error; the versions found for the other
+// modules are still returned.
+func AsOf(ctx context.Context, c *proxy.Client, current map[string]string, opts AsOfOptions) (map[string]string, error) {
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	found, errs := workpool.Map(opts.Concurrency, opts.FailFast, paths, func(path string) (string, error) {
+		v, err := asOf(ctx, c, path, current[path], opts)
+		return v, failure.Module(path, "", err)
+	})
+	selected := make(map[string]string, len(paths))
+	for i, path := range paths {
+		if errs[i] == nil {
+			selected[path] = found[i]
+		}
+	}
+	return selected, errors.Join(errs...)
+}
+
+func asOf(ctx context.Context, c *proxy.Client, path, current string, opts AsOfOptions) (string, error) {
+	list, err := c.Versions(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	candidates := versions.Releases(list, opts.IncludePrerelease)
+	if !opts.IncludePrerelease {
+		for _, v := range versions.Releases(list, true) {
+			if semver.Prerelease(v) != "" {
+				rejected(path, v, "prerelease")
+			}
+		}
+	}
+	if candidates, err = WithoutExcluded(path, candidates, opts.Excluded); err != nil {
+		return "", err
+	}
+	if len(candidates) == 0 && module.IsPseudoVersion(current) {
+		if t, err := module.PseudoVersionTime(current); err == nil && t.Before(opts.Before) {
+			selected(path, current, "no tagged version, current pseudo-version predates the cutoff")
+			return current, nil
+		}
+	}
+	var retractions []Retraction
+	if !opts.AllowRetracted && len(candidates) > 0 {
+		if retractions, err = Retractions(ctx, c, path); err != nil {
+			return "", err
+		}
+	}
+	for _, v := range candidates {
+		if r := Retracted(retractions, v); r != nil {
+			rejected(path, v, "retracted", "rationale", r.Rationale)
+			continue
+		}
+		info, err := c.Info(ctx, path, v)
+		if err != nil {
+			return "", err
+		}
+		if info.Time.Before(opts.Before) {
+			selected(path, v, "newest release before the cutoff", "published", info.Time)
+			return v, nil
+		}
+		rejected(path, v, "after cutoff", "published", info.Time)
+	}
+	return "", fmt.Errorf("no release published before %s", opts.Before.UTC().Format(time.RFC3339))
+}
+
+// WithoutExcluded returns the versions of path in list that excluded does
+// not report, in the same order. It fails if it reports all of them, since
+// nothing is left to select.
+func WithoutExcluded(path string, list []string, excluded func(path, version string) bool) ([]string, error) {
+	if excluded == nil || len(list) == 0 {
+		return list, nil
+	}
+	var kept []string
+	for _, v := range list {
+		if excluded(path, v) {
+			rejected(path, v, "excluded by go.mod")
+			continue
+		}
+		kept = append(kept, v)
+	}
+	if len(kept) == 0 {
+		if len(list) == 1 {
+			return nil, fmt.Errorf("the only available version, %s, is excluded by go.mod", list[0])
+		}
+		return nil, fmt.Errorf("all %d available versions, %s to %s, are excluded by go.mod", len(list), list[len(list)-1], list[0])
+	}
+	return kept, nil
+}
@@ -0,0 +1,54 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/proxytest"
+)
+
+func TestAsOfBoundsInFlightRequests(t *testing.T) {
+	// Every module has a release before the cutoff and one after, so each
+	// lookup makes several requests.
+	var mods []proxytest.Module
+	current := make(map[string]string)
+	for i := 0; i < 30; i++ {
+		path := fmt.Sprintf("example.com/m%d", i)
+		mods = append(mods,
+			proxytest.Module{Path: path, Version: "v1.0.0", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			proxytest.Module{Path: path, Version: "v1.1.0", Time: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		)
+		current[path] = "v1.1.0"
+	}
+	for _, size := range []int{1, 3, 8} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			s := proxytest.New(t, mods...)
+			s.Delay(5 * time.Millisecond)
+			c, err := proxy.New(s.URL, proxy.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := AsOf(context.Background(), c, current, AsOfOptions{
+				Before:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				Concurrency: size,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path := range current {
+				if got[path] != "v1.0.0" {
+					t.Errorf("AsOf selected %s@%s, want v1.0.0", path, got[path])
+				}
+			}
+			if peak := s.Peak(); peak > size {
+				t.Errorf("peak of %d requests in flight, want at most %d", peak, size)
+			} else if size > 1 && peak < 2 {
+				t.Errorf("peak of %d requests in flight, want them to overlap", peak)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/proxy"
+)
+
+// commitHash matches full and abbreviated commit hashes. Like the go
+// command, at least seven hex digits are needed to tell them from other
+// revisions.
+var commitHash = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// IsCommit reports whether rev looks like a commit hash rather than a
+// branch or tag name.
+func IsCommit(rev string) bool {
+	return commitHash.MatchString(rev)
+}
+
+// Commit returns the version of path at the commit rev, a full or
+// abbreviated hash, as resolved by the proxy: the pseudo-version the go
+// command derives from the nearest tagged ancestor, or the tagged version if
+// the commit is a release. Private modules are resolved from their
+// repositories through the direct entry of the proxy list, like `go get`
+// does. Branch and tag names are rejected, since the commit they name moves.
+func Commit(ctx context.Context, c *proxy.Client, path, rev string) (string, error) {
+	if !IsCommit(rev) {
+		return "", errors.New("not a commit hash; pass the commit to pin (at least 7 hex digits), not a branch or tag name")
+	}
+	info, err := c.Info(ctx, path, rev)
+	if err != nil {
+		return "", err
+	}
+	if !module.IsPseudoVersion(info.Version) {
+		return info.Version, nil
+	}
+	got, err := module.PseudoVersionRev(info.Version)
+	if err != nil {
+		return "", err
+	}
+	// The pseudo-version carries the first 12 digits of the hash.
+	if !strings.HasPrefix(got, rev) && !strings.HasPrefix(rev, got) {
+		return "", fmt.Errorf("proxy resolved the commit to %s of another commit", info.Version)
+	}
+	return info.Version, nil
+}
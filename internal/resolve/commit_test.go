@@ -0,0 +1,120 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/proxytest"
+)
+
+var commitTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// The commits of the fake repositories.
+const (
+	untagged   = "abcdef0123456789abcdef0123456789abcdef01"
+	afterTag   = "0123456789abcdef0123456789abcdef01234567"
+	afterPre   = "fedcba9876543210fedcba9876543210fedcba98"
+	taggedAt   = "1111111111111111111111111111111111111111"
+	afterMajor = "2222222222222222222222222222222222222222"
+	other      = "3333333333333333333333333333333333333333"
+)
+
+func commitProxy(t *testing.T) *proxy.Client {
+	s := proxytest.New(t,
+		// A module without tags.
+		proxytest.Module{Path: "example.com/new", Version: "v0.0.0-20240102030405-abcdef012345", Time: commitTime, Commit: untagged},
+		// A module with a release and a prerelease, and commits after
+		// each of them.
+		proxytest.Module{Path: "example.com/m", Version: "v1.2.3", Commit: taggedAt},
+		proxytest.Module{Path: "example.com/m", Version: "v1.2.4-0.20240102030405-0123456789ab", Time: commitTime, Commit: afterTag},
+		proxytest.Module{Path: "example.com/m", Version: "v1.3.0-rc.1"},
+		proxytest.Module{Path: "example.com/m", Version: "v1.3.0-rc.1.0.20240102030405-fedcba987654", Time: commitTime, Commit: afterPre},
+		// A major version without tags of its own.
+		proxytest.Module{Path: "example.com/m/v2", Version: "v2.0.0-20240102030405-222222222222", Time: commitTime, Commit: afterMajor},
+		// A proxy resolving a commit to the pseudo-version of another.
+		proxytest.Module{Path: "example.com/bad", Version: "v0.0.0-20240102030405-333333333333", Time: commitTime, Commit: untagged},
+	)
+	c, err := proxy.New(s.URL, proxy.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCommit(t *testing.T) {
+	c := commitProxy(t)
+	for _, tt := range []struct {
+		name, path, rev string
+		// want is the version go get would write, base the tag it is
+		// derived from.
+		want, base string
+	}{
+		{"no tag", "example.com/new", untagged, "v0.0.0-20240102030405-abcdef012345", ""},
+		{"no tag short hash", "example.com/new", untagged[:7], "v0.0.0-20240102030405-abcdef012345", ""},
+		{"no tag 12 digits", "example.com/new", untagged[:12], "v0.0.0-20240102030405-abcdef012345", ""},
+		{"after release", "example.com/m", afterTag, "v1.2.4-0.20240102030405-0123456789ab", "v1.2.3"},
+		{"after release short hash", "example.com/m", afterTag[:8], "v1.2.4-0.20240102030405-0123456789ab", "v1.2.3"},
+		{"after prerelease", "example.com/m", afterPre[:10], "v1.3.0-rc.1.0.20240102030405-fedcba987654", "v1.3.0-rc.1"},
+		{"major without tags", "example.com/m/v2", afterMajor[:7], "v2.0.0-20240102030405-222222222222", ""},
+		{"tagged commit", "example.com/m", taggedAt[:7], "v1.2.3", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Commit(context.Background(), c, tt.path, tt.rev)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Commit(%s, %s) = %s, want %s", tt.path, tt.rev, got, tt.want)
+			}
+			if !module.IsPseudoVersion(got) {
+				return
+			}
+			if base, err := module.PseudoVersionBase(got); err != nil || base != tt.base {
+				t.Errorf("base of %s = %q, %v, want %q", got, base, err, tt.base)
+			}
+			rev, _ := module.PseudoVersionRev(got)
+			if !strings.HasPrefix(tt.rev, rev) && !strings.HasPrefix(rev, tt.rev) {
+				t.Errorf("%s does not name commit %s", got, tt.rev)
+			}
+			// The go command derives pseudo-versions the same way.
+			if want := module.PseudoVersion(semver.Major(got), tt.base, commitTime, rev); got != want {
+				t.Errorf("Commit(%s, %s) = %s, but the go command derives %s", tt.path, tt.rev, got, want)
+			}
+		})
+	}
+}
+
+func TestCommitRejected(t *testing.T) {
+	c := commitProxy(t)
+	for _, tt := range []struct {
+		name, path, rev string
+		want            func(error) bool
+	}{
+		{"branch", "example.com/m", "main", contains("not a commit hash")},
+		{"tag", "example.com/m", "v1.2.3", contains("not a commit hash")},
+		{"too short", "example.com/m", afterTag[:6], contains("not a commit hash")},
+		{"unknown commit", "example.com/m", other[:7], func(err error) bool { return errors.Is(err, proxy.ErrNotFound) }},
+		{"other commit", "example.com/bad", untagged[:7], contains("of another commit")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Commit(context.Background(), c, tt.path, tt.rev)
+			if err == nil {
+				t.Fatalf("Commit(%s, %s) = %s, want an error", tt.path, tt.rev, v)
+			}
+			if !tt.want(err) {
+				t.Errorf("Commit(%s, %s) error = %v", tt.path, tt.rev, err)
+			}
+		})
+	}
+}
+
+func contains(s string) func(error) bool {
+	return func(err error) bool { return strings.Contains(err.Error(), s) }
+}
@@ -0,0 +1,132 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/versions"
+)
+
+// Latest returns the newest version of path: the highest tagged release,
+// else the highest pre-release, else whatever the proxy reports as @latest
+// (typically a pseudo-version of the default branch). Like the go command,
+// +incompatible versions are only chosen if there is no other candidate.
+func Latest(ctx context.Context, c *proxy.Client, path string) (string, error) {
+	v, reason, err := latest(ctx, c, path)
+	if err != nil {
+		return "", err
+	}
+	selected(path, v, reason)
+	return v, nil
+}
+
+// latest returns the version Latest selects and why, without counting it
+// as selected.
+func latest(ctx context.Context, c *proxy.Client, path string) (string, string, error) {
+	list, err := c.Versions(ctx, path)
+	if err != nil {
+		return "", "", err
+	}
+	for _, pre := range []bool{false, true} {
+		if v := newest(versions.Releases(list, pre)); v != "" {
+			for _, n := range versions.Releases(list, true) {
+				if semver.Compare(n, v) <= 0 {
+					break
+				}
+				reason := "incompatible"
+				if semver.Prerelease(n) != "" {
+					reason = "prerelease"
+				}
+				rejected(path, n, reason)
+			}
+			return v, "latest", nil
+		}
+	}
+	info, err := c.Latest(ctx, path)
+	if err != nil {
+		return "", "", err
+	}
+	return info.Version, "no tagged version, @latest of the proxy", nil
+}
+
+// selected logs the version chosen for path and why.
+func selected(path, version, reason string, args ...any) {
+	metrics.Add(metrics.ModulesResolved, 1)
+	slog.Info("selected version", append([]any{"module", path, "version", version, "reason", reason}, args...)...)
+}
+
+// rejected logs a candidate version of path that was passed over and why.
+func rejected(path, version, reason string, args ...any) {
+	slog.Debug("rejected version", append([]any{"module", path, "version", version, "reason", reason}, args...)...)
+}
+
+// Package returns the module providing the package pkg and its latest
+// version. Like the go command, it picks the longest module path the proxy
+// knows.
+func Package(ctx context.Context, c *proxy.Client, pkg string) (string, string, error) {
+	for p := pkg; p != "." && p != "/"; p = path.Dir(p) {
+		v, err := Latest(ctx, c, p)
+		if err == nil {
+			return p, v, nil
+		}
+		if !proxy.Unavailable(err) {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("no module provides package %s", pkg)
+}
+
+// newest returns the first compatible version of the sorted list, falling
+// back to the first +incompatible one.
+func newest(list []string) string {
+	for _, v := range list {
+		if !strings.HasSuffix(v, "+incompatible") {
+			return v
+		}
+	}
+	if len(list) > 0 {
+		return list[0]
+	}
+	return ""
+}
+
+// LatestMajor returns the module path of the highest major version of the
+// module at path published after the one of version, and its latest version.
+// The paths of the following major versions are probed one after another
+// until the proxy does not know one. For a +incompatible version vN, the
+// probing starts at the path of vN itself, since modules often adopt a /vN
+// suffix within the major version they were published at before. It returns
+// empty strings if there is no newer major version.
+func LatestMajor(ctx context.Context, c *proxy.Client, path, version string) (newPath, latest string, err error) {
+	n := versions.PathMajor(path, version)
+	if n < 0 {
+		return "", "", nil
+	}
+	next := n + 1
+	if strings.HasSuffix(version, "+incompatible") {
+		next = n
+	}
+	for ; ; next++ {
+		p := versions.MajorPath(path, next)
+		v, err := Latest(ctx, c, p)
+		if proxy.Unavailable(err) {
+			if next == n {
+				// No /vN path within the incompatible major version; a
+				// later major version can still exist.
+				continue
+			}
+			return newPath, latest, nil
+		}
+		if err != nil {
+			return "", "", err
+		}
+		newPath, latest = p, v
+	}
+}
@@ -0,0 +1,67 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+
+	"pin-go-dependencies/internal/proxy"
+)
+
+// Retraction is a retract directive of a module.
+type Retraction struct {
+	Low       string
+	High      string
+	Rationale string
+}
+
+// String formats the retracted interval like the retract directive does.
+func (r Retraction) String() string {
+	if r.Low == r.High {
+		return r.Low
+	}
+	return "[" + r.Low + ", " + r.High + "]"
+}
+
+// Retractions returns the retract directives of path. As in the go command,
+// they are read from the go.mod of the latest version of the module.
+func Retractions(ctx context.Context, c *proxy.Client, path string) ([]Retraction, error) {
+	latest, _, err := latest(ctx, c, path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.GoMod(ctx, path, latest)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseLax(path+"@"+latest+"/go.mod", data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s@%s: parsing go.mod: %w", path, latest, err)
+	}
+	rs := make([]Retraction, 0, len(f.Retract))
+	for _, r := range f.Retract {
+		rs = append(rs, Retraction{Low: r.Low, High: r.High, Rationale: r.Rationale})
+	}
+	return rs, nil
+}
+
+// Retracted returns the retraction covering version, or nil.
+func Retracted(rs []Retraction, version string) *Retraction {
+	for i, r := range rs {
+		if semver.Compare(r.Low, version) <= 0 && semver.Compare(version, r.High) <= 0 {
+			return &rs[i]
+		}
+	}
+	return nil
+}
+
+// CheckRetracted returns the retraction covering path@version, or nil.
+func CheckRetracted(ctx context.Context, c *proxy.Client, path, version string) (*Retraction, error) {
+	rs, err := Retractions(ctx, c, path)
+	if err != nil {
+		return nil, err
+	}
+	return Retracted(rs, version), nil
+}
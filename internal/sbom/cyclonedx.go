@@ -0,0 +1,95 @@
+package sbom
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The subset of the CycloneDX 1.5 JSON schema written by CycloneDX.
+type (
+	cdxBOM struct {
+		BOMFormat    string          `json:"bomFormat"`
+		SpecVersion  string          `json:"specVersion"`
+		SerialNumber string          `json:"serialNumber"`
+		Version      int             `json:"version"`
+		Metadata     cdxMetadata     `json:"metadata"`
+		Components   []cdxComponent  `json:"components"`
+		Dependencies []cdxDependency `json:"dependencies"`
+	}
+	cdxMetadata struct {
+		Timestamp string       `json:"timestamp"`
+		Tools     cdxTools     `json:"tools"`
+		Component cdxComponent `json:"component"`
+	}
+	cdxTools struct {
+		Components []cdxComponent `json:"components"`
+	}
+	cdxComponent struct {
+		Type       string        `json:"type"`
+		BOMRef     string        `json:"bom-ref,omitempty"`
+		Name       string        `json:"name"`
+		Version    string        `json:"version,omitempty"`
+		PURL       string        `json:"purl,omitempty"`
+		Properties []cdxProperty `json:"properties,omitempty"`
+	}
+	cdxProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	cdxDependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	}
+)
+
+// CycloneDX returns the inventory as a CycloneDX 1.5 JSON document. The main
+// module is the root component and depends on every module of the build
+// list.
+func CycloneDX(inv *Inventory) ([]byte, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	root := cdxComponent{
+		Type:   "application",
+		BOMRef: inv.Main.PURL(),
+		Name:   inv.Main.Path,
+		PURL:   inv.Main.PURL(),
+	}
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + uuid,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: inv.Created.Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: ToolName}}},
+			Component: root,
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{{Ref: root.BOMRef, DependsOn: []string{}}},
+	}
+	for _, c := range inv.Components {
+		comp := cdxComponent{
+			Type:    "library",
+			BOMRef:  c.PURL(),
+			Name:    c.Path,
+			Version: c.Version,
+			PURL:    c.PURL(),
+		}
+		for _, p := range c.properties() {
+			comp.Properties = append(comp.Properties, cdxProperty{Name: p[0], Value: p[1]})
+		}
+		bom.Components = append(bom.Components, comp)
+		bom.Dependencies[0].DependsOn = append(bom.Dependencies[0].DependsOn, comp.BOMRef)
+	}
+	return marshal(bom)
+}
+
+func marshal(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
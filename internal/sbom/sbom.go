@@ -0,0 +1,131 @@
+// Package sbom describes the build list of a module as a software bill of
+// materials in the CycloneDX and SPDX JSON formats.
+package sbom
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gosum"
+)
+
+// ToolName identifies this tool as the creator of a document.
+const ToolName = "pin-go-dependencies"
+
+// Property names attached to components.
+const (
+	// PropertyChecksum holds the h1 hash of the module from go.sum.
+	PropertyChecksum = "go:checksum:h1"
+	// PropertyReplace holds the module version a component is replaced by.
+	PropertyReplace = "go:replace"
+	// PropertyLocal holds the directory a component is replaced by. Such
+	// components are not artifacts of any module registry.
+	PropertyLocal = "go:replace:local"
+)
+
+// Component is a module of the build list.
+type Component struct {
+	Path    string
+	Version string
+	// Checksum is the h1 hash of the module contents from go.sum, if any.
+	Checksum string
+	// Replace is the replacement module version, and Local the replacement
+	// directory; at most one of them is set.
+	Replace string
+	Local   string
+}
+
+// PURL returns the package URL of the component.
+func (c Component) PURL() string {
+	segs := strings.Split(c.Path, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	purl := "pkg:golang/" + strings.Join(segs, "/")
+	if c.Version != "" {
+		// A literal "+" would read as a space to some purl parsers.
+		purl += "@" + strings.ReplaceAll(url.PathEscape(c.Version), "+", "%2B")
+	}
+	return purl
+}
+
+// properties returns the Go specific properties of c in a fixed order.
+func (c Component) properties() [][2]string {
+	var props [][2]string
+	if c.Checksum != "" {
+		props = append(props, [2]string{PropertyChecksum, c.Checksum})
+	}
+	if c.Replace != "" {
+		props = append(props, [2]string{PropertyReplace, c.Replace})
+	}
+	if c.Local != "" {
+		props = append(props, [2]string{PropertyLocal, c.Local})
+	}
+	return props
+}
+
+// Inventory is the main module and the modules of its build list.
+type Inventory struct {
+	Main       Component
+	Components []Component
+	// Created is the creation time recorded in the documents.
+	Created time.Time
+}
+
+// Collect lists the build list of the module whose go.mod is file and looks
+// up the checksums of its modules in the go.sum next to it. Workspaces are
+// ignored, so the inventory matches what a release build of the module uses.
+func Collect(ctx context.Context, file string) (*Inventory, error) {
+	dir := filepath.Dir(file)
+	mods, err := gocmd.ListModules(ctx, dir, gocmd.ListOptions{GoWork: "off"})
+	if err != nil {
+		return nil, err
+	}
+	sum, err := gosum.Read(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		return nil, err
+	}
+	inv := &Inventory{Created: time.Now().UTC().Truncate(time.Second)}
+	for _, m := range mods {
+		if m.Main {
+			inv.Main = Component{Path: m.Path}
+			continue
+		}
+		c := Component{Path: m.Path, Version: m.Version}
+		switch {
+		case m.Replace != nil && m.Replace.Version == "":
+			c.Local = m.Replace.Path
+		case m.Replace != nil:
+			c.Replace = m.Replace.Path + "@" + m.Replace.Version
+			c.Checksum = sum.Hash(m.Replace.Path, m.Replace.Version)
+		default:
+			c.Checksum = sum.Hash(m.Path, m.Version)
+		}
+		inv.Components = append(inv.Components, c)
+	}
+	if inv.Main.Path == "" {
+		return nil, fmt.Errorf("%s: go list did not report the main module", file)
+	}
+	return inv, nil
+}
+
+// random is the source of the UUIDs identifying documents.
+var random io.Reader = rand.Reader
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(random, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,84 @@
+package sbom
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// inventory covers every kind of component: plain, without checksum,
+// +incompatible, replaced by a module version and by a directory.
+var inventory = &Inventory{
+	Main: Component{Path: "example.com/main"},
+	Components: []Component{
+		{Path: "example.com/a", Version: "v1.2.3", Checksum: "h1:8Y6sT0r6eYx1uVZ3TfbuXHKmU7YXTc6dT3o3a7rTQnU="},
+		{Path: "example.com/nosum", Version: "v0.1.0"},
+		{Path: "github.com/old/lib", Version: "v2.0.0+incompatible", Checksum: "h1:Q0k0EQvlr+Yw9xnhmw3VYc3mQy8pwQ4p0lL35Ej2n8w="},
+		{Path: "example.com/replaced", Version: "v1.0.0", Replace: "example.com/fork@v1.0.1", Checksum: "h1:2S1h7wXjH6+Q1mN8i3m4cZkQ2N0VzKQdd4GUb8Yp5Tc="},
+		{Path: "example.com/local", Version: "v0.0.0-00010101000000-000000000000", Local: "../local"},
+	},
+	Created: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// zeros replaces the random source so that documents get a fixed UUID.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestGolden(t *testing.T) {
+	old := random
+	random = zeros{}
+	t.Cleanup(func() { random = old })
+
+	for _, tt := range []struct {
+		golden string
+		write  func(*Inventory) ([]byte, error)
+	}{
+		{"cyclonedx.golden", CycloneDX},
+		{"spdx.golden", SPDX},
+	} {
+		t.Run(tt.golden, func(t *testing.T) {
+			got, err := tt.write(inventory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(name, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s differs from %s, rerun with -update if the change is intended:\n%s", tt.golden, name, got)
+			}
+		})
+	}
+}
+
+func TestPURL(t *testing.T) {
+	for _, tt := range []struct {
+		c    Component
+		want string
+	}{
+		{Component{Path: "example.com/a", Version: "v1.2.3"}, "pkg:golang/example.com/a@v1.2.3"},
+		{Component{Path: "example.com/main"}, "pkg:golang/example.com/main"},
+		{Component{Path: "github.com/old/lib", Version: "v2.0.0+incompatible"}, "pkg:golang/github.com/old/lib@v2.0.0%2Bincompatible"},
+	} {
+		if got := tt.c.PURL(); got != tt.want {
+			t.Errorf("PURL(%s@%s) = %q, want %q", tt.c.Path, tt.c.Version, got, tt.want)
+		}
+	}
+}
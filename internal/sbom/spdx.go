@@ -0,0 +1,118 @@
+package sbom
+
+import (
+	"fmt"
+	"time"
+)
+
+// The subset of the SPDX 2.3 JSON schema written by SPDX.
+type (
+	spdxDocument struct {
+		SPDXVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SPDXID            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+		DocumentDescribes []string           `json:"documentDescribes"`
+		Packages          []spdxPackage      `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}
+	spdxCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+	spdxPackage struct {
+		Name             string           `json:"name"`
+		SPDXID           string           `json:"SPDXID"`
+		VersionInfo      string           `json:"versionInfo,omitempty"`
+		DownloadLocation string           `json:"downloadLocation"`
+		FilesAnalyzed    bool             `json:"filesAnalyzed"`
+		LicenseConcluded string           `json:"licenseConcluded"`
+		LicenseDeclared  string           `json:"licenseDeclared"`
+		CopyrightText    string           `json:"copyrightText"`
+		ExternalRefs     []spdxRef        `json:"externalRefs"`
+		Annotations      []spdxAnnotation `json:"annotations,omitempty"`
+	}
+	spdxRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+	spdxAnnotation struct {
+		AnnotationType string `json:"annotationType"`
+		Annotator      string `json:"annotator"`
+		AnnotationDate string `json:"annotationDate"`
+		Comment        string `json:"comment"`
+	}
+	spdxRelationship struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+	}
+)
+
+// SPDX returns the inventory as an SPDX 2.3 JSON document. The document
+// describes the main module, which depends on every module of the build
+// list. SPDX has no generic properties, so the Go specific properties are
+// recorded as package annotations of the form "name=value".
+func SPDX(inv *Inventory) ([]byte, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return nil, err
+	}
+	created := inv.Created.Format(time.RFC3339)
+	creator := "Tool: " + ToolName
+	pkg := func(id string, c Component) spdxPackage {
+		p := spdxPackage{
+			Name:             c.Path,
+			SPDXID:           id,
+			VersionInfo:      c.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []spdxRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  c.PURL(),
+			}},
+		}
+		for _, prop := range c.properties() {
+			p.Annotations = append(p.Annotations, spdxAnnotation{
+				AnnotationType: "OTHER",
+				Annotator:      creator,
+				AnnotationDate: created,
+				Comment:        prop[0] + "=" + prop[1],
+			})
+		}
+		return p
+	}
+
+	const rootID = "SPDXRef-Package-0"
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              inv.Main.Path,
+		DocumentNamespace: "https://spdx.org/spdxdocs/" + ToolName + "-" + uuid,
+		CreationInfo:      spdxCreationInfo{Created: created, Creators: []string{creator}},
+		DocumentDescribes: []string{rootID},
+		Packages:          []spdxPackage{pkg(rootID, inv.Main)},
+		Relationships: []spdxRelationship{{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: rootID,
+		}},
+	}
+	for i, c := range inv.Components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		doc.Packages = append(doc.Packages, pkg(id, c))
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      rootID,
+			RelationshipType:   "DEPENDS_ON",
+			RelatedSPDXElement: id,
+		})
+	}
+	return marshal(doc)
+}
@@ -0,0 +1,288 @@
+// Package scripts finds `go install` and `go run` invocations of unpinned
+// tools in shell scripts, Makefiles, Dockerfiles and CI configuration, and
+// rewrites them to concrete versions.
+package scripts
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/fsutil"
+)
+
+// DefaultGlobs are the file name patterns scanned by default.
+var DefaultGlobs = []string{"Dockerfile*", "Makefile", "*.sh", "*.yml"}
+
+// Finding is a `go install` or `go run` of a remote package at @latest or
+// without a version.
+type Finding struct {
+	File string `json:"file" yaml:"file"`
+	// Line and Column locate the package argument; Column counts bytes
+	// from 1.
+	Line    int    `json:"line" yaml:"line"`
+	Column  int    `json:"column" yaml:"column"`
+	Command string `json:"command" yaml:"command"`
+	Package string `json:"package" yaml:"package"`
+	// Version is "latest", or empty if the argument has no version.
+	Version string `json:"version" yaml:"version"`
+
+	// start and end delimit the bytes of the file that a pinned version
+	// replaces: "latest" after the @, or an empty range after the package
+	// path, where "@" and the version are inserted.
+	start, end int
+}
+
+// Walk scans the files below paths whose name matches one of globs, in the
+// syntax of filepath.Match. Files named in paths are scanned whatever their
+// name. .git, vendor and node_modules directories are skipped. The
+// findings are sorted by file and position.
+func Walk(paths, globs []string) ([]Finding, error) {
+	var findings []Finding
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			found, err := ScanFile(root)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, found...)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				switch d.Name() {
+				case ".git", "vendor", "node_modules":
+					if path != root {
+						return filepath.SkipDir
+					}
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || !matches(globs, d.Name()) {
+				return nil
+			}
+			found, err := ScanFile(path)
+			if err != nil {
+				return err
+			}
+			findings = append(findings, found...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].start < findings[j].start
+	})
+	return findings, nil
+}
+
+func matches(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanFile returns the findings of the file name.
+func ScanFile(name string) ([]Finding, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Scan(name, data), nil
+}
+
+// Scan returns the findings of data, the content of the file name.
+func Scan(name string, data []byte) []Finding {
+	var findings []Finding
+	toks := lex(data)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.op || (t.text != "go" && !strings.HasSuffix(t.text, "/go")) {
+			continue
+		}
+		if i+1 >= len(toks) || toks[i+1].op || (toks[i+1].text != "install" && toks[i+1].text != "run") {
+			continue
+		}
+		command := toks[i+1].text
+		i += 2
+		for ; i < len(toks) && !toks[i].op; i++ {
+			arg := toks[i]
+			if strings.HasPrefix(arg.text, "-") {
+				if !strings.Contains(arg.text, "=") && ValueFlags[strings.TrimLeft(arg.text, "-")] {
+					i++
+				}
+				continue
+			}
+			if f, ok := finding(name, data, command, arg); ok {
+				findings = append(findings, f)
+			}
+			if command == "run" {
+				// The arguments after the package are passed to the
+				// program.
+				break
+			}
+		}
+		i--
+	}
+	return findings
+}
+
+// ValueFlags are the build flags of go install and go run that take a value
+// as a separate argument.
+var ValueFlags = map[string]bool{
+	"C": true, "asmflags": true, "buildmode": true, "buildvcs": true, "compiler": true,
+	"exec": true, "gccgoflags": true, "gcflags": true, "installsuffix": true,
+	"ldflags": true, "mod": true, "modfile": true, "o": true, "overlay": true,
+	"p": true, "pgo": true, "pkgdir": true, "tags": true, "toolexec": true,
+}
+
+// finding reports whether the argument arg of `go command` names a remote
+// package at @latest or without a version.
+func finding(name string, data []byte, command string, arg token) (Finding, bool) {
+	pkg, version, hasVersion := strings.Cut(arg.text, "@")
+	first, _, _ := strings.Cut(pkg, "/")
+	if !strings.Contains(first, ".") || module.CheckImportPath(pkg) != nil {
+		return Finding{}, false
+	}
+	if hasVersion && version != "latest" {
+		return Finding{}, false
+	}
+	line := bytes.Count(data[:arg.start], []byte("\n")) + 1
+	f := Finding{
+		File:    name,
+		Line:    line,
+		Column:  arg.start - bytes.LastIndexByte(data[:arg.start], '\n'),
+		Command: command,
+		Package: pkg,
+		Version: version,
+		start:   arg.start + len(pkg),
+		end:     arg.start + len(pkg),
+	}
+	if hasVersion {
+		f.start++
+		f.end = f.start + len(version)
+	}
+	return f, true
+}
+
+// token is a shell word, or an operator ending a command, such as ; or a
+// newline. The text of a word has the quotes around it removed; start is
+// its offset in the file after them.
+type token struct {
+	text  string
+	start int
+	op    bool
+}
+
+// lex splits data into shell words and operators. Comments, which start
+// with a # at the beginning of a word outside quotes, are skipped, and a
+// backslash before a newline continues the command on the next line.
+func lex(data []byte) []token {
+	var toks []token
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data) && data[i+1] == '\n':
+			i += 2
+		case c == '\\' && i+2 < len(data) && data[i+1] == '\r' && data[i+2] == '\n':
+			i += 3
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("\n;&|()<>`", c) >= 0:
+			toks = append(toks, token{text: string(c), start: i, op: true})
+			i++
+		default:
+			start := i
+			var quote byte
+			for i < len(data) {
+				c := data[i]
+				if quote != 0 {
+					if c == quote {
+						quote = 0
+					} else if c == '\n' {
+						break
+					}
+					i++
+					continue
+				}
+				if c == '"' || c == '\'' {
+					quote = c
+				} else if c == '\\' && i+1 < len(data) && data[i+1] != '\n' {
+					i++
+				} else if strings.IndexByte(" \t\r\n;&|()<>`", c) >= 0 {
+					break
+				}
+				i++
+			}
+			end := i
+			word := data[start:end]
+			// Only quotes around the whole word are removed.
+			if n := len(word); n >= 2 && (word[0] == '"' || word[0] == '\'') && word[n-1] == word[0] {
+				start, word = start+1, word[1:n-1]
+			}
+			toks = append(toks, token{text: string(word), start: start})
+			i = end
+		}
+	}
+	return toks
+}
+
+// Pin returns data with the version of every finding replaced by the
+// version of its package in pinned. Findings without a version in pinned
+// are left alone, and all other bytes are kept as they are.
+func Pin(data []byte, findings []Finding, pinned map[string]string) []byte {
+	var buf bytes.Buffer
+	last := 0
+	for _, f := range findings {
+		v, ok := pinned[f.Package]
+		if !ok || f.start < last || f.end > len(data) {
+			continue
+		}
+		buf.Write(data[last:f.start])
+		if f.Version == "" {
+			buf.WriteByte('@')
+		}
+		buf.WriteString(v)
+		last = f.end
+	}
+	buf.Write(data[last:])
+	return buf.Bytes()
+}
+
+// Fix rewrites the file name, whose findings are findings in the order of
+// their position, to the versions in pinned.
+func Fix(name string, findings []Finding, pinned map[string]string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	fixed := Pin(data, findings, pinned)
+	if bytes.Equal(fixed, data) {
+		return nil
+	}
+	return fsutil.ReplaceFile(name, fixed)
+}
@@ -0,0 +1,257 @@
+// Package sumcheck compares the go.sum of a module with its module graph:
+// it finds entries for module versions the graph no longer contains, hashes
+// the go command needs but go.sum lacks, and module versions recorded with
+// more than one hash.
+package sumcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/checksum"
+	"pin-go-dependencies/internal/failure"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/mvs"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Kind classifies a Finding.
+type Kind string
+
+const (
+	// Orphaned entries are for module versions outside the module graph.
+	Orphaned Kind = "orphaned"
+	// Missing entries are hashes the go command needs to load the module
+	// graph or build the packages of the main module.
+	Missing Kind = "missing"
+	// Conflicting entries record different hashes for one module version.
+	Conflicting Kind = "conflicting"
+)
+
+// goModSuffix marks the go.sum lines holding the hash of a go.mod file.
+const goModSuffix = "/go.mod"
+
+// Finding is a go.sum entry that does not match the module graph.
+type Finding struct {
+	Path string `json:"path"`
+	// Version carries the "/go.mod" suffix for go.mod hashes, exactly as in
+	// go.sum.
+	Version string `json:"version"`
+	Kind    Kind   `json:"kind"`
+	// Hashes are the recorded hashes; there are none for missing entries.
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// Options configures Check and Fix.
+type Options struct {
+	// Proxy serves the go.mod files of the module graph and, for Fix, the
+	// files the missing hashes are computed from.
+	Proxy *proxy.Client
+	// Checksum, if set, verifies the hashes Fix computes against the
+	// checksum database.
+	Checksum    *checksum.Verifier
+	Concurrency int
+	// FailFast stops at the first module that fails.
+	FailFast bool
+}
+
+// Check compares sum, the go.sum of the main module m, with the module graph
+// of m. The go.mod hash of every module version whose go.mod is part of the
+// graph is needed, as is the hash of every module providing a package
+// imported by the packages or tests of m; go.mod hashes are orphaned if
+// their version is not in the graph at all, module hashes if it is not the
+// selected one. Replaced modules are checked under their replacement, and
+// modules replaced by a directory have no hashes. The findings are sorted
+// by module version.
+func Check(ctx context.Context, m *gomod.Module, sum *gosum.Sum, opts Options) ([]Finding, error) {
+	g, err := mvs.NewReqs(opts.Proxy, m).Graph(ctx, opts.Concurrency, opts.FailFast)
+	if err != nil {
+		return nil, err
+	}
+	pkgMods, err := packageModules(ctx, m, sum)
+	if err != nil {
+		return nil, err
+	}
+
+	// target is the module version go.sum records for mv, if any.
+	target := func(mv module.Version) (module.Version, bool) {
+		switch rep := m.Replacement(mv); {
+		case rep == nil:
+			return mv, true
+		case rep.New.Version == "":
+			return module.Version{}, false
+		default:
+			return rep.New, true
+		}
+	}
+	need := make(map[module.Version]bool)
+	known := make(map[module.Version]bool)
+	for mv := range g.Nodes {
+		if t, ok := target(mv); ok {
+			known[module.Version{Path: t.Path, Version: t.Version + goModSuffix}] = true
+		}
+	}
+	for _, mv := range g.Loaded {
+		if t, ok := target(mv); ok {
+			need[module.Version{Path: t.Path, Version: t.Version + goModSuffix}] = true
+		}
+	}
+	for path, v := range g.Selected {
+		if t, ok := target(module.Version{Path: path, Version: v}); ok {
+			known[t] = true
+		}
+	}
+	for _, pm := range pkgMods {
+		// A module the build list does not have comes from an import
+		// go.mod does not satisfy; that is for pin to fix, not go.sum.
+		if g.Selected[pm.Path] != pm.Version {
+			continue
+		}
+		if t, ok := target(module.Version{Path: pm.Path, Version: pm.Version}); ok {
+			need[t] = true
+		}
+	}
+	for mv := range need {
+		known[mv] = true
+	}
+
+	hashes := make(map[module.Version][]string)
+	var recorded []module.Version
+	for _, l := range sum.Lines {
+		mv := module.Version{Path: l.Path, Version: l.Version}
+		if _, ok := hashes[mv]; !ok {
+			recorded = append(recorded, mv)
+		}
+		if !contains(hashes[mv], l.Hash) {
+			hashes[mv] = append(hashes[mv], l.Hash)
+		}
+	}
+	var found []Finding
+	for _, mv := range recorded {
+		hs := hashes[mv]
+		switch {
+		case !known[mv]:
+			found = append(found, Finding{Path: mv.Path, Version: mv.Version, Kind: Orphaned, Hashes: hs})
+		case len(hs) > 1:
+			found = append(found, Finding{Path: mv.Path, Version: mv.Version, Kind: Conflicting, Hashes: hs})
+		}
+	}
+	for mv := range need {
+		if _, ok := hashes[mv]; !ok {
+			found = append(found, Finding{Path: mv.Path, Version: mv.Version, Kind: Missing})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Version < b.Version
+	})
+	return found, nil
+}
+
+// packageModules lists the modules providing the packages of m on a copy of
+// its go.mod and go.sum: with -mod=readonly the go command leaves out the
+// packages of modules whose hashes are missing.
+func packageModules(ctx context.Context, m *gomod.Module, sum *gosum.Sum) ([]gocmd.Module, error) {
+	tmp, err := os.MkdirTemp("", "pin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	modFile := filepath.Join(tmp, "go.mod")
+	if err := os.WriteFile(modFile, m.Data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "go.sum"), sum.Format(), 0o644); err != nil {
+		return nil, err
+	}
+	return gocmd.PackageModules(ctx, filepath.Dir(m.Filename), modFile)
+}
+
+// Fix returns a copy of sum with the findings of Check resolved: orphaned
+// entries are dropped, and missing and conflicting hashes are computed from
+// the files the proxy serves and, if opts.Checksum is set, verified against
+// the checksum database. A computed hash that does not verify is an error.
+func Fix(ctx context.Context, sum *gosum.Sum, found []Finding, opts Options) (*gosum.Sum, error) {
+	drop := make(map[module.Version]bool)
+	var todo []Finding
+	for _, f := range found {
+		drop[module.Version{Path: f.Path, Version: f.Version}] = true
+		if f.Kind != Orphaned {
+			todo = append(todo, f)
+		}
+	}
+	fixed := &gosum.Sum{}
+	for _, l := range sum.Lines {
+		if !drop[module.Version{Path: l.Path, Version: l.Version}] {
+			fixed.Add(l)
+		}
+	}
+
+	lines, errs := workpool.Map(opts.Concurrency, opts.FailFast, todo, func(f Finding) (gosum.Line, error) {
+		h, err := hash(ctx, opts.Proxy, f.Path, f.Version)
+		if err != nil {
+			return gosum.Line{}, failure.Module(f.Path, f.Version, err)
+		}
+		return gosum.Line{Path: f.Path, Version: f.Version, Hash: h}, nil
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if opts.Checksum != nil {
+		results, err := opts.Checksum.Verify(ctx, lines, opts.Concurrency, opts.FailFast)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if r.Status == checksum.StatusMismatch {
+				errs = append(errs, fmt.Errorf("%s %s: computed hash does not verify: %s", r.Path, r.Version, r.Note))
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range lines {
+		fixed.Add(l)
+	}
+	return fixed, nil
+}
+
+// hash computes the go.sum hash of path at version, which is a go.mod hash
+// if version carries the "/go.mod" suffix.
+func hash(ctx context.Context, c *proxy.Client, path, version string) (string, error) {
+	if v, ok := strings.CutSuffix(version, goModSuffix); ok {
+		data, err := c.GoMod(ctx, path, v)
+		if err != nil {
+			return "", err
+		}
+		return gosum.HashGoMod(data)
+	}
+	data, err := c.Zip(ctx, path, version)
+	if err != nil {
+		return "", err
+	}
+	return gosum.HashZip(data)
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// Package toolchain compares Go versions and looks up released Go
+// toolchains.
+package toolchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pin-go-dependencies/internal/httpx"
+)
+
+// DownloadURL lists the Go releases, newest first, in JSON.
+const DownloadURL = "https://go.dev/dl/?mode=json"
+
+// Version returns the Go version of the toolchain name, such as "1.21.3"
+// for go1.21.3 or go1.21.3-custom, or "" if name is not a toolchain name.
+func Version(name string) string {
+	v, ok := strings.CutPrefix(name, "go")
+	if !ok {
+		return ""
+	}
+	v, _, _ = strings.Cut(v, "-")
+	if !IsValid(v) {
+		return ""
+	}
+	return v
+}
+
+// IsValid reports whether v is a Go version such as 1.21, 1.21rc1 or
+// 1.21.3.
+func IsValid(v string) bool {
+	_, ok := parse(v)
+	return ok
+}
+
+// version is a parsed Go version. A language version such as 1.21 has
+// patch -1 and kind -1; pre-releases such as 1.21rc1 have patch -1.
+type version struct {
+	major, minor, patch int
+	kind, pre           int
+}
+
+// Pre-release kinds in release order; kindRelease is a final release.
+var kinds = []string{"alpha", "beta", "rc"}
+
+const kindRelease = 3
+
+// parse parses a Go version: 1.21, 1.21rc1 or 1.21.3.
+func parse(s string) (version, bool) {
+	v := version{patch: -1, kind: -1}
+	major, rest, ok := strings.Cut(s, ".")
+	if !ok {
+		return v, false
+	}
+	var err error
+	if v.major, err = strconv.Atoi(major); err != nil {
+		return v, false
+	}
+	minor, patch, hasPatch := strings.Cut(rest, ".")
+	for i, k := range kinds {
+		if m, pre, ok := strings.Cut(minor, k); ok && !hasPatch {
+			if v.pre, err = strconv.Atoi(pre); err != nil {
+				return v, false
+			}
+			v.kind, minor = i, m
+			break
+		}
+	}
+	if v.minor, err = strconv.Atoi(minor); err != nil {
+		return v, false
+	}
+	if hasPatch {
+		if v.patch, err = strconv.Atoi(patch); err != nil {
+			return v, false
+		}
+		v.kind = kindRelease
+	}
+	return v, true
+}
+
+// Compare returns -1, 0 or +1 depending on whether the Go version x is
+// older than, equal to or newer than y, in the order of the go command:
+// 1.21 < 1.21rc1 < 1.21.0 < 1.21.1. Invalid versions compare as strings.
+func Compare(x, y string) int {
+	a, okA := parse(x)
+	b, okB := parse(y)
+	if !okA || !okB {
+		return strings.Compare(x, y)
+	}
+	for _, d := range [][2]int{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}, {a.kind, b.kind}, {a.pre, b.pre}} {
+		switch {
+		case d[0] < d[1]:
+			return -1
+		case d[0] > d[1]:
+			return +1
+		}
+	}
+	return 0
+}
+
+// release is an entry of the DownloadURL listing.
+type release struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
+// Latest returns the toolchain name of the newest stable Go release, such
+// as go1.23.2, as listed at url. The request stops when ctx is done.
+func Latest(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpx.NewClient(nil).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	var releases []release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return "", fmt.Errorf("decoding %s: %w", url, err)
+	}
+	newest := ""
+	for _, r := range releases {
+		v := Version(r.Version)
+		if r.Stable && v != "" && (newest == "" || Compare(v, Version(newest)) > 0) {
+			newest = r.Version
+		}
+	}
+	if newest == "" {
+		return "", fmt.Errorf("%s lists no stable Go release", url)
+	}
+	return newest, nil
+}
@@ -0,0 +1,170 @@
+// Package tools finds the tool dependencies of a module: the tool
+// directives of its go.mod and, for projects that predate them, the imports
+// of tools.go files, which are only built with the "tools" build tag.
+package tools
+
+import (
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gomod"
+)
+
+// SourceDirective is the Source of tools declared by a tool directive.
+const SourceDirective = "go.mod"
+
+// Tool is a tool package a module depends on.
+type Tool struct {
+	Path string `json:"path" yaml:"path"`
+	// Source is SourceDirective or the tools.go file importing the tool,
+	// relative to the module root.
+	Source string `json:"source" yaml:"source"`
+	// Module is the required module providing the tool and Version its
+	// version, or the version of its replacement. Both are empty if no
+	// required module provides the tool; Version is also empty for tools
+	// of the main module and of modules replaced by a directory.
+	Module  string `json:"module" yaml:"module"`
+	Version string `json:"version" yaml:"version"`
+	// Line is the line of the directive or import in Source.
+	Line int `json:"line" yaml:"line"`
+}
+
+// Provided reports whether the tool comes from the main module or a
+// required module.
+func (t Tool) Provided() bool { return t.Module != "" }
+
+// Find returns the tools of the module m, sorted by path and source.
+func Find(m *gomod.Module) ([]Tool, error) {
+	var tools []Tool
+	for _, t := range m.File.Tool {
+		tools = append(tools, Tool{Path: t.Path, Source: SourceDirective, Line: t.Syntax.Start.Line})
+	}
+	imports, err := scan(filepath.Dir(m.Filename))
+	if err != nil {
+		return nil, err
+	}
+	tools = append(tools, imports...)
+	for i := range tools {
+		tools[i].Module, tools[i].Version = Provider(m, tools[i].Path)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		if tools[i].Path != tools[j].Path {
+			return tools[i].Path < tools[j].Path
+		}
+		return tools[i].Source < tools[j].Source
+	})
+	return tools, nil
+}
+
+// Provider returns the module providing the package path and its effective
+// version: the main module or the required module with the longest
+// matching path. Both are empty if no such module provides it.
+func Provider(m *gomod.Module, path string) (mod, version string) {
+	if within(path, m.ModulePath()) {
+		return m.ModulePath(), ""
+	}
+	for _, r := range m.Requires() {
+		if within(path, r.Path) && len(r.Path) > len(mod) {
+			mod, version = r.Path, r.Version
+			if r.Replace != nil {
+				version = r.Replace.Version
+			}
+		}
+	}
+	return mod, version
+}
+
+func within(pkg, mod string) bool {
+	return pkg == mod || strings.HasPrefix(pkg, mod+"/")
+}
+
+// scan returns the imports of the tools.go files below dir that belong to
+// its module: directories holding another module, vendor and testdata
+// directories, and directories starting with . or _ are skipped, like the
+// go command does.
+func scan(dir string) ([]Tool, error) {
+	var tools []Tool
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := d.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		imports, err := toolImports(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for _, imp := range imports {
+			imp.Source = filepath.ToSlash(rel)
+			tools = append(tools, imp)
+		}
+		return nil
+	})
+	return tools, err
+}
+
+// toolImports returns the imports of the Go file name if it is a tools.go
+// file: one whose build constraint only holds with the "tools" tag.
+func toolImports(name string) ([]Tool, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, nil, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	isTools := false
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+		for _, c := range g.List {
+			expr, err := constraint.Parse(c.Text)
+			if err != nil {
+				continue
+			}
+			withTools := expr.Eval(func(tag string) bool { return tag == "tools" })
+			without := expr.Eval(func(string) bool { return false })
+			isTools = withTools && !without
+		}
+	}
+	if !isTools {
+		return nil, nil
+	}
+	var imports []Tool
+	for _, imp := range f.Imports {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		if module.CheckImportPath(path) == nil {
+			imports = append(imports, Tool{Path: path, Line: fset.Position(imp.Pos()).Line})
+		}
+	}
+	return imports, nil
+}
@@ -0,0 +1,482 @@
+// Package vendored checks that the vendor directory of a module matches the
+// requirements of its go.mod and the content of the vendored module
+// versions.
+package vendored
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/gosum"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// Dir is the name of the vendor directory and ModulesTxt the file in it
+// recording the vendored modules.
+const (
+	Dir        = "vendor"
+	ModulesTxt = "modules.txt"
+)
+
+// Module is a module recorded in vendor/modules.txt.
+type Module struct {
+	Path string
+	// Version is empty for entries that only record a replacement.
+	Version string
+	// Replace is the replacement, whose Version is empty for a directory.
+	Replace *module.Version
+	// Explicit is set for modules required by go.mod.
+	Explicit bool
+	// Packages are the vendored packages of the module.
+	Packages []string
+}
+
+// ParseModulesTxt parses the content of vendor/modules.txt: every module
+// starts with a "# path version [=> replacement]" line, followed by "##"
+// annotations and the import paths of its vendored packages.
+func ParseModulesTxt(data []byte) ([]Module, error) {
+	var mods []Module
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "## "):
+			if len(mods) == 0 {
+				return nil, fmt.Errorf("line %d: annotation before the first module", n)
+			}
+			for _, a := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+				if strings.TrimSpace(a) == "explicit" {
+					mods[len(mods)-1].Explicit = true
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			m, err := parseModuleLine(strings.TrimPrefix(line, "# "))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			mods = append(mods, m)
+		default:
+			if len(mods) == 0 {
+				return nil, fmt.Errorf("line %d: package %s before the first module", n, line)
+			}
+			mods[len(mods)-1].Packages = append(mods[len(mods)-1].Packages, line)
+		}
+	}
+	return mods, sc.Err()
+}
+
+func parseModuleLine(line string) (Module, error) {
+	var m Module
+	old, rep, replaced := strings.Cut(line, "=>")
+	f := strings.Fields(old)
+	switch len(f) {
+	case 1:
+		m.Path = f[0]
+	case 2:
+		m.Path, m.Version = f[0], f[1]
+	default:
+		return m, fmt.Errorf("malformed module line %q", line)
+	}
+	if replaced {
+		f := strings.Fields(rep)
+		switch len(f) {
+		case 1:
+			m.Replace = &module.Version{Path: f[0]}
+		case 2:
+			m.Replace = &module.Version{Path: f[0], Version: f[1]}
+		default:
+			return m, fmt.Errorf("malformed replacement in %q", line)
+		}
+	}
+	return m, nil
+}
+
+// Drift is a difference between the vendor directory and what it should
+// hold.
+type Drift struct {
+	// Path and Version identify the module; both are empty for files that
+	// belong to no vendored module.
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+	// File is the affected file, relative to the vendor directory.
+	File string `json:"file,omitempty"`
+}
+
+// Verifier checks vendor directories.
+type Verifier struct {
+	// Proxy serves the zip archives of modules missing from ModCache.
+	Proxy *proxy.Client
+	// ModCache is the module cache (GOMODCACHE), searched for downloaded
+	// zip archives. Empty disables the lookup.
+	ModCache string
+	// Hashes compares the vendored files with the module zip archives, on
+	// top of comparing the versions of vendor/modules.txt with go.mod.
+	Hashes bool
+	// Concurrency is the maximum number of modules read at the same time.
+	Concurrency int
+	// FailFast stops at the first module that cannot be read.
+	FailFast bool
+}
+
+// Verify returns the drift of the vendor directory next to the go.mod of m,
+// sorted by module path and file. A missing vendor/modules.txt is a drift
+// of its own.
+func (v *Verifier) Verify(ctx context.Context, m *gomod.Module) ([]Drift, error) {
+	dir := filepath.Join(filepath.Dir(m.Filename), Dir)
+	data, err := os.ReadFile(filepath.Join(dir, ModulesTxt))
+	if os.IsNotExist(err) {
+		return []Drift{{Reason: "no " + Dir + "/" + ModulesTxt + "; the module is not vendored", File: ModulesTxt}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mods, err := ParseModulesTxt(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ModulesTxt), err)
+	}
+	drift := compareVersions(m, mods)
+	if v.Hashes {
+		sum, err := gosum.Read(filepath.Join(filepath.Dir(m.Filename), "go.sum"))
+		if err != nil {
+			return nil, err
+		}
+		// The files of modules vendored at the wrong version are bound to
+		// differ; the version drift says it all.
+		skip := make(map[string]bool)
+		for _, d := range drift {
+			skip[d.Path] = true
+		}
+		hd, err := v.compareFiles(ctx, dir, mods, sum, skip)
+		if err != nil {
+			return nil, err
+		}
+		drift = append(drift, hd...)
+	}
+	sort.SliceStable(drift, func(i, j int) bool {
+		if drift[i].Path != drift[j].Path {
+			return drift[i].Path < drift[j].Path
+		}
+		return drift[i].File < drift[j].File
+	})
+	return drift, nil
+}
+
+// compareVersions reports the requirements of m that vendor/modules.txt
+// records at another version or replacement, or not at all, and the
+// modules it records as required that m does not require.
+func compareVersions(m *gomod.Module, mods []Module) []Drift {
+	vendored := make(map[string]Module)
+	for _, vm := range mods {
+		if vm.Version != "" {
+			vendored[vm.Path] = vm
+		}
+	}
+	var drift []Drift
+	required := make(map[string]bool)
+	for _, r := range m.Requires() {
+		required[r.Path] = true
+		vm, ok := vendored[r.Path]
+		switch {
+		case !ok:
+			drift = append(drift, Drift{Path: r.Path, Version: r.Version, Reason: "required by go.mod but not vendored"})
+		case vm.Version != r.Version:
+			drift = append(drift, Drift{Path: r.Path, Version: r.Version, Reason: "vendored at " + vm.Version + ", go.mod requires " + r.Version})
+		case !sameReplacement(vm.Replace, r.Replace):
+			drift = append(drift, Drift{Path: r.Path, Version: r.Version, Reason: "vendored with replacement " + describe(vm.Replace) + ", go.mod has " + describe(r.Replace)})
+		case !vm.Explicit:
+			drift = append(drift, Drift{Path: r.Path, Version: r.Version, Reason: "required by go.mod but not marked explicit in " + ModulesTxt})
+		}
+	}
+	for _, vm := range mods {
+		if vm.Version != "" && vm.Explicit && !required[vm.Path] {
+			drift = append(drift, Drift{Path: vm.Path, Version: vm.Version, Reason: "vendored as required, but go.mod does not require it"})
+		}
+	}
+	return drift
+}
+
+func sameReplacement(a, b *module.Version) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Path == b.Path && a.Version == b.Version
+}
+
+func describe(r *module.Version) string {
+	switch {
+	case r == nil:
+		return "none"
+	case r.Version == "":
+		return r.Path
+	}
+	return r.String()
+}
+
+// compareFiles compares every file below dir with the zip archive of the
+// vendored module it belongs to, reporting modified, added and missing
+// files. Modules replaced by a directory are skipped: they differ from any
+// published content on purpose. So are the modules in skip.
+func (v *Verifier) compareFiles(ctx context.Context, dir string, mods []Module, sum *gosum.Sum, skip map[string]bool) ([]Drift, error) {
+	files, err := hashTree(dir)
+	if err != nil {
+		return nil, err
+	}
+	delete(files, ModulesTxt)
+
+	// Files belong to the module with the longest path they are in.
+	var vendored []Module
+	for _, vm := range mods {
+		if vm.Version != "" && len(vm.Packages) > 0 {
+			vendored = append(vendored, vm)
+		}
+	}
+	sort.Slice(vendored, func(i, j int) bool { return len(vendored[i].Path) > len(vendored[j].Path) })
+	owned := make([]map[string]string, len(vendored))
+	var drift []Drift
+	for name, h := range files {
+		i := -1
+		for j, vm := range vendored {
+			if strings.HasPrefix(name, vm.Path+"/") {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			drift = append(drift, Drift{Reason: "file of no vendored module", File: name})
+			continue
+		}
+		if owned[i] == nil {
+			owned[i] = make(map[string]string)
+		}
+		owned[i][strings.TrimPrefix(name, vendored[i].Path+"/")] = h
+	}
+
+	type item struct {
+		mod   Module
+		files map[string]string
+	}
+	var items []item
+	for i, vm := range vendored {
+		if vm.Replace != nil && vm.Replace.Version == "" || skip[vm.Path] {
+			continue
+		}
+		items = append(items, item{vm, owned[i]})
+	}
+	found, errs := workpool.Map(v.Concurrency, v.FailFast, items, func(it item) ([]Drift, error) {
+		return v.compareModule(ctx, it.mod, it.files, sum)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for _, d := range found {
+		drift = append(drift, d...)
+	}
+	return drift, nil
+}
+
+// compareModule compares the vendored files of vm, keyed by their path in
+// the module, with its zip archive.
+func (v *Verifier) compareModule(ctx context.Context, vm Module, files map[string]string, sum *gosum.Sum) ([]Drift, error) {
+	src := module.Version{Path: vm.Path, Version: vm.Version}
+	if vm.Replace != nil {
+		src = *vm.Replace
+	}
+	zipped, err := v.zipFiles(ctx, src, sum)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	var drift []Drift
+	add := func(name, reason string) {
+		drift = append(drift, Drift{Path: vm.Path, Version: vm.Version, Reason: reason, File: vm.Path + "/" + name})
+	}
+	for name, h := range files {
+		zf, ok := zipped[name]
+		switch {
+		case !ok:
+			add(name, "not in the module zip; added locally")
+		case zf.hash != h:
+			add(name, "differs from the module zip; modified locally")
+		}
+	}
+	// go mod vendor copies every file of a package directory except tests
+	// and Go files that never build.
+	byDir := make(map[string][]string)
+	for name := range zipped {
+		byDir[path.Dir(name)] = append(byDir[path.Dir(name)], name)
+	}
+	for _, pkg := range vm.Packages {
+		rel := "."
+		if pkg != vm.Path {
+			rel = strings.TrimPrefix(pkg, vm.Path+"/")
+		}
+		for _, name := range byDir[rel] {
+			if _, ok := files[name]; !ok && vendorCopies(name, zipped[name].data) {
+				add(name, "in the module zip but missing from "+Dir)
+			}
+		}
+	}
+	return drift, nil
+}
+
+// zipFile is a file of a module zip archive.
+type zipFile struct {
+	hash string
+	data []byte
+}
+
+// zipFiles returns the files of the zip archive of mv, keyed by their path
+// in the module, after checking the archive against the hash in sum. The
+// archive comes from the module cache or else from the proxy.
+func (v *Verifier) zipFiles(ctx context.Context, mv module.Version, sum *gosum.Sum) (map[string]zipFile, error) {
+	data, err := v.zip(ctx, mv)
+	if err != nil {
+		return nil, err
+	}
+	if want := sum.Hash(mv.Path, mv.Version); want != "" {
+		got, err := gosum.HashZip(data)
+		if err != nil {
+			return nil, err
+		}
+		if got != want {
+			return nil, fmt.Errorf("zip hash %s does not match go.sum %s", got, want)
+		}
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading zip: %w", err)
+	}
+	prefix := mv.Path + "@" + mv.Version + "/"
+	files := make(map[string]zipFile)
+	for _, zf := range zr.File {
+		name, ok := strings.CutPrefix(zf.Name, prefix)
+		if !ok {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", zf.Name, err)
+		}
+		files[name] = zipFile{hash: fmt.Sprintf("%x", sha256.Sum256(content)), data: content}
+	}
+	return files, nil
+}
+
+func (v *Verifier) zip(ctx context.Context, mv module.Version) ([]byte, error) {
+	if v.ModCache != "" {
+		ep, err := module.EscapePath(mv.Path)
+		if err != nil {
+			return nil, err
+		}
+		ev, err := module.EscapeVersion(mv.Version)
+		if err != nil {
+			return nil, err
+		}
+		if data, err := os.ReadFile(filepath.Join(v.ModCache, "cache", "download", filepath.FromSlash(ep), "@v", ev+".zip")); err == nil {
+			return data, nil
+		}
+	}
+	if v.Proxy == nil {
+		return nil, fmt.Errorf("not in the module cache")
+	}
+	return v.Proxy.Zip(ctx, mv.Path, mv.Version)
+}
+
+// hashTree returns the SHA-256 of every regular file below dir, keyed by
+// its slash-separated path relative to dir.
+func hashTree(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fmt.Sprintf("%x", sha256.Sum256(data))
+		return nil
+	})
+	return files, err
+}
+
+// vendorCopies reports whether go mod vendor copies the file name of a
+// package directory with the given content: everything but tests, go.mod
+// and go.sum, and Go files that no set of build tags includes, such as
+// those tagged "ignore".
+func vendorCopies(name string, data []byte) bool {
+	base := path.Base(name)
+	if strings.HasSuffix(base, "_test.go") || base == "go.mod" || base == "go.sum" {
+		return false
+	}
+	if !strings.HasSuffix(base, ".go") {
+		return true
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, base, data, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return true
+	}
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+		for _, c := range g.List {
+			expr, err := constraint.Parse(c.Text)
+			if err == nil && !anyTags(expr, true) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// anyTags evaluates a build constraint the way the go command does when it
+// gathers all possible imports: every tag but "ignore" is both present and
+// absent, taking whichever value prefer asks for, which flips under a
+// negation.
+func anyTags(x constraint.Expr, prefer bool) bool {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		if x.Tag == "ignore" {
+			return false
+		}
+		return prefer
+	case *constraint.NotExpr:
+		return !anyTags(x.X, !prefer)
+	case *constraint.AndExpr:
+		return anyTags(x.X, prefer) && anyTags(x.Y, prefer)
+	case *constraint.OrExpr:
+		return anyTags(x.X, prefer) || anyTags(x.Y, prefer)
+	}
+	return true
+}
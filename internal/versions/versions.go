@@ -0,0 +1,129 @@
+// Package versions contains helpers for comparing module versions.
+package versions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// Delta classifies the step from one version to another.
+type Delta string
+
+const (
+	None  Delta = ""
+	Patch Delta = "patch"
+	Minor Delta = "minor"
+	Major Delta = "major"
+)
+
+// Rank orders deltas by severity, None being the lowest.
+func (d Delta) Rank() int {
+	switch d {
+	case Patch:
+		return 1
+	case Minor:
+		return 2
+	case Major:
+		return 3
+	}
+	return 0
+}
+
+// DeltaOf returns the kind of change from one version to another. Anything
+// that is not an upgrade, including downgrades, yields None; a change of
+// pre-release or build metadata only counts as a patch.
+func DeltaOf(from, to string) Delta {
+	if semver.Compare(from, to) >= 0 {
+		return None
+	}
+	switch {
+	case semver.Major(from) != semver.Major(to):
+		return Major
+	case semver.MajorMinor(from) != semver.MajorMinor(to):
+		return Minor
+	}
+	return Patch
+}
+
+// Releases returns the valid semantic versions among list, newest first.
+// Pre-release versions are dropped unless includePrerelease is set.
+func Releases(list []string, includePrerelease bool) []string {
+	var out []string
+	for _, v := range list {
+		if !semver.IsValid(v) || (!includePrerelease && semver.Prerelease(v) != "") {
+			continue
+		}
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return semver.Compare(out[i], out[j]) > 0 })
+	return out
+}
+
+// PathMajor returns the major version of the module at path at version:
+// the one of its path suffix, or of version for paths without one, where v0
+// counts as 1. It returns -1 if path is invalid.
+func PathMajor(path, version string) int {
+	_, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok {
+		return -1
+	}
+	if pathMajor != "" {
+		n, err := strconv.Atoi(strings.TrimLeft(pathMajor, "/.v"))
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	if m := semver.Major(version); m != "" && m != "v0" {
+		n, _ := strconv.Atoi(strings.TrimPrefix(m, "v"))
+		return n
+	}
+	return 1
+}
+
+// MajorPath returns the module path of major version n of the module at
+// path: the path without suffix for v0 and v1 and with a /vN suffix for
+// later versions, while gopkg.in paths always end in ".vN". It returns ""
+// if path is invalid.
+func MajorPath(path string, n int) string {
+	prefix, _, ok := module.SplitPathVersion(path)
+	if !ok {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(path, "gopkg.in/"):
+		return fmt.Sprintf("%s.v%d", prefix, n)
+	case n <= 1:
+		return prefix
+	}
+	return fmt.Sprintf("%s/v%d", prefix, n)
+}
+
+// NextRelease reports whether the release to is the first one that can
+// follow from: its next patch, minor or major version, or the release of the
+// pre-release from. Build metadata such as +incompatible is ignored.
+func NextRelease(from, to string) bool {
+	if !semver.IsValid(from) || !semver.IsValid(to) || semver.Prerelease(to) != "" {
+		return false
+	}
+	n := func(v string) [3]int {
+		var parts [3]int
+		core := strings.TrimPrefix(semver.Canonical(v), "v")
+		core, _, _ = strings.Cut(core, "+")
+		core, _, _ = strings.Cut(core, "-")
+		for i, s := range strings.SplitN(core, ".", 3) {
+			parts[i], _ = strconv.Atoi(s)
+		}
+		return parts
+	}
+	f, t := n(from), n(to)
+	if semver.Prerelease(from) != "" && t == f {
+		return true
+	}
+	return t == [3]int{f[0], f[1], f[2] + 1} || t == [3]int{f[0], f[1] + 1, 0} || t == [3]int{f[0] + 1, 0, 0}
+}
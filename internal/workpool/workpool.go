@@ -0,0 +1,123 @@
+// Package workpool runs independent jobs on a bounded number of goroutines.
+package workpool
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+)
+
+// DefaultSize is the pool size used when none is configured: the number of
+// usable CPUs, but at least 8 since the jobs mostly wait on the network.
+func DefaultSize() int {
+	if n := runtime.GOMAXPROCS(0); n > 8 {
+		return n
+	}
+	return 8
+}
+
+// Limit bounds the operations running at a time across all the pools whose
+// context carries it, such as pools started by the jobs of another. Only
+// the operations themselves, the proxy requests and go commands, take a
+// slot: a job does not hold one while it waits on a pool of its own, so
+// nested pools cannot deadlock.
+type Limit struct {
+	slots chan struct{}
+}
+
+// NewLimit returns a Limit of n operations at a time. A size below 1
+// selects DefaultSize.
+func NewLimit(n int) *Limit {
+	if n < 1 {
+		n = DefaultSize()
+	}
+	return &Limit{slots: make(chan struct{}, n)}
+}
+
+type limitKey struct{}
+
+// WithLimit returns a copy of ctx whose operations are bounded by l.
+func WithLimit(ctx context.Context, l *Limit) context.Context {
+	return context.WithValue(ctx, limitKey{}, l)
+}
+
+// Acquire waits for a slot of the Limit carried by ctx, if any, and returns
+// the function that releases it. It fails with the error of ctx if ctx is
+// done first.
+func Acquire(ctx context.Context) (release func(), err error) {
+	l, _ := ctx.Value(limitKey{}).(*Limit)
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type result[R any] struct {
+	index int
+	value R
+	err   error
+}
+
+// Map calls fn for every item using at most size goroutines at a time and
+// returns the results and errors in the order of items, independent of the
+// order in which the calls complete. A size below 1 selects DefaultSize.
+//
+// If failFast is set, Map stops calling fn after the first error and reports
+// only that error: the items that failed later or were skipped get zero
+// values and no error.
+func Map[T, R any](size int, failFast bool, items []T, fn func(T) (R, error)) ([]R, []error) {
+	if size < 1 {
+		size = DefaultSize()
+	}
+	if size > len(items) {
+		size = len(items)
+	}
+
+	jobs := make(chan int)
+	results := make(chan result[R])
+	var failed atomic.Bool
+	for w := 0; w < size; w++ {
+		go func() {
+			for i := range jobs {
+				if failFast && failed.Load() {
+					results <- result[R]{index: i}
+					continue
+				}
+				v, err := fn(items[i])
+				if err != nil {
+					failed.Store(true)
+				}
+				results <- result[R]{index: i, value: v, err: err}
+			}
+		}()
+	}
+	go func() {
+		for i := range items {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	values := make([]R, len(items))
+	errs := make([]error, len(items))
+	var first bool
+	for range items {
+		r := <-results
+		if r.err != nil && failFast {
+			// Calls already running when the first one failed are
+			// left out.
+			if first {
+				continue
+			}
+			first = true
+		}
+		values[r.index] = r.value
+		errs[r.index] = r.err
+	}
+	return values, errs
+}
@@ -0,0 +1,203 @@
+package workpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProxy serves every version list after a delay and records the peak
+// number of requests it handled at the same time.
+type fakeProxy struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	total    int
+}
+
+func (p *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.inFlight++
+	p.total++
+	if p.inFlight > p.peak {
+		p.peak = p.inFlight
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inFlight--
+		p.mu.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	fmt.Fprintln(w, "v1.0.0")
+}
+
+// fetch returns a Map function requesting the version list of a module.
+func fetch(base string) func(string) (string, error) {
+	return func(path string) (string, error) {
+		resp, err := http.Get(base + "/" + path + "/@v/list")
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return path + " " + string(data), err
+	}
+}
+
+func modules(n int) []string {
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("example.com/m%d", i)
+	}
+	return paths
+}
+
+func TestMapBoundsInFlightRequests(t *testing.T) {
+	for _, size := range []int{1, 3, 8} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			p := &fakeProxy{}
+			srv := httptest.NewServer(p)
+			defer srv.Close()
+
+			paths := modules(40)
+			got, errs := Map(size, false, paths, fetch(srv.URL))
+			if err := errors.Join(errs...); err != nil {
+				t.Fatal(err)
+			}
+			for i, path := range paths {
+				if want := path + " v1.0.0\n"; got[i] != want {
+					t.Errorf("result %d = %q, want %q", i, got[i], want)
+				}
+			}
+			if p.total != len(paths) {
+				t.Errorf("proxy got %d requests, want %d", p.total, len(paths))
+			}
+			if p.peak > size {
+				t.Errorf("peak of %d requests in flight, want at most %d", p.peak, size)
+			}
+			if size > 1 && p.peak < 2 {
+				t.Errorf("peak of %d requests in flight, want them to overlap", p.peak)
+			}
+		})
+	}
+}
+
+func TestMapDefaultSize(t *testing.T) {
+	p := &fakeProxy{}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	if _, errs := Map(0, false, modules(3*DefaultSize()), fetch(srv.URL)); errors.Join(errs...) != nil {
+		t.Fatal(errors.Join(errs...))
+	}
+	if p.peak > DefaultSize() {
+		t.Errorf("peak of %d requests in flight, want at most DefaultSize() = %d", p.peak, DefaultSize())
+	}
+}
+
+func TestMapErrorsInOrder(t *testing.T) {
+	boom := errors.New("boom")
+	_, errs := Map(4, false, []int{0, 1, 2, 3, 4, 5}, func(i int) (int, error) {
+		if i%2 == 1 {
+			return 0, fmt.Errorf("item %d: %w", i, boom)
+		}
+		return i, nil
+	})
+	for i, err := range errs {
+		if (i%2 == 1) != errors.Is(err, boom) {
+			t.Errorf("error %d = %v", i, err)
+		}
+	}
+}
+
+func TestMapFailFast(t *testing.T) {
+	boom := errors.New("boom")
+	var calls []int
+	fn := func(i int) (int, error) {
+		calls = append(calls, i)
+		if i >= 1 {
+			return 0, fmt.Errorf("item %d: %w", i, boom)
+		}
+		return i + 10, nil
+	}
+
+	// With one goroutine, the items after the first failure are skipped.
+	got, errs := Map(1, true, []int{0, 1, 2, 3}, fn)
+	if len(calls) != 2 {
+		t.Errorf("fn called for %v, want it to stop after item 1", calls)
+	}
+	if got[0] != 10 || errs[0] != nil || !errors.Is(errs[1], boom) {
+		t.Errorf("results %v, errors %v, want item 0 and the error of item 1", got, errs)
+	}
+	for i := 2; i < len(errs); i++ {
+		if got[i] != 0 || errs[i] != nil {
+			t.Errorf("skipped item %d = %d, %v, want zero values", i, got[i], errs[i])
+		}
+	}
+
+	calls = nil
+	if _, errs := Map(1, false, []int{0, 1, 2, 3}, fn); len(calls) != 4 || errors.Join(errs...) == nil {
+		t.Errorf("without failFast, fn called for %v, want every item", calls)
+	}
+}
+
+func TestLimitNested(t *testing.T) {
+	// Jobs of an outer pool start inner pools of their own; only the
+	// operations of the inner jobs take a slot, so a Limit of 1 bounds
+	// them without a deadlock.
+	for _, n := range []int{1, 3} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			ctx := WithLimit(context.Background(), NewLimit(n))
+			var mu sync.Mutex
+			inFlight, peak := 0, 0
+			op := func(int) (struct{}, error) {
+				release, err := Acquire(ctx)
+				if err != nil {
+					return struct{}{}, err
+				}
+				defer release()
+				mu.Lock()
+				inFlight++
+				if inFlight > peak {
+					peak = inFlight
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				return struct{}{}, nil
+			}
+			_, errs := Map(4, false, []int{0, 1, 2, 3}, func(int) (struct{}, error) {
+				_, errs := Map(4, false, []int{0, 1, 2, 3, 4, 5}, op)
+				return struct{}{}, errors.Join(errs...)
+			})
+			if err := errors.Join(errs...); err != nil {
+				t.Fatal(err)
+			}
+			if peak > n {
+				t.Errorf("peak of %d operations, want at most %d", peak, n)
+			}
+		})
+	}
+}
+
+func TestAcquireCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithLimit(context.Background(), NewLimit(1)))
+	release, err := Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	cancel()
+	if _, err := Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire of a full Limit after cancel = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,415 @@
+// Package pinner is the library interface of the pinning tool: it resolves
+// the build list of a module, rewrites its go.mod so every module is
+// required at the resolved version, and checks go.mod files for
+// requirements that are not reproducibly pinned. The pin and check
+// commands of the CLI are built on it.
+//
+// Nothing is printed; failures are reported through the returned errors,
+// which match ErrRetracted and ErrNotFound with errors.Is where they apply.
+// Progress is logged through the default slog logger, which is up to the
+// embedding program to configure.
+package pinner
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"pin-go-dependencies/internal/check"
+	"pin-go-dependencies/internal/gocmd"
+	"pin-go-dependencies/internal/gomod"
+	"pin-go-dependencies/internal/lockfile"
+	"pin-go-dependencies/internal/pin"
+	"pin-go-dependencies/internal/proxy"
+	"pin-go-dependencies/internal/resolve"
+	"pin-go-dependencies/internal/workpool"
+)
+
+// ErrRetracted is matched by the error of Resolve when the build list holds
+// a version retracted by its authors, unless WithAllowRetracted is given.
+var ErrRetracted = pin.ErrRetracted
+
+// ErrNotFound is matched by errors caused by a module or version that the
+// module proxy or the go command does not know.
+var ErrNotFound = proxy.ErrNotFound
+
+// defaultCacheTTL is how long cached version lists stay valid, the default
+// of the --cache-ttl flag.
+const defaultCacheTTL = time.Hour
+
+// Pinner resolves and checks go.mod files. It is safe for concurrent use,
+// and its concurrent calls share the limit of WithConcurrency.
+type Pinner struct {
+	goproxy        string
+	cacheDir       string
+	cacheTTL       time.Duration
+	noCache        bool
+	concurrency    int
+	failFast       bool
+	offline        bool
+	allowRetracted bool
+	// limit bounds the proxy requests and go commands of all calls.
+	limit *workpool.Limit
+
+	// proxy is nil if it could not be set up; proxyErr then says why.
+	proxy    *proxy.Client
+	proxyErr error
+}
+
+// Option configures a Pinner.
+type Option func(*Pinner)
+
+// WithProxy sets the module proxy list, with the syntax of GOPROXY. By
+// default the GOPROXY of the go environment is used.
+func WithProxy(goproxy string) Option {
+	return func(p *Pinner) { p.goproxy = goproxy }
+}
+
+// WithCacheDir sets the directory proxy responses are cached in; an empty
+// dir disables the cache. By default they are cached below the user cache
+// directory.
+func WithCacheDir(dir string) Option {
+	return func(p *Pinner) { p.cacheDir, p.noCache = dir, dir == "" }
+}
+
+// WithCacheTTL sets how long cached version lists stay valid, an hour by
+// default.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(p *Pinner) { p.cacheTTL = ttl }
+}
+
+// WithConcurrency sets the maximum number of proxy requests and go commands
+// running at a time, across all the calls of the Pinner.
+func WithConcurrency(n int) Option {
+	return func(p *Pinner) { p.concurrency = n }
+}
+
+// WithFailFast makes the Pinner stop at the first module that fails instead
+// of looking up the others and reporting all failures.
+func WithFailFast(failFast bool) Option {
+	return func(p *Pinner) { p.failFast = failFast }
+}
+
+// WithOffline keeps the Pinner from using the network: modules are only
+// taken from the module cache, and the go commands it runs do not download
+// anything. Other Pinners of the program are not affected.
+func WithOffline(offline bool) Option {
+	return func(p *Pinner) { p.offline = offline }
+}
+
+// WithAllowRetracted allows Resolve to pin versions retracted by their
+// authors.
+func WithAllowRetracted(allow bool) Option {
+	return func(p *Pinner) { p.allowRetracted = allow }
+}
+
+// New returns a Pinner configured by opts. If the module proxy cannot be
+// set up, such as with GOPROXY=off, New still succeeds when retracted
+// versions are allowed, since pinning does not need the proxy then; the
+// calls that do need it fail.
+func New(opts ...Option) (*Pinner, error) {
+	p := &Pinner{concurrency: workpool.DefaultSize(), cacheTTL: defaultCacheTTL}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.limit = workpool.NewLimit(p.concurrency)
+	if p.offline {
+		env, err := gocmd.Env("GOMODCACHE")
+		if err != nil {
+			return nil, err
+		}
+		p.proxy = proxy.NewOffline(filepath.Join(env["GOMODCACHE"], "cache", "download"))
+		return p, nil
+	}
+	var popts proxy.Options
+	var err error
+	if !p.noCache {
+		dir := p.cacheDir
+		if dir == "" {
+			if dir, err = proxy.DefaultCacheDir(); err != nil {
+				return nil, err
+			}
+		}
+		popts.Cache = &proxy.Cache{Dir: dir, TTL: p.cacheTTL}
+	}
+	if p.proxy, p.proxyErr = proxy.FromEnv(p.goproxy, popts); p.proxyErr != nil && !p.allowRetracted {
+		return nil, p.proxyErr
+	}
+	return p, nil
+}
+
+// Change is a requirement added or changed by a Resolution. Old is empty for
+// added requirements.
+type Change = pin.Change
+
+// Resolution is the pending rewrite of a go.mod and its go.sum. Nothing is
+// written until it is passed to Apply.
+type Resolution struct {
+	// File is the go.mod the resolution is for.
+	File    string
+	Added   []Change
+	Changed []Change
+	// Toolchain is set if the toolchain directive changes; its Path is
+	// "toolchain" and Old is empty if there was none.
+	Toolchain *Change
+
+	res *pin.Result
+}
+
+// Modified reports whether applying r would modify go.mod or go.sum.
+func (r *Resolution) Modified() bool { return r.res.Modified() }
+
+// Diff returns a unified diff of the pending go.mod and go.sum changes.
+func (r *Resolution) Diff() []byte { return r.res.Diff() }
+
+// Files returns the files applying r writes.
+func (r *Resolution) Files() []string { return r.res.Files() }
+
+// WorkspaceMode selects how ResolveWith takes go.work files into account.
+type WorkspaceMode int
+
+const (
+	// WorkspaceOff ignores go.work files, like Resolve does.
+	WorkspaceOff WorkspaceMode = iota
+	// WorkspaceAuto pins to the workspace build list when the module is
+	// used by a go.work, which the go command finds like it does for
+	// builds.
+	WorkspaceAuto
+	// WorkspaceOn requires a go.work that uses the module.
+	WorkspaceOn
+)
+
+// ResolveOptions configures ResolveWith. The zero value resolves like
+// Resolve does.
+type ResolveOptions struct {
+	Workspace WorkspaceMode
+	// Exclude, if set, reports modules to leave alone: their requirements
+	// are kept as they are and they are never added.
+	Exclude func(path string) bool
+	// Toolchain, if set, is the toolchain name, such as go1.22.3, the
+	// toolchain directive is set to.
+	Toolchain string
+	// Before, if set, pins every module to the newest release published
+	// before it instead of the version the go command selects; pre-releases
+	// are only picked with IncludePrerelease.
+	Before            time.Time
+	IncludePrerelease bool
+	// Commits, if set, pins only their modules, to the pseudo-versions of
+	// the commits their Version fields name by full or abbreviated hash.
+	Commits []module.Version
+	// FixMVS only raises the requirements below the version minimal
+	// version selection picks from the requirement graph to that version.
+	FixMVS bool
+}
+
+// Resolve resolves the build list of the module whose go.mod is at
+// modfilePath, ignoring any workspace, and returns the rewrite pinning
+// every module of it. The go commands and proxy requests it runs stop when
+// ctx is done.
+func (p *Pinner) Resolve(ctx context.Context, modfilePath string) (*Resolution, error) {
+	return p.ResolveWith(ctx, modfilePath, ResolveOptions{})
+}
+
+// ResolveWith is like Resolve, configured by opts. Commits cannot be
+// combined with Before or FixMVS, nor FixMVS with Before or Toolchain.
+func (p *Pinner) ResolveWith(ctx context.Context, modfilePath string, opts ResolveOptions) (*Resolution, error) {
+	asOf := !opts.Before.IsZero()
+	if len(opts.Commits) > 0 && (asOf || opts.FixMVS) || opts.FixMVS && (asOf || opts.Toolchain != "") {
+		return nil, errors.New("pinner: Commits cannot be combined with Before or FixMVS, nor FixMVS with Before or Toolchain")
+	}
+	// The proxy is needed for Before and to check for retracted versions.
+	// Otherwise it only validates module replacements, which the go
+	// command checks while listing the build list anyway.
+	ctx = p.context(ctx)
+	c, err := p.client()
+	if err != nil && (asOf || opts.FixMVS || len(opts.Commits) > 0 || !p.allowRetracted) {
+		return nil, err
+	}
+	var res *pin.Result
+	switch {
+	case opts.FixMVS:
+		res, err = pin.PlanFixMVS(ctx, modfilePath, c, p.concurrency, p.failFast, opts.Exclude)
+	case len(opts.Commits) > 0:
+		res, err = pin.PlanCommits(ctx, modfilePath, opts.Commits, pin.UpdateOptions{Proxy: c, Concurrency: p.concurrency, FailFast: p.failFast, Toolchain: opts.Toolchain})
+	default:
+		popts := pin.Options{
+			Workspace:      workspaceModes[opts.Workspace],
+			Proxy:          c,
+			AllowRetracted: p.allowRetracted,
+			Exclude:        opts.Exclude,
+			Toolchain:      opts.Toolchain,
+			Concurrency:    p.concurrency,
+			FailFast:       p.failFast,
+		}
+		if asOf {
+			popts.AsOf = &resolve.AsOfOptions{
+				Before:            opts.Before,
+				IncludePrerelease: opts.IncludePrerelease,
+				AllowRetracted:    p.allowRetracted,
+				Concurrency:       p.concurrency,
+				FailFast:          p.failFast,
+			}
+		}
+		res, err = pin.Plan(ctx, modfilePath, popts)
+	}
+	if err != nil {
+		return nil, wrap(err)
+	}
+	return &Resolution{File: res.File, Added: res.Added, Changed: res.Changed, Toolchain: res.Toolchain, res: res}, nil
+}
+
+// workspaceModes maps the workspace modes to those of the pin package.
+var workspaceModes = map[WorkspaceMode]pin.WorkspaceMode{
+	WorkspaceOff:  pin.WorkspaceOff,
+	WorkspaceAuto: pin.WorkspaceAuto,
+	WorkspaceOn:   pin.WorkspaceOn,
+}
+
+// Apply writes the go.mod and go.sum of r. It fails without writing anything
+// if ctx is done; files that are being written are written completely.
+func (p *Pinner) Apply(ctx context.Context, r *Resolution) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.res.Apply()
+}
+
+// Violation is a requirement that is not reproducibly pinned. Its Rule
+// identifies the kind of violation, such as "missing-sum" or "retracted",
+// and its Line is the line of the directive in File, or 0 if there is none.
+type Violation = check.Violation
+
+// Failing returns the number of violations no exception covers, which are
+// the ones that fail a check.
+func Failing(vs []Violation) int { return check.Failing(vs) }
+
+// Rules is the policy of the configuration file, and MinVersion one of its
+// minimum versions.
+type (
+	Rules      = check.Rules
+	MinVersion = check.MinVersion
+)
+
+// Exception leaves out the violations of a rule for the modules matching a
+// pattern. They are still reported, with the justification.
+type Exception = check.Exception
+
+// CheckOptions selects the optional rules of CheckWith. The zero value
+// checks like Check does.
+type CheckOptions struct {
+	// NoPseudo reports requirements on pseudo-versions.
+	NoPseudo bool
+	// MinGo, if set, reports a go directive older than this Go version.
+	MinGo string
+	// RequireToolchain reports a missing toolchain directive.
+	RequireToolchain bool
+	// AllowPseudoTool, if set, reports tools that may be provided at a
+	// pseudo-version.
+	AllowPseudoTool func(path string) bool
+	// MVS reports requirements on a lower version than minimal version
+	// selection over the requirement graph picks.
+	MVS bool
+	// Generate reports go:generate directives running a tool with go run
+	// at a floating version.
+	Generate bool
+	// LockFile, if set, is a lock file the build list and go.sum must
+	// match.
+	LockFile string
+	// Ignore, if set, reports modules whose violations are left out.
+	Ignore func(path string) bool
+	// Rules and Exceptions are the policy of the configuration file.
+	// MinVersions are matched in order, so the most specific pattern
+	// should come first.
+	Rules      Rules
+	Exceptions []Exception
+}
+
+// Check reports the violations of the go.mod at modfilePath: requirements
+// without go.sum entries, tools that are not pinned, and requirements on
+// retracted versions. If the retractions of some requirements cannot be
+// looked up, the violations of the others are still returned, as a non-nil
+// slice, along with the error. Like Resolve, it stops when ctx is done.
+func (p *Pinner) Check(ctx context.Context, modfilePath string) ([]Violation, error) {
+	return p.CheckWith(ctx, modfilePath, CheckOptions{})
+}
+
+// CheckWith is like Check, with the optional rules of opts. The violations
+// are sorted by module path.
+func (p *Pinner) CheckWith(ctx context.Context, modfilePath string, opts CheckOptions) ([]Violation, error) {
+	m, err := gomod.Load(modfilePath)
+	if err != nil {
+		return nil, err
+	}
+	ctx = p.context(ctx)
+	c, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	copts := check.Options{
+		NoPseudo:         opts.NoPseudo,
+		Proxy:            c,
+		Concurrency:      p.concurrency,
+		FailFast:         p.failFast,
+		Ignore:           opts.Ignore,
+		MinGo:            opts.MinGo,
+		RequireToolchain: opts.RequireToolchain,
+		AllowPseudoTool:  opts.AllowPseudoTool,
+		MVS:              opts.MVS,
+		Generate:         opts.Generate,
+		Rules:            opts.Rules,
+		Exceptions:       opts.Exceptions,
+	}
+	if opts.LockFile != "" {
+		if copts.Lock, err = lockfile.Read(opts.LockFile); err != nil {
+			return nil, err
+		}
+	}
+	vs, err := check.Run(ctx, m, copts)
+	if err != nil {
+		return vs, wrap(err)
+	}
+	return vs, nil
+}
+
+// context returns ctx carrying the settings of p for the go command and the
+// limit of its proxy requests and go commands.
+func (p *Pinner) context(ctx context.Context) context.Context {
+	return workpool.WithLimit(gocmd.WithOffline(ctx, p.offline), p.limit)
+}
+
+// client returns the module proxy of p, or why there is none.
+func (p *Pinner) client() (*proxy.Client, error) {
+	if p.proxy == nil {
+		return nil, p.proxyErr
+	}
+	return p.proxy, nil
+}
+
+// notFoundMarkers appear in the messages of the go command when a module or
+// version does not exist.
+var notFoundMarkers = []string{"no matching versions", "unknown revision", "invalid version", "not found"}
+
+// notFoundError is a go command failure about an unknown module or version.
+type notFoundError struct{ err error }
+
+func (e *notFoundError) Error() string        { return e.err.Error() }
+func (e *notFoundError) Unwrap() error        { return e.err }
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// wrap makes go command failures about unknown modules match ErrNotFound.
+func wrap(err error) error {
+	var ge *gocmd.Error
+	if !errors.As(err, &ge) || errors.Is(err, ErrNotFound) {
+		return err
+	}
+	for _, m := range notFoundMarkers {
+		if strings.Contains(ge.Msg, m) {
+			return &notFoundError{err: err}
+		}
+	}
+	return err
+}
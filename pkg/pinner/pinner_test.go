@@ -0,0 +1,257 @@
+package pinner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"pin-go-dependencies/internal/metrics"
+	"pin-go-dependencies/internal/proxytest"
+	"pin-go-dependencies/internal/workpool"
+	"pin-go-dependencies/pkg/pinner"
+)
+
+// modules has a module a requiring b, which the main module does not list.
+var modules = []proxytest.Module{
+	{Path: "example.com/a", Version: "v1.0.0", GoMod: "module example.com/a\n\ngo 1.16\n\nrequire example.com/b v1.1.0\n", Files: map[string]string{"a.go": "package a\n"}},
+	{Path: "example.com/b", Version: "v1.0.0", Files: map[string]string{"b.go": "package b\n"}},
+	{Path: "example.com/b", Version: "v1.1.0", Files: map[string]string{"b.go": "package b\n"}},
+}
+
+const mainGoMod = `module example.com/main
+
+go 1.16
+
+require example.com/a v1.0.0
+`
+
+// setup writes the main module to a temporary directory and returns its
+// go.mod and a Pinner using s.
+func setup(t *testing.T, s *proxytest.Server) (string, *pinner.Pinner) {
+	t.Helper()
+	s.UseWithGo(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "go.mod")
+	if err := os.WriteFile(file, []byte(mainGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), s.GoSum(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pinner.New(pinner.WithProxy(s.URL), pinner.WithCacheDir(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file, p
+}
+
+func TestResolve(t *testing.T) {
+	s := proxytest.New(t, modules...)
+	file, p := setup(t, s)
+
+	res, err := p.Resolve(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pinner.Change{{Path: "example.com/b", New: "v1.1.0", Indirect: true}}
+	if !reflect.DeepEqual(res.Added, want) || len(res.Changed) != 0 {
+		t.Errorf("Added = %+v, Changed = %+v, want Added = %+v", res.Added, res.Changed, want)
+	}
+	if !res.Modified() || !strings.Contains(string(res.Diff()), "+require example.com/b v1.1.0 // indirect") {
+		t.Errorf("Diff =\n%s", res.Diff())
+	}
+	if data, _ := os.ReadFile(file); string(data) != mainGoMod {
+		t.Errorf("Resolve wrote go.mod:\n%s", data)
+	}
+}
+
+func TestApply(t *testing.T) {
+	s := proxytest.New(t, modules...)
+	file, p := setup(t, s)
+	ctx := context.Background()
+
+	res, err := p.Resolve(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Apply(ctx, res); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "require example.com/b v1.1.0 // indirect\n") {
+		t.Errorf("go.mod after Apply:\n%s", data)
+	}
+	again, err := p.Resolve(ctx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Modified() {
+		t.Errorf("Resolve after Apply still changes:\n%s", again.Diff())
+	}
+}
+
+func TestApplyCanceled(t *testing.T) {
+	s := proxytest.New(t, modules...)
+	file, p := setup(t, s)
+
+	res, err := p.Resolve(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Apply(ctx, res); !errors.Is(err, context.Canceled) {
+		t.Errorf("Apply = %v, want context.Canceled", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != mainGoMod {
+		t.Errorf("canceled Apply wrote go.mod:\n%s", data)
+	}
+}
+
+func TestResolveCanceled(t *testing.T) {
+	s := proxytest.New(t, modules...)
+	file, p := setup(t, s)
+	s.Hang()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := p.Resolve(ctx, file)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Resolve = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Resolve returned %s after the cancellation", d)
+	}
+	if len(s.Requests()) == 0 {
+		t.Error("Resolve was canceled before reaching the proxy")
+	}
+}
+
+func TestResolveRetracted(t *testing.T) {
+	s := proxytest.New(t, append(modules, proxytest.Module{
+		Path:    "example.com/a",
+		Version: "v1.0.1",
+		GoMod:   "module example.com/a\n\ngo 1.16\n\nrequire example.com/b v1.1.0\n\nretract v1.0.0 // broken\n",
+		Files:   map[string]string{"a.go": "package a\n"},
+	})...)
+	file, p := setup(t, s)
+
+	_, err := p.Resolve(context.Background(), file)
+	if !errors.Is(err, pinner.ErrRetracted) || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Resolve = %v, want a retraction of example.com/a v1.0.0", err)
+	}
+}
+
+func TestConcurrencySharedByCalls(t *testing.T) {
+	// Several modules pinned at a time, like pin --recursive does, share
+	// the limit of the Pinner instead of each getting one of their own.
+	// Their dependencies differ, so that their lookups are not shared.
+	const n = 2
+	var deps []proxytest.Module
+	requires := make([]string, 4)
+	for i := 0; i < 4*6; i++ {
+		path := fmt.Sprintf("example.com/d%d", i)
+		deps = append(deps, proxytest.Module{Path: path, Version: "v1.0.0", GoMod: "module " + path + "\n\ngo 1.16\n", Files: map[string]string{"d.go": "package d\n"}})
+		requires[i%4] += "\t" + path + " v1.0.0\n"
+	}
+	s := proxytest.New(t, deps...)
+	s.UseWithGo(t)
+	var files []string
+	for i, require := range requires {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "go.mod")
+		gomod := fmt.Sprintf("module example.com/main%d\n\ngo 1.16\n\nrequire (\n%s)\n", i, require)
+		if err := os.WriteFile(file, []byte(gomod), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "go.sum"), s.GoSum(t), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	p, err := pinner.New(pinner.WithProxy(s.URL), pinner.WithCacheDir(""), pinner.WithConcurrency(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(file string) (*pinner.Resolution, error) {
+		return p.Resolve(context.Background(), file)
+	}
+
+	// The first round fills the module cache, so that the go commands,
+	// which download in parallel on their own, leave the proxy alone
+	// afterwards.
+	for _, file := range files {
+		if _, err := resolve(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Delay(10 * time.Millisecond)
+	s.ResetPeak()
+	before := len(s.Requests())
+	if _, errs := workpool.Map(n, false, files, resolve); errors.Join(errs...) != nil {
+		t.Fatal(errors.Join(errs...))
+	}
+	if len(s.Requests()) == before {
+		t.Fatal("the modules were pinned without proxy requests")
+	}
+	if peak := s.Peak(); peak > n {
+		t.Errorf("peak of %d proxy requests in flight, want at most %d", peak, n)
+	}
+}
+
+func TestResolveCountsModules(t *testing.T) {
+	// a and b are pinned; c is replaced by a directory and d is excluded,
+	// so neither counts as resolved.
+	s := proxytest.New(t, append(modules,
+		proxytest.Module{Path: "example.com/d", Version: "v1.0.0", Files: map[string]string{"d.go": "package d\n"}},
+	)...)
+	file, p := setup(t, s)
+	gomod := mainGoMod + "require (\n\texample.com/c v1.0.0\n\texample.com/d v1.0.0\n)\n\nreplace example.com/c => ./c\n"
+	if err := os.WriteFile(file, []byte(gomod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := filepath.Join(filepath.Dir(file), "c")
+	if err := os.Mkdir(c, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(c, "go.mod"), []byte("module example.com/c\n\ngo 1.16\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts pinner.ResolveOptions
+	}{
+		{"build list", pinner.ResolveOptions{}},
+		{"before", pinner.ResolveOptions{Before: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := metrics.NewRecorder()
+			old := metrics.Default
+			metrics.Default = r
+			t.Cleanup(func() { metrics.Default = old })
+
+			tt.opts.Exclude = func(path string) bool { return path == "example.com/d" }
+			if _, err := p.ResolveWith(context.Background(), file, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			counters := r.Stats().Counters
+			if n := counters[metrics.ModulesResolved]; n != 2 {
+				t.Errorf("%d modules resolved, want 2", n)
+			}
+			if counters[metrics.ProxyRequests] == 0 || counters[metrics.GoCommands] == 0 {
+				t.Errorf("counters = %v, want proxy requests and go commands", counters)
+			}
+		})
+	}
+}